@@ -0,0 +1,74 @@
+package incache
+
+// Number is the constraint of the values aggregated by Sum, Min, Max and Avg.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// ranger is implemented by the caches that can iterate over their entries without copying them, see LRUCache.Range.
+type ranger[K comparable, V any] interface {
+	Range(f func(k K, v V) bool)
+}
+
+// rangeLive calls f for each non-expired value of c, using Range if c implements it and GetAll otherwise.
+func rangeLive[K comparable, V any](c Cache[K, V], f func(v V)) {
+	if r, ok := c.(ranger[K, V]); ok {
+		r.Range(func(_ K, v V) bool {
+			f(v)
+			return true
+		})
+		return
+	}
+	for _, v := range c.GetAll() {
+		f(v)
+	}
+}
+
+// Sum returns the sum of the non-expired values of the cache, or 0 if it is empty.
+// The values of LRUCache, LFUCache and MCache are summed in place; other caches are read with GetAll.
+func Sum[K comparable, N Number](c Cache[K, N]) N {
+	var sum N
+	rangeLive(c, func(v N) { sum += v })
+	return sum
+}
+
+// Min returns the smallest non-expired value of the cache. It returns false if the cache holds no live entry.
+func Min[K comparable, N Number](c Cache[K, N]) (N, bool) {
+	var m N
+	found := false
+	rangeLive(c, func(v N) {
+		if !found || v < m {
+			m, found = v, true
+		}
+	})
+	return m, found
+}
+
+// Max returns the largest non-expired value of the cache. It returns false if the cache holds no live entry.
+func Max[K comparable, N Number](c Cache[K, N]) (N, bool) {
+	var m N
+	found := false
+	rangeLive(c, func(v N) {
+		if !found || v > m {
+			m, found = v, true
+		}
+	})
+	return m, found
+}
+
+// Avg returns the mean of the non-expired values of the cache. It returns false if the cache holds no live entry.
+// The values are summed as float64, so large integers may lose precision.
+func Avg[K comparable, N Number](c Cache[K, N]) (float64, bool) {
+	var sum float64
+	n := 0
+	rangeLive(c, func(v N) {
+		sum += float64(v)
+		n++
+	})
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
@@ -0,0 +1,53 @@
+package incache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregates(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	caches["SLRU"] = NewSLRU(10, WithClock[string, int](clock))
+
+	for name, c := range caches {
+		c.Set("a", 4)
+		c.Set("b", -2)
+		c.SetWithTimeout("c", 10, time.Minute)
+		c.SetWithTimeout("expired", 100, time.Second)
+		c.SetWithTimeout("expired-low", -100, time.Second)
+		clock.Advance(2 * time.Second)
+
+		if got := Sum(c); got != 12 {
+			t.Errorf("%s: expected Sum 12, got %d", name, got)
+		}
+		if got, ok := Min(c); !ok || got != -2 {
+			t.Errorf("%s: expected Min -2, got %d, %v", name, got, ok)
+		}
+		if got, ok := Max(c); !ok || got != 10 {
+			t.Errorf("%s: expected Max 10, got %d, %v", name, got, ok)
+		}
+		if got, ok := Avg(c); !ok || got != 4 {
+			t.Errorf("%s: expected Avg 4, got %v, %v", name, got, ok)
+		}
+		c.Close()
+	}
+}
+
+func TestAggregates_Empty(t *testing.T) {
+	c := NewLRU[string, float64](10)
+	defer c.Close()
+
+	if got := Sum[string, float64](c); got != 0 {
+		t.Errorf("Expected Sum 0, got %v", got)
+	}
+	if _, ok := Min[string, float64](c); ok {
+		t.Errorf("Expected Min to report an empty cache")
+	}
+	if _, ok := Max[string, float64](c); ok {
+		t.Errorf("Expected Max to report an empty cache")
+	}
+	if _, ok := Avg[string, float64](c); ok {
+		t.Errorf("Expected Avg to report an empty cache")
+	}
+}
@@ -3,6 +3,7 @@ package incache
 import (
 	"strconv"
 	"testing"
+	"time"
 )
 
 // LFU Benchmarks
@@ -71,6 +72,19 @@ func BenchmarkLRU_Get(b *testing.B) {
 	}
 }
 
+// BenchmarkLRU_Get_Allocs reports the allocations per Get of a present key, which should be zero.
+func BenchmarkLRU_Get_Allocs(b *testing.B) {
+	cache := NewLRU[int, int](10000)
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(i % 10000)
+	}
+}
+
 func BenchmarkLRU_SetGet(b *testing.B) {
 	cache := NewLRU[int, int](10000)
 	b.ResetTimer()
@@ -248,6 +262,21 @@ func BenchmarkLRU_Parallel_Get(b *testing.B) {
 	})
 }
 
+func BenchmarkLRU_Parallel_Get_ReadBuffer(b *testing.B) {
+	cache := NewLRU(10000, WithReadBuffer[int, int](1024))
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(i % 10000)
+			i++
+		}
+	})
+}
+
 func BenchmarkMCache_Parallel_Set(b *testing.B) {
 	cache := NewManual[int, int](10000, 0)
 	b.ResetTimer()
@@ -274,3 +303,179 @@ func BenchmarkMCache_Parallel_Get(b *testing.B) {
 		}
 	})
 }
+
+// Warmup benchmarks
+
+func BenchmarkLRU_Warmup(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache := NewLRU[int, int](100000)
+		for j := 0; j < 100000; j++ {
+			cache.Set(j, j)
+		}
+	}
+}
+
+func BenchmarkLRU_Warmup_InitialCapacity(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache := NewLRU(100000, WithInitialCapacity[int, int](100000))
+		for j := 0; j < 100000; j++ {
+			cache.Set(j, j)
+		}
+	}
+}
+
+func BenchmarkMCache_Warmup(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache := NewManual[int, int](100000, 0)
+		for j := 0; j < 100000; j++ {
+			cache.Set(j, j)
+		}
+	}
+}
+
+func BenchmarkMCache_Warmup_InitialCapacity(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache := NewManual(100000, 0, WithInitialCapacity[int, int](100000))
+		for j := 0; j < 100000; j++ {
+			cache.Set(j, j)
+		}
+	}
+}
+
+// Churn benchmarks
+
+func BenchmarkLRU_Churn(b *testing.B) {
+	cache := NewLRU[int, int](10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+// BenchmarkLRU_Churn_Allocs reports the allocations per Set of a new key into a full cache.
+// The new item reuses the slot of the evicted one, so it does not allocate.
+func BenchmarkLRU_Churn_Allocs(b *testing.B) {
+	cache := NewLRU[int, int](10000)
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 10000; i < b.N+10000; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkLRU_Churn_EvictionBatch(b *testing.B) {
+	cache := NewLRU(10000, WithEvictionBatch[int, int](100))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkLRU_Churn_Watermarks(b *testing.B) {
+	cache := NewLRU(10000, WithWatermarks[int, int](1, 0.9))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkLFU_Churn(b *testing.B) {
+	cache := NewLFU[int, int](10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkLFU_Churn_EvictionBatch(b *testing.B) {
+	cache := NewLFU(10000, WithEvictionBatch[int, int](100))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkMCache_Churn(b *testing.B) {
+	cache := NewManual[int, int](1000, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkMCache_Churn_EvictionBatch(b *testing.B) {
+	cache := NewManual(1000, 0, WithEvictionBatch[int, int](100))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i, i)
+	}
+}
+
+func BenchmarkLRU_SetEqual(b *testing.B) {
+	cache := NewLRU[int, int](10000, WithValueEquals[int, int](func(a, b int) bool { return a == b }))
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(i%10000, i%10000)
+	}
+}
+
+// Count benchmarks
+
+func BenchmarkLRU_Count(b *testing.B) {
+	cache := NewLRU[int, int](100000)
+	for i := 0; i < 100000; i++ {
+		cache.SetWithTimeout(i, i, time.Hour)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Count()
+	}
+}
+
+func BenchmarkLRU_Count_Approximate(b *testing.B) {
+	cache := NewLRU(100000, WithApproximateCount[int, int]())
+	for i := 0; i < 100000; i++ {
+		cache.SetWithTimeout(i, i, time.Hour)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Count()
+	}
+}
+
+// GetAll benchmarks
+
+func BenchmarkLRU_GetAll(b *testing.B) {
+	cache := NewLRU[int, int](10000)
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.GetAll()
+	}
+}
+
+func BenchmarkLRU_GetAllInto(b *testing.B) {
+	cache := NewLRU[int, int](10000)
+	for i := 0; i < 10000; i++ {
+		cache.Set(i, i)
+	}
+	dst := make(map[int]int, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.GetAllInto(dst)
+	}
+}
@@ -1,6 +1,12 @@
 package incache
 
-import "time"
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
 
 // Cache is the common interface implemented by all cache types.
 // It provides a unified API for cache operations regardless of the underlying eviction policy.
@@ -10,6 +16,14 @@ type Cache[K comparable, V any] interface {
 	// Otherwise, it returns (value, true).
 	Get(k K) (V, bool)
 
+	// GetOrDefault retrieves the value associated with the given key from the cache like Get,
+	// or returns def if the key is not found or has expired.
+	GetOrDefault(k K, def V) V
+
+	// GetWithExpiration retrieves the value associated with the given key together with its expiration time.
+	// If the key is not found or has expired, it returns (zero value of ValueTTL[V], false).
+	GetWithExpiration(k K) (ValueTTL[V], bool)
+
 	// Set adds or updates a key-value pair in the cache without setting an expiration time.
 	Set(k K, v V)
 
@@ -28,9 +42,16 @@ type Cache[K comparable, V any] interface {
 	// It returns true if the key was added to the cache, otherwise false.
 	NotFoundSetWithTimeout(k K, v V, timeout time.Duration) bool
 
+	// ReplaceIfPresent updates the value of a key only if it exists and is not expired.
+	// It returns true if the value was replaced, otherwise false. It never creates a new key.
+	ReplaceIfPresent(k K, v V) bool
+
 	// GetAll retrieves all non-expired key-value pairs from the cache.
 	GetAll() map[K]V
 
+	// GetAllWithExpiration retrieves all non-expired key-value pairs from the cache together with their expiration times.
+	GetAllWithExpiration() map[K]ValueTTL[V]
+
 	// Keys returns a slice of all non-expired keys currently stored in the cache.
 	Keys() []K
 
@@ -43,6 +64,41 @@ type Cache[K comparable, V any] interface {
 
 	// Len returns the total number of elements in the cache (including expired ones).
 	Len() int
+
+	// Stats returns the usage statistics of the cache.
+	Stats() Stats
+
+	// Name returns the name of the cache set with WithName, or an empty string.
+	Name() string
+
+	// Close stops any background goroutines of the cache and releases its resources.
+	// After calling Close, the cache should not be used.
+	Close()
+}
+
+// NoExpiry is the time to live reported by TTL for keys that never expire.
+const NoExpiry time.Duration = -1
+
+// ValueTTL is a cached value together with its expiration time.
+type ValueTTL[V any] struct {
+	Value V
+	// ExpireAt is the time at which the value expires. The zero time means the value never expires.
+	ExpireAt time.Time
+}
+
+// Entry is a key-value pair of a cache.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Expirable is implemented by values that hold resources and must release them when they leave the cache.
+// Whenever an entry is removed from a cache, because it was evicted, expired, deleted or purged,
+// OnRemoved is called on its value if the value implements Expirable.
+// Values that leave the cache to be handed to the caller, e.g. by TransferTo or Drain, are not notified.
+// OnRemoved is called while the cache lock is held and must not call methods of the cache.
+type Expirable interface {
+	OnRemoved()
 }
 
 // Compile-time checks to ensure all cache types implement the Cache interface
@@ -50,4 +106,82 @@ var (
 	_ Cache[string, any] = (*LFUCache[string, any])(nil)
 	_ Cache[string, any] = (*LRUCache[string, any])(nil)
 	_ Cache[string, any] = (*MCache[string, any])(nil)
+	_ Cache[string, any] = (*SLRUCache[string, any])(nil)
+	_ Cache[string, any] = (*ShardedCache[string, any])(nil)
+	_ Cache[string, any] = (*TieredCache[string, any])(nil)
 )
+
+// sortedEntries returns the key-value pairs of m as entries sorted by key according to less.
+func sortedEntries[K comparable, V any](m map[K]V, less func(a, b K) bool) []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, len(m))
+	for k, v := range m {
+		entries = append(entries, Entry[K, V]{Key: k, Value: v})
+	}
+	slices.SortFunc(entries, func(a, b Entry[K, V]) int {
+		switch {
+		case less(a.Key, b.Key):
+			return -1
+		case less(b.Key, a.Key):
+			return 1
+		}
+		return 0
+	})
+	return entries
+}
+
+// expiresBefore reports whether expiration time a is earlier than expiration time b.
+// An expiration time of 0 means the entry never expires.
+func expiresBefore(a, b int64) bool {
+	if a == 0 {
+		return false
+	}
+	return b == 0 || a < b
+}
+
+// remainingTTL returns the time to live of an entry with the Unix nano expiration timestamp expireAt,
+// as of the Unix nano timestamp now, or NoExpiry if it never expires. It returns false if the entry has expired.
+func remainingTTL(expireAt, now int64) (time.Duration, bool) {
+	switch {
+	case expireAt == 0:
+		return NoExpiry, true
+	case expireAt < now:
+		return 0, false
+	}
+	return time.Duration(expireAt - now), true
+}
+
+// expireTime converts a Unix nano expiration timestamp to a time.Time.
+// An expiration timestamp of 0 is converted to the zero time.
+func expireTime(expireAt int64) time.Time {
+	if expireAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, expireAt)
+}
+
+// sizeString formats the size of a cache for String, where unbounded caches have the maximum size.
+func sizeString(size uint) string {
+	if size == math.MaxUint {
+		return "unbounded"
+	}
+	return strconv.FormatUint(uint64(size), 10)
+}
+
+// keyExpiration is a key together with its Unix nano expiration timestamp.
+type keyExpiration[K comparable] struct {
+	key      K
+	expireAt int64
+}
+
+// keysByExpiration returns the keys of the entries sorted by expiration time, soonest first.
+func keysByExpiration[K comparable](entries []keyExpiration[K]) []K {
+	slices.SortFunc(entries, func(a, b keyExpiration[K]) int {
+		return cmp.Compare(a.expireAt, b.expireAt)
+	})
+
+	keys := make([]K, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+	return keys
+}
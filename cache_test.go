@@ -0,0 +1,1174 @@
+package incache
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCaches creates an LRU, an LFU and an MCache of the given size with opts, keyed by their type,
+// for the tests of the behavior shared by the caches. Tests add other types, e.g. SLRU, where they apply.
+func newCaches[K comparable, V any](size uint, opts ...Option[K, V]) map[string]Cache[K, V] {
+	return map[string]Cache[K, V]{
+		"LRU":    NewLRU(size, opts...),
+		"LFU":    NewLFU(size, opts...),
+		"MCache": NewManual(size, 0, opts...),
+	}
+}
+
+func TestCache_Close(t *testing.T) {
+	caches := map[string]Cache[string, int]{
+		"LRU":                 NewLRU(10, WithCleanupInterval[string, int](time.Millisecond)),
+		"LFU":                 NewLFU(10, WithCleanupInterval[string, int](time.Millisecond)),
+		"MCache":              NewManual(10, time.Millisecond, WithCleanupInterval[string, int](time.Millisecond)),
+		"LRU without sweeper": NewLRU[string, int](10),
+	}
+
+	for name, c := range caches {
+		c.Set("key", 1)
+		c.Close()
+
+		if c.Len() != 0 {
+			t.Errorf("%s: expected Close to release the entries, got Len=%d", name, c.Len())
+		}
+	}
+}
+
+func TestCache_UseAfterClose(t *testing.T) {
+	caches := map[string]Cache[string, int]{
+		"LRU":    NewLRU(10, WithCleanupInterval[string, int](time.Millisecond)),
+		"LFU":    NewLFU(10, WithCleanupInterval[string, int](time.Millisecond)),
+		"MCache": NewManual[string, int](10, time.Millisecond),
+	}
+
+	for name, c := range caches {
+		c.Set("key", 1)
+		c.Close()
+		c.Close()
+
+		c.Set("key", 2)
+		c.SetWithTimeout("key", 2, time.Second)
+		if c.NotFoundSet("key", 2) || c.NotFoundSetWithTimeout("key", 2, time.Second) {
+			t.Errorf("%s: NotFoundSet should not store on a closed cache", name)
+		}
+		if c.ReplaceIfPresent("key", 2) {
+			t.Errorf("%s: ReplaceIfPresent should not store on a closed cache", name)
+		}
+		if _, ok := c.Get("key"); ok {
+			t.Errorf("%s: Get should not find keys on a closed cache", name)
+		}
+		c.Delete("key")
+		c.Purge()
+		c.Set("key", 3)
+		if len(c.GetAll()) != 0 || len(c.Keys()) != 0 || c.Count() != 0 || c.Len() != 0 {
+			t.Errorf("%s: expected closed cache to stay empty", name)
+		}
+
+		ts, ok := c.(interface{ TrySet(string, int) error })
+		if !ok {
+			t.Fatalf("%s: expected cache to implement TrySet", name)
+		}
+		if err := ts.TrySet("key", 4); err != ErrCacheClosed {
+			t.Errorf("%s: expected ErrCacheClosed, got %v", name, err)
+		}
+	}
+}
+
+func TestCache_TrySetFull(t *testing.T) {
+	caches := newCaches[string, int](2)
+
+	for name, c := range caches {
+		ts := c.(interface{ TrySet(string, int) error })
+		if err := ts.TrySet("a", 1); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		if err := ts.TrySet("b", 2); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+
+		if err := ts.TrySet("c", 3); err != ErrCacheFull {
+			t.Errorf("%s: expected ErrCacheFull at capacity, got %v", name, err)
+		}
+		if c.Count() != 2 {
+			t.Errorf("%s: expected TrySet not to evict, got Count=%d", name, c.Count())
+		}
+		if err := ts.TrySet("a", 10); err != nil {
+			t.Errorf("%s: expected update of existing key to succeed, got %v", name, err)
+		}
+
+		c.Delete("b")
+		if err := ts.TrySet("c", 3); err != nil {
+			t.Errorf("%s: expected TrySet to succeed after Delete, got %v", name, err)
+		}
+		if v, ok := c.Get("c"); !ok || v != 3 {
+			t.Errorf("%s: expected c to be stored, got %v", name, v)
+		}
+	}
+}
+
+func TestCache_GetManyWithExpiration(t *testing.T) {
+	type manyGetter interface {
+		GetManyWithExpiration(keys []string) map[string]ValueTTL[int]
+	}
+
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("forever", 1)
+		c.SetWithTimeout("live", 2, time.Minute)
+		c.SetWithTimeout("expired", 3, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		got := c.(manyGetter).GetManyWithExpiration([]string{"forever", "live", "expired", "missing"})
+		if len(got) != 2 {
+			t.Errorf("%s: expected only the 2 live keys, got %v", name, got)
+		}
+		if e := got["forever"]; e.Value != 1 || !e.ExpireAt.IsZero() {
+			t.Errorf("%s: expected forever=1 without expiration, got %+v", name, e)
+		}
+		want := clock.Now().Add(time.Minute - 2*time.Second)
+		if e := got["live"]; e.Value != 2 || !e.ExpireAt.Equal(want) {
+			t.Errorf("%s: expected live=2 expiring at %v, got %+v", name, want, e)
+		}
+	}
+}
+
+func TestCache_SetIfAbsent(t *testing.T) {
+	type absentSetter interface {
+		SetIfAbsent(k string, v int) (int, bool)
+	}
+
+	caches := newCaches[string, int](10)
+
+	for name, c := range caches {
+		s := c.(absentSetter)
+
+		if current, set := s.SetIfAbsent("key", 1); !set || current != 1 {
+			t.Errorf("%s: expected absent key to be set, got (%v, %v)", name, current, set)
+		}
+		if current, set := s.SetIfAbsent("key", 2); set || current != 1 {
+			t.Errorf("%s: expected the current holder (1, false), got (%v, %v)", name, current, set)
+		}
+		if v, _ := c.Get("key"); v != 1 {
+			t.Errorf("%s: expected existing value to be kept, got %v", name, v)
+		}
+
+		c.SetWithTimeout("expired", 1, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		if current, set := s.SetIfAbsent("expired", 2); !set || current != 2 {
+			t.Errorf("%s: expected expired key to be set, got (%v, %v)", name, current, set)
+		}
+
+		c.Close()
+		if current, set := s.SetIfAbsent("other", 3); set || current != 0 {
+			t.Errorf("%s: expected (0, false) on a closed cache, got (%v, %v)", name, current, set)
+		}
+	}
+}
+
+func TestCache_ExtendMany(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("forever", 1)
+		c.SetWithTimeout("a", 2, time.Minute)
+		c.SetWithTimeout("b", 3, 2*time.Minute)
+		c.SetWithTimeout("expired", 4, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		extended := c.(interface {
+			ExtendMany([]string, time.Duration) int
+		}).ExtendMany([]string{"forever", "a", "b", "expired", "missing"}, time.Hour)
+		if extended != 2 {
+			t.Errorf("%s: expected 2 keys to be extended, got %d", name, extended)
+		}
+
+		all := c.GetAllWithExpiration()
+		if !all["forever"].ExpireAt.IsZero() {
+			t.Errorf("%s: expected forever to keep no expiration, got %v", name, all["forever"].ExpireAt)
+		}
+		start := clock.Now().Add(-2 * time.Second)
+		if want := start.Add(time.Minute + time.Hour); !all["a"].ExpireAt.Equal(want) {
+			t.Errorf("%s: expected a to expire at %v, got %v", name, want, all["a"].ExpireAt)
+		}
+		if want := start.Add(2*time.Minute + time.Hour); !all["b"].ExpireAt.Equal(want) {
+			t.Errorf("%s: expected b to expire at %v, got %v", name, want, all["b"].ExpireAt)
+		}
+		if _, ok := all["expired"]; ok {
+			t.Errorf("%s: expected the expired key to stay expired", name)
+		}
+	}
+}
+
+func TestCache_DeleteExpired(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("live", 1)
+		c.SetWithTimeout("ttl", 2, time.Minute)
+		c.SetWithTimeout("expired1", 3, time.Second)
+		c.SetWithTimeout("expired2", 4, time.Second)
+		c.SetWithTimeout("unlisted", 5, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		removed := c.(interface{ DeleteExpired([]string) int }).DeleteExpired([]string{"live", "ttl", "expired1", "expired2", "missing"})
+		if removed != 2 {
+			t.Errorf("%s: expected 2 expired keys to be removed, got %d", name, removed)
+		}
+		// Only the supplied keys are removed, live keys are kept
+		if c.Len() != 3 {
+			t.Errorf("%s: expected live, ttl and unlisted to remain, got Len=%d", name, c.Len())
+		}
+		if c.Count() != 2 {
+			t.Errorf("%s: expected 2 live keys, got %d", name, c.Count())
+		}
+
+		// The cache stays consistent after removing entries from its internal structures
+		for i := 0; i < 20; i++ {
+			c.Set(fmt.Sprint(i), i)
+		}
+		if c.Len() != 10 {
+			t.Errorf("%s: expected a full cache after refilling, got Len=%d", name, c.Len())
+		}
+	}
+}
+
+func TestCache_ExpiringWithin(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("forever", 0)
+		c.SetWithTimeout("expired", 0, time.Second)
+		c.SetWithTimeout("later", 0, 40*time.Second)
+		c.SetWithTimeout("soon", 0, 5*time.Second)
+		c.SetWithTimeout("soonest", 0, 3*time.Second)
+		c.SetWithTimeout("outside", 0, time.Hour)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		got := c.(interface{ ExpiringWithin(time.Duration) []string }).ExpiringWithin(time.Minute)
+		want := []string{"soonest", "soon", "later"}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestCache_GetWhere(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("user:1", 10)
+		c.Set("user:2", 20)
+		c.Set("order:1", 30)
+		c.SetWithTimeout("user:3", 25, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		getWhere := c.(interface {
+			GetWhere(func(k string, v int) bool) map[string]int
+		}).GetWhere
+
+		got := getWhere(func(k string, v int) bool { return v >= 15 && v <= 30 })
+		want := map[string]int{"user:2": 20, "order:1": 30}
+		if !maps.Equal(got, want) {
+			t.Errorf("%s: expected %v for the value range, got %v", name, want, got)
+		}
+
+		got = getWhere(func(k string, v int) bool { return strings.HasPrefix(k, "user:") })
+		want = map[string]int{"user:1": 10, "user:2": 20}
+		if !maps.Equal(got, want) {
+			t.Errorf("%s: expected %v for the key pattern, got %v", name, want, got)
+		}
+
+		// Expired entries are excluded even if the predicate accepts everything
+		got = getWhere(func(k string, v int) bool { return true })
+		if _, ok := got["user:3"]; ok || len(got) != 3 {
+			t.Errorf("%s: expected only the 3 live entries, got %v", name, got)
+		}
+	}
+}
+
+func TestCache_SomeKeys(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[int, int](clock))
+
+	for _, c := range caches {
+		for i := 0; i < 6; i++ {
+			c.Set(i, i)
+		}
+		c.SetWithTimeout(6, 6, time.Second)
+		c.SetWithTimeout(7, 7, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		someKeys := c.(interface{ SomeKeys(int) []int }).SomeKeys
+		for _, n := range []int{-1, 0, 3, 6, 10} {
+			keys := someKeys(n)
+			if want := max(min(n, c.Count()), 0); len(keys) != want {
+				t.Errorf("%s: expected %d keys for n=%d, got %v", name, want, n, keys)
+			}
+			for _, k := range keys {
+				if k >= 6 {
+					t.Errorf("%s: expected only live keys for n=%d, got %v", name, n, keys)
+				}
+			}
+		}
+	}
+}
+
+func TestCache_SomeKeysEvictionOrder(t *testing.T) {
+	lru := NewLRU[int, int](10)
+	lfu := NewLFU[int, int](10)
+	for i := 0; i < 5; i++ {
+		lru.Set(i, i)
+		lfu.Set(i, i)
+	}
+	lru.Get(0)
+	lfu.Get(0)
+	lfu.Get(1)
+	lfu.Get(1)
+
+	if got, want := lru.SomeKeys(3), []int{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("LRU: expected the least recently used keys %v, got %v", want, got)
+	}
+	if got, want := lfu.SomeKeys(5), []int{2, 3, 4, 0, 1}; !slices.Equal(got, want) {
+		t.Errorf("LFU: expected the least frequently used keys %v, got %v", want, got)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	caches := newCaches[string, int](2)
+	caches["SLRU"] = NewSLRU[string, int](2)
+
+	for name, c := range caches {
+		c.Set("a", 1)
+		c.SetWithTimeout("b", 2, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+
+		c.Get("a")
+		c.Get("a")
+		c.Get("b") // expired
+		c.Get("missing")
+
+		c.Set("c", 3)
+		c.Set("d", 4) // evicts a
+
+		s := c.Stats()
+		if s.Hits != 2 || s.Misses != 2 || s.Evictions != 1 || s.Len != 2 {
+			t.Errorf("%s: expected 2 hits, 2 misses, 1 eviction and 2 entries, got %+v", name, s)
+		}
+		if s.HitRatio() != 0.5 {
+			t.Errorf("%s: expected a hit ratio of 0.5, got %v", name, s.HitRatio())
+		}
+	}
+}
+
+func TestCache_GetState(t *testing.T) {
+	type stateGetter interface {
+		GetState(k string) (int, Presence)
+	}
+
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("live", 1)
+		c.SetWithTimeout("expired", 2, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		g := c.(stateGetter)
+		if v, p := g.GetState("live"); v != 1 || p != Live {
+			t.Errorf("%s: expected (1, live), got (%v, %v)", name, v, p)
+		}
+		if v, p := g.GetState("expired"); v != 2 || p != Expired {
+			t.Errorf("%s: expected (2, expired), got (%v, %v)", name, v, p)
+		}
+		// The expired key is removed, so it is absent afterwards
+		if v, p := g.GetState("expired"); v != 0 || p != Absent {
+			t.Errorf("%s: expected (0, absent) after removal, got (%v, %v)", name, v, p)
+		}
+		if v, p := g.GetState("never"); v != 0 || p != Absent {
+			t.Errorf("%s: expected (0, absent), got (%v, %v)", name, v, p)
+		}
+	}
+}
+
+func TestCache_NewFromSeed(t *testing.T) {
+	seed := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		seed[fmt.Sprint(i)] = i
+	}
+
+	caches := map[string]Cache[string, int]{
+		"LRU":    NewLRUFrom(5, seed),
+		"LFU":    NewLFUFrom(5, seed),
+		"MCache": NewManualFrom(5, 0, seed),
+		"SLRU":   NewSLRUFrom(5, seed),
+	}
+
+	for name, c := range caches {
+		if c.Len() != 5 {
+			t.Errorf("%s: expected the oversized seed to be capped at 5 entries, got %d", name, c.Len())
+		}
+		for k, v := range c.GetAll() {
+			if seed[k] != v {
+				t.Errorf("%s: expected %s=%d from the seed, got %d", name, k, seed[k], v)
+			}
+		}
+	}
+
+	c := NewLRUFrom(100, map[string]int{"a": 1, "b": 2})
+	if c.Len() != 2 {
+		t.Errorf("Expected all seed entries to be stored, got Len=%d", c.Len())
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Errorf("Expected b=2 from the seed, got %v", v)
+	}
+}
+
+func TestCache_NameAndString(t *testing.T) {
+	clock := NewMockClock()
+	opts := []Option[string, int]{WithName[string, int]("sessions"), WithClock[string, int](clock)}
+	caches := map[string]Cache[string, int]{
+		"incache.LRU[":    NewLRU(10, opts...),
+		"incache.LFU[":    NewLFU(10, opts...),
+		"incache.MCache[": NewManual(10, 0, opts...),
+		"incache.SLRU[":   NewSLRU(10, opts...),
+	}
+
+	for _, c := range caches {
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.SetWithTimeout("c", 3, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for prefix, c := range caches {
+		if c.Name() != "sessions" {
+			t.Errorf("%s: expected name sessions, got %q", prefix, c.Name())
+		}
+
+		want := prefix + "name=sessions size=10 count=2]"
+		if s := c.(fmt.Stringer).String(); s != want {
+			t.Errorf("expected %q, got %q", want, s)
+		}
+	}
+
+	if name := NewLRU[string, int](10).Name(); name != "" {
+		t.Errorf("expected empty name by default, got %q", name)
+	}
+}
+
+// resource is a value that records whether it has been removed from a cache.
+type resource struct {
+	removed *bool
+}
+
+func (r resource) OnRemoved() {
+	*r.removed = true
+}
+
+func TestCache_Expirable(t *testing.T) {
+	newCaches := map[string]func(clock Clock) Cache[string, resource]{
+		"LRU":    func(clock Clock) Cache[string, resource] { return NewLRU(2, WithClock[string, resource](clock)) },
+		"LFU":    func(clock Clock) Cache[string, resource] { return NewLFU(2, WithClock[string, resource](clock)) },
+		"MCache": func(clock Clock) Cache[string, resource] { return NewManual(2, 0, WithClock[string, resource](clock)) },
+		"SLRU":   func(clock Clock) Cache[string, resource] { return NewSLRU(2, WithClock[string, resource](clock)) },
+	}
+
+	for name, newCache := range newCaches {
+		paths := map[string]func(c Cache[string, resource], clock *MockClock){
+			"delete": func(c Cache[string, resource], clock *MockClock) { c.Delete("a") },
+			"evict": func(c Cache[string, resource], clock *MockClock) {
+				clock.Advance(2 * time.Minute) // makes "a" the victim of MCache too
+				c.Set("b", resource{new(bool)})
+				c.Set("c", resource{new(bool)})
+			},
+			"expire": func(c Cache[string, resource], clock *MockClock) {
+				clock.Advance(2 * time.Minute)
+				c.Get("a")
+			},
+			"purge": func(c Cache[string, resource], clock *MockClock) { c.Purge() },
+			"close": func(c Cache[string, resource], clock *MockClock) { c.Close() },
+		}
+
+		for path, remove := range paths {
+			clock := NewMockClock()
+			c := newCache(clock)
+			removed := false
+			c.SetWithTimeout("a", resource{&removed}, time.Minute)
+			if removed {
+				t.Fatalf("%s: expected OnRemoved not to be called on Set", name)
+			}
+
+			remove(c, clock)
+			if !removed {
+				t.Errorf("%s: expected OnRemoved to be called on %s", name, path)
+			}
+			c.Close()
+		}
+	}
+}
+
+func TestCache_PurgeNotifiesEveryEntry(t *testing.T) {
+	caches := newCaches[string, resource](10)
+
+	for name, c := range caches {
+		removed := make([]bool, 5)
+		for i := range removed {
+			c.Set(fmt.Sprint(i), resource{&removed[i]})
+		}
+
+		c.Purge()
+		for i, ok := range removed {
+			if !ok {
+				t.Errorf("%s: expected OnRemoved to be called for entry %d on Purge", name, i)
+			}
+		}
+		if c.Len() != 0 {
+			t.Errorf("%s: expected an empty cache after Purge, got %d entries", name, c.Len())
+		}
+	}
+}
+
+func TestCache_Flush(t *testing.T) {
+	events := make(chan Event[string, resource], 10)
+	caches := map[string]interface {
+		Cache[string, resource]
+		Flush()
+	}{
+		"LRU":    NewLRU(10, WithEventChannel(events)),
+		"LFU":    NewLFU[string, resource](10),
+		"MCache": NewManual[string, resource](10, 0),
+	}
+
+	for name, c := range caches {
+		removed := make([]bool, 5)
+		for i := range removed {
+			c.Set(fmt.Sprint(i), resource{&removed[i]})
+		}
+
+		c.Flush()
+		for i, ok := range removed {
+			if !ok {
+				t.Errorf("%s: expected OnRemoved to be called for entry %d on Flush", name, i)
+			}
+		}
+		if c.Len() != 0 {
+			t.Errorf("%s: expected an empty cache after Flush, got %d entries", name, c.Len())
+		}
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("LRU: expected an event for every flushed entry, got %d", len(events))
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Reason != ReasonPurged {
+			t.Errorf("LRU: expected flushed entries to be reported with ReasonPurged, got %v", e.Reason)
+		}
+	}
+}
+
+func TestCache_ExpirableTransfer(t *testing.T) {
+	removed := false
+	src := NewLRU[string, resource](10)
+	dst := NewLRU[string, resource](10)
+	src.Set("a", resource{&removed})
+
+	src.TransferTo(dst)
+	if removed {
+		t.Errorf("Expected OnRemoved not to be called for values moved to another cache")
+	}
+	dst.Delete("a")
+	if !removed {
+		t.Errorf("Expected OnRemoved to be called when the value is deleted from the destination")
+	}
+}
+
+func TestCache_WithApproximateCount(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock), WithApproximateCount[string, int]())
+	caches["SLRU"] = NewSLRU(10, WithClock[string, int](clock), WithApproximateCount[string, int]())
+
+	for name, c := range caches {
+		for i := 0; i < 1000; i++ {
+			k := fmt.Sprint(i % 37)
+			switch {
+			case i%5 == 0:
+				c.Delete(k)
+			case i%3 == 0:
+				c.SetWithTimeout(k, i, time.Minute)
+			default:
+				c.Set(k, i)
+			}
+			if got, want := c.Count(), len(c.GetAll()); got != want {
+				t.Fatalf("%s: after %d operations expected Count %d, got %d", name, i+1, want, got)
+			}
+		}
+	}
+
+	clock.Advance(2 * time.Minute)
+	for name, c := range caches {
+		for i := 0; i < 37; i++ {
+			c.Get(fmt.Sprint(i)) // removes the expired entries
+		}
+		if got, want := c.Count(), len(c.GetAll()); got != want {
+			t.Errorf("%s: expected Count %d once expired entries were observed, got %d", name, want, got)
+		}
+	}
+}
+
+func TestCache_GetAllSorted(t *testing.T) {
+	type sortedGetter interface {
+		GetAllSorted(less func(a, b string) bool) []Entry[string, int]
+	}
+
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for _, c := range caches {
+		c.Set("c", 3)
+		c.Set("a", 1)
+		c.SetWithTimeout("expired", 0, time.Second)
+		c.Set("b", 2)
+	}
+	clock.Advance(2 * time.Second)
+
+	byKey := func(a, b string) bool { return a < b }
+	want := []Entry[string, int]{{"a", 1}, {"b", 2}, {"c", 3}}
+	for name, c := range caches {
+		for i := 0; i < 5; i++ {
+			if got := c.(sortedGetter).GetAllSorted(byKey); !slices.Equal(got, want) {
+				t.Fatalf("%s: expected %v, got %v", name, want, got)
+			}
+		}
+		got := c.(sortedGetter).GetAllSorted(func(a, b string) bool { return a > b })
+		if !slices.Equal(got, []Entry[string, int]{{"c", 3}, {"b", 2}, {"a", 1}}) {
+			t.Errorf("%s: expected descending order, got %v", name, got)
+		}
+	}
+}
+
+func TestCache_WithExpiryGrace(t *testing.T) {
+	clock := NewMockClock()
+	opts := []Option[string, int]{WithClock[string, int](clock), WithExpiryGrace[string, int](10 * time.Second)}
+	caches := newCaches(10, opts...)
+	caches["SLRU"] = NewSLRU(10, opts...)
+
+	for _, c := range caches {
+		c.SetWithTimeout("a", 1, time.Minute)
+		c.SetWithTimeout("b", 2, time.Minute)
+		c.Set("forever", 3)
+	}
+
+	clock.Advance(45 * time.Second)
+	for name, c := range caches {
+		if v, ok := c.Get("a"); !ok || v != 1 {
+			t.Errorf("%s: expected a to be live before the grace period, got %v, %v", name, v, ok)
+		}
+	}
+
+	// 55s is before the nominal TTL of a minute but within the grace period
+	clock.Advance(10 * time.Second)
+	for name, c := range caches {
+		if _, ok := c.Get("a"); ok {
+			t.Errorf("%s: expected a to be expired within the grace period", name)
+		}
+		if c.Count() != 1 || !slices.Equal(c.Keys(), []string{"forever"}) {
+			t.Errorf("%s: expected only forever to be live, got %v", name, c.Keys())
+		}
+		if _, ok := c.GetAll()["b"]; ok {
+			t.Errorf("%s: expected b to be expired within the grace period", name)
+		}
+	}
+}
+
+func TestCache_HasAndTouch(t *testing.T) {
+	type toucher interface {
+		HasAndTouch(k string) bool
+	}
+
+	clock := NewMockClock()
+	caches := map[string]Cache[string, int]{
+		"LRU":    NewLRU(2, WithClock[string, int](clock)),
+		"LFU":    NewLFU(2, WithClock[string, int](clock)),
+		"MCache": NewManual(10, 0, WithClock[string, int](clock)),
+	}
+
+	for _, c := range caches {
+		c.Set("a", 1)
+		c.SetWithTimeout("b", 2, time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	for name, c := range caches {
+		tc := c.(toucher)
+		if !tc.HasAndTouch("a") {
+			t.Errorf("%s: expected a to be present", name)
+		}
+		if tc.HasAndTouch("b") {
+			t.Errorf("%s: expected expired b to be absent", name)
+		}
+		if tc.HasAndTouch("missing") {
+			t.Errorf("%s: expected missing key to be absent", name)
+		}
+		if s := c.Stats(); s.Hits != 1 || s.Misses != 2 {
+			t.Errorf("%s: expected 1 hit and 2 misses, got %+v", name, s)
+		}
+	}
+
+	// a was touched after c was set, so c is evicted to make room for d.
+	for _, name := range []string{"LRU", "LFU"} {
+		c := caches[name]
+		c.Set("c", 3)
+		c.(toucher).HasAndTouch("a")
+		c.Set("d", 4)
+		if _, ok := c.Get("a"); !ok {
+			t.Errorf("%s: expected touched a to survive eviction", name)
+		}
+		if _, ok := c.Get("c"); ok {
+			t.Errorf("%s: expected c to be evicted", name)
+		}
+	}
+}
+
+func TestCache_WithMinTTL(t *testing.T) {
+	clock := NewMockClock()
+	opts := []Option[string, int]{WithClock[string, int](clock), WithMinTTL[string, int](time.Second)}
+	rejectOpts := append(opts, WithRejectShortTTL[string, int]())
+	caches := newCaches(10, opts...)
+	rejecting := map[string]Cache[string, int]{
+		"LRU":    NewLRU(10, rejectOpts...),
+		"LFU":    NewLFU(10, rejectOpts...),
+		"MCache": NewManual(10, 0, rejectOpts...),
+	}
+
+	for name, c := range caches {
+		c.SetWithTimeout("short", 1, time.Millisecond)
+		c.SetWithTimeout("long", 2, time.Minute)
+		c.SetWithTimeout("forever", 3, 0)
+		if !c.NotFoundSetWithTimeout("added", 4, time.Millisecond) {
+			t.Errorf("%s: expected NotFoundSetWithTimeout to add the key", name)
+		}
+	}
+	for name, c := range rejecting {
+		c.SetWithTimeout("short", 1, time.Millisecond)
+		c.SetWithTimeout("long", 2, time.Minute)
+		if c.NotFoundSetWithTimeout("added", 4, time.Millisecond) {
+			t.Errorf("%s: expected NotFoundSetWithTimeout to reject the short TTL", name)
+		}
+		if _, ok := c.Get("short"); ok {
+			t.Errorf("%s: expected the write with a short TTL to be rejected", name)
+		}
+		if _, ok := c.Get("long"); !ok {
+			t.Errorf("%s: expected the write with a long TTL to be stored", name)
+		}
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	for name, c := range caches {
+		for _, k := range []string{"short", "added"} {
+			e, ok := c.GetWithExpiration(k)
+			if !ok {
+				t.Fatalf("%s: expected %s to outlive its 1ms TTL", name, k)
+			}
+			if want := clock.Now().Add(500 * time.Millisecond); !e.ExpireAt.Equal(want) {
+				t.Errorf("%s: expected %s to expire at %v, got %v", name, k, want, e.ExpireAt)
+			}
+		}
+	}
+
+	clock.Advance(time.Second)
+	for name, c := range caches {
+		if got := c.Keys(); len(got) != 2 || !slices.Contains(got, "long") || !slices.Contains(got, "forever") {
+			t.Errorf("%s: expected only long and forever to be live once the minimum TTL elapsed, got %v", name, got)
+		}
+	}
+}
+
+func TestCache_Rename(t *testing.T) {
+	type renamer interface {
+		Rename(oldKey, newKey string) bool
+	}
+
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for name, c := range caches {
+		rc := c.(renamer)
+		c.SetWithTimeout("old", 1, time.Minute)
+		c.Set("taken", 2)
+		c.SetWithTimeout("expired", 3, time.Second)
+		clock.Advance(2 * time.Second)
+
+		if !rc.Rename("old", "taken") {
+			t.Fatalf("%s: expected old to be renamed", name)
+		}
+		if _, ok := c.Get("old"); ok {
+			t.Errorf("%s: expected old to be gone after the rename", name)
+		}
+		e, ok := c.GetWithExpiration("taken")
+		if !ok || e.Value != 1 {
+			t.Errorf("%s: expected taken to be overwritten with 1, got %v, %v", name, e.Value, ok)
+		}
+		if want := clock.Now().Add(58 * time.Second); !e.ExpireAt.Equal(want) {
+			t.Errorf("%s: expected the TTL to survive the rename, got %v, want %v", name, e.ExpireAt, want)
+		}
+		if c.Count() != 1 {
+			t.Errorf("%s: expected 1 entry, got %d", name, c.Count())
+		}
+
+		if rc.Rename("expired", "new") || rc.Rename("missing", "new") {
+			t.Errorf("%s: expected expired and missing keys not to be renamed", name)
+		}
+		if _, ok := c.Get("new"); ok {
+			t.Errorf("%s: expected new to be missing", name)
+		}
+		if !rc.Rename("taken", "taken") {
+			t.Errorf("%s: expected a rename to the same key to succeed", name)
+		}
+		clock.Advance(time.Minute)
+	}
+}
+
+func TestCache_WithEagerScanCleanup(t *testing.T) {
+	clock := NewMockClock()
+	opts := []Option[string, int]{WithClock[string, int](clock), WithEagerScanCleanup[string, int]()}
+	caches := newCaches(10, opts...)
+
+	for name, c := range caches {
+		c.SetWithTimeout("a", 1, time.Second)
+		c.SetWithTimeout("b", 2, time.Second)
+		c.SetWithTimeout("c", 3, time.Minute)
+		c.Set("d", 4)
+		clock.Advance(2 * time.Second)
+
+		if got := c.GetAll(); len(got) != 2 {
+			t.Errorf("%s: expected 2 live entries, got %v", name, got)
+		}
+		if c.Len() != 2 {
+			t.Errorf("%s: expected GetAll to remove the expired entries, got Len=%d", name, c.Len())
+		}
+
+		c.SetWithTimeout("e", 5, time.Second)
+		clock.Advance(2 * time.Second)
+		if n := len(c.Keys()); n != 2 || c.Len() != 2 {
+			t.Errorf("%s: expected Keys to remove the expired entry, got %d keys and Len=%d", name, n, c.Len())
+		}
+
+		c.SetWithTimeout("f", 6, time.Second)
+		clock.Advance(2 * time.Second)
+		if n := c.Count(); n != 2 || c.Len() != 2 {
+			t.Errorf("%s: expected Count to remove the expired entries, got %d and Len=%d", name, n, c.Len())
+		}
+		c.Close()
+	}
+
+	lazy := NewLRU(10, WithClock[string, int](clock))
+	defer lazy.Close()
+	lazy.SetWithTimeout("a", 1, time.Second)
+	clock.Advance(2 * time.Second)
+	lazy.GetAll()
+	if lazy.Len() != 1 {
+		t.Errorf("Expected GetAll to leave expired entries without the option, got Len=%d", lazy.Len())
+	}
+}
+
+func TestCache_GetSet(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	type getSetter interface {
+		GetSet(k string, v int, ttl time.Duration) (int, bool)
+	}
+
+	for name, c := range caches {
+		gs := c.(getSetter)
+		if old, existed := gs.GetSet("a", 1, time.Minute); existed || old != 0 {
+			t.Errorf("%s: expected no previous value, got %d, %v", name, old, existed)
+		}
+		if old, existed := gs.GetSet("a", 2, time.Second); !existed || old != 1 {
+			t.Errorf("%s: expected the previous value 1, got %d, %v", name, old, existed)
+		}
+		if v, ok := c.Get("a"); !ok || v != 2 {
+			t.Errorf("%s: expected the new value 2, got %d, %v", name, v, ok)
+		}
+
+		clock.Advance(2 * time.Second)
+		if _, ok := c.Get("a"); ok {
+			t.Errorf("%s: expected the new TTL to apply", name)
+		}
+		if old, existed := gs.GetSet("a", 3, 0); existed || old != 0 {
+			t.Errorf("%s: expected the expired value not to be returned, got %d, %v", name, old, existed)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_TTLStats(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	type ttlStater interface {
+		TTLStats() (permanent, expiring, expired int)
+	}
+
+	for name, c := range caches {
+		c.Set("p1", 1)
+		c.Set("p2", 2)
+		c.SetWithTimeout("e1", 3, time.Second)
+		c.SetWithTimeout("e2", 4, time.Second)
+		c.SetWithTimeout("e3", 5, time.Second)
+		c.SetWithTimeout("l1", 6, time.Hour)
+		clock.Advance(2 * time.Second)
+
+		permanent, expiring, expired := c.(ttlStater).TTLStats()
+		if permanent != 2 || expiring != 1 || expired != 3 {
+			t.Errorf("%s: expected 2 permanent, 1 expiring and 3 expired entries, got %d, %d, %d",
+				name, permanent, expiring, expired)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_Range(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+
+	for name, c := range caches {
+		r := c.(ranger[string, int])
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.SetWithTimeout("expired", 3, time.Second)
+		clock.Advance(2 * time.Second)
+
+		seen := map[string]int{}
+		r.Range(func(k string, v int) bool {
+			seen[k] = v
+			return true
+		})
+		if len(seen) != 2 || seen["a"] != 1 || seen["b"] != 2 {
+			t.Errorf("%s: expected the live entries, got %v", name, seen)
+		}
+
+		calls := 0
+		r.Range(func(string, int) bool {
+			calls++
+			return false
+		})
+		if calls != 1 {
+			t.Errorf("%s: expected Range to stop when f returns false, got %d calls", name, calls)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_GetOrDefault(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	caches["SLRU"] = NewSLRU(10, WithClock[string, int](clock))
+	caches["Sharded"] = NewSharded(2, func() Cache[string, int] { return NewLRU(10, WithClock[string, int](clock)) })
+
+	for name, c := range caches {
+		c.Set("a", 1)
+		c.SetWithTimeout("b", 2, time.Second)
+		clock.Advance(2 * time.Second)
+
+		if v := c.GetOrDefault("a", -1); v != 1 {
+			t.Errorf("%s: expected the stored value 1, got %d", name, v)
+		}
+		if v := c.GetOrDefault("missing", -1); v != -1 {
+			t.Errorf("%s: expected the default for a missing key, got %d", name, v)
+		}
+		if v := c.GetOrDefault("b", -1); v != -1 {
+			t.Errorf("%s: expected the default for an expired key, got %d", name, v)
+		}
+		if s := c.Stats(); s.Hits != 1 || s.Misses != 2 {
+			t.Errorf("%s: expected 1 hit and 2 misses, got %+v", name, s)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_GetAllInto(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	type intoGetter interface {
+		GetAllInto(dst map[string]int)
+	}
+
+	for name, c := range caches {
+		c.Set("a", 1)
+		c.SetWithTimeout("b", 2, time.Second)
+		clock.Advance(2 * time.Second)
+
+		dst := map[string]int{"stale": 3}
+		c.(intoGetter).GetAllInto(dst)
+		if len(dst) != 1 || dst["a"] != 1 {
+			t.Errorf("%s: expected only the live entry a, got %v", name, dst)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_RemovedValuesAreCollectable(t *testing.T) {
+	type payload struct{ data [1 << 10]byte }
+	collected := make(chan string, 16)
+	// newValue returns a value that reports its name on collected once it has been garbage collected.
+	newValue := func(name string) *payload {
+		p := &payload{}
+		runtime.SetFinalizer(p, func(*payload) { collected <- name })
+		return p
+	}
+	waitCollected := func(want ...string) []string {
+		pending := make(map[string]bool)
+		for _, name := range want {
+			pending[name] = true
+		}
+		for i := 0; i < 20 && len(pending) > 0; i++ {
+			runtime.GC()
+			select {
+			case name := <-collected:
+				delete(pending, name)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		return slices.Sorted(maps.Keys(pending))
+	}
+
+	caches := newCaches[string, *payload](2)
+	caches["SLRU"] = NewSLRU[string, *payload](2)
+	for name, c := range caches {
+		c.Set("deleted", newValue("deleted"))
+		c.Delete("deleted")
+		c.Set("evicted", newValue("evicted"))
+		c.Set("b", &payload{})
+		c.Set("c", &payload{}) // evicts evicted
+		c.Set("purged", newValue("purged"))
+		c.Purge()
+		c.Set("d", &payload{}) // keeps the cache in use
+
+		if pending := waitCollected("deleted", "evicted", "purged"); len(pending) > 0 {
+			t.Errorf("%s: expected the removed values to be garbage collected, still reachable: %v", name, pending)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	type ttler interface {
+		TTL(k string) (time.Duration, bool)
+	}
+
+	for name, c := range caches {
+		c.SetWithTimeout("a", 1, time.Minute)
+		c.Set("forever", 2)
+		c.SetWithTimeout("expired", 3, time.Second)
+		clock.Advance(2 * time.Second)
+
+		tc := c.(ttler)
+		if ttl, ok := tc.TTL("a"); !ok || ttl != 58*time.Second {
+			t.Errorf("%s: expected a TTL of 58s, got %v, %v", name, ttl, ok)
+		}
+		if ttl, ok := tc.TTL("forever"); !ok || ttl != NoExpiry {
+			t.Errorf("%s: expected NoExpiry, got %v, %v", name, ttl, ok)
+		}
+		if _, ok := tc.TTL("expired"); ok {
+			t.Errorf("%s: expected an expired key to be reported as absent", name)
+		}
+		if _, ok := tc.TTL("missing"); ok {
+			t.Errorf("%s: expected a missing key to be reported as absent", name)
+		}
+		if s := c.Stats(); s.Hits != 0 || s.Misses != 0 {
+			t.Errorf("%s: expected TTL not to count as a lookup, got %+v", name, s)
+		}
+		c.Close()
+	}
+}
+
+func TestCache_PauseResume(t *testing.T) {
+	interval := 2 * time.Millisecond
+	caches := map[string]Cache[string, int]{
+		"LRU":    NewLRU(10, WithCleanupInterval[string, int](interval)),
+		"LFU":    NewLFU(10, WithCleanupInterval[string, int](interval), WithStartPaused[string, int]()),
+		"MCache": NewManual[string, int](10, interval),
+	}
+	type pauser interface {
+		Pause()
+		Resume()
+	}
+
+	for name, c := range caches {
+		p := c.(pauser)
+		if name != "LFU" { // created with WithStartPaused
+			p.Pause()
+		}
+		c.SetWithTimeout("a", 1, time.Millisecond)
+		c.SetWithTimeout("b", 2, time.Millisecond)
+		time.Sleep(10 * interval)
+		if c.Len() != 2 {
+			t.Errorf("%s: expected the paused sweeper to keep the expired entries, got Len=%d", name, c.Len())
+		}
+		if _, ok := c.Get("a"); ok {
+			t.Errorf("%s: expected Get to treat an expired entry as absent while paused", name)
+		}
+
+		p.Resume()
+		deadline := time.Now().Add(time.Second)
+		for c.Len() != 0 && time.Now().Before(deadline) {
+			time.Sleep(interval)
+		}
+		if c.Len() != 0 {
+			t.Errorf("%s: expected the resumed sweeper to remove the expired entries, got Len=%d", name, c.Len())
+		}
+		c.Close()
+	}
+}
+
+func TestCache_GetManyPartial(t *testing.T) {
+	clock := NewMockClock()
+	caches := newCaches(10, WithClock[string, int](clock))
+	type partialGetter interface {
+		GetManyPartial(keys []string) (map[string]int, []string)
+	}
+
+	for name, c := range caches {
+		c.Set("a", 1)
+		c.SetWithTimeout("b", 2, time.Minute)
+		c.SetWithTimeout("expired", 3, time.Second)
+		clock.Advance(2 * time.Second)
+
+		found, missing := c.(partialGetter).GetManyPartial([]string{"missing", "a", "expired", "b"})
+		if !maps.Equal(found, map[string]int{"a": 1, "b": 2}) {
+			t.Errorf("%s: expected a and b to be found, got %v", name, found)
+		}
+		if !slices.Equal(missing, []string{"missing", "expired"}) {
+			t.Errorf("%s: expected [missing expired] in order, got %v", name, missing)
+		}
+		if s := c.Stats(); s.Hits != 2 || s.Misses != 2 {
+			t.Errorf("%s: expected 2 hits and 2 misses, got %+v", name, s)
+		}
+		c.Close()
+	}
+}
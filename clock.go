@@ -0,0 +1,52 @@
+package incache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to a cache.
+// It allows expiration to be tested deterministically without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// MockClock is a Clock whose time only moves when Advance or Set is called.
+// It is safe for concurrent use.
+type MockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewMockClock creates a new MockClock set to the current time.
+func NewMockClock() *MockClock {
+	return &MockClock{now: time.Now()}
+}
+
+// Now returns the current time of the clock.
+func (m *MockClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.now
+}
+
+// Advance moves the clock forward by d.
+func (m *MockClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = m.now.Add(d)
+}
+
+// Set sets the clock to t.
+func (m *MockClock) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = t
+}
@@ -0,0 +1,25 @@
+package incache
+
+import "errors"
+
+// ErrCacheClosed is returned when an operation is attempted on a cache that has been closed.
+var ErrCacheClosed = errors.New("incache: cache is closed")
+
+// ErrValueTooLarge is returned when a value exceeds the maximum value size configured with WithMaxValueSize.
+var ErrValueTooLarge = errors.New("incache: value is too large")
+
+// ErrTombstoned is returned when a key cannot be written because it was deleted with DeleteWithTombstone
+// and its tombstone has not expired yet.
+var ErrTombstoned = errors.New("incache: key is tombstoned")
+
+// ErrNotFound is returned by LoadingCache.GetOrLoad when a key does not exist. A Loader signals that a key
+// does not exist by returning an error wrapping ErrNotFound.
+var ErrNotFound = errors.New("incache: key not found")
+
+// ErrCacheFull is returned when a new key cannot be added because the cache is at capacity
+// and the operation does not evict other entries to make room.
+var ErrCacheFull = errors.New("incache: cache is full")
+
+// ErrPanicked is returned by Group.Do, and the loads built on it, to the callers that waited for a function
+// that panicked. The caller that executed the function gets the panic instead.
+var ErrPanicked = errors.New("incache: function panicked")
@@ -0,0 +1,36 @@
+package incache
+
+// RemovalReason describes why an entry was removed from a cache.
+type RemovalReason int
+
+const (
+	// ReasonDeleted means the entry was removed explicitly, e.g. by Delete or Purge.
+	ReasonDeleted RemovalReason = iota
+	// ReasonEvicted means the entry was evicted to make room for other entries.
+	ReasonEvicted
+	// ReasonExpired means the entry was removed because it had expired.
+	ReasonExpired
+	// ReasonPurged means the entry was removed by Flush, which clears the whole cache.
+	ReasonPurged
+)
+
+// String returns the name of the reason, e.g. "evicted".
+func (r RemovalReason) String() string {
+	switch r {
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonExpired:
+		return "expired"
+	case ReasonPurged:
+		return "purged"
+	default:
+		return "deleted"
+	}
+}
+
+// Event is sent on the channel configured with WithEventChannel whenever an entry is removed from the cache.
+type Event[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Reason RemovalReason
+}
@@ -0,0 +1,40 @@
+package incache
+
+// keyRing holds the last keys added to it, up to a fixed number, overwriting the oldest key once it is full.
+// It records the keys evicted from an LRUCache with WithEvictionHistory.
+type keyRing[K comparable] struct {
+	keys []K
+	next int // index of the slot written by the next add
+	full bool
+}
+
+// newKeyRing creates a ring holding up to n keys, or returns nil if n is not positive.
+func newKeyRing[K comparable](n int) *keyRing[K] {
+	if n <= 0 {
+		return nil
+	}
+	return &keyRing[K]{keys: make([]K, n)}
+}
+
+// add records a key, overwriting the oldest one if the ring is full.
+func (r *keyRing[K]) add(k K) {
+	r.keys[r.next] = k
+	r.next++
+	if r.next == len(r.keys) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// recent returns the recorded keys, the most recently added first.
+func (r *keyRing[K]) recent() []K {
+	n := r.next
+	if r.full {
+		n = len(r.keys)
+	}
+	keys := make([]K, 0, n)
+	for j := 1; j <= n; j++ {
+		keys = append(keys, r.keys[(r.next-j+len(r.keys))%len(r.keys)])
+	}
+	return keys
+}
@@ -0,0 +1,109 @@
+package incache
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// globalSweeper is the shared background sweeper set with SetGlobalSweeper.
+var globalSweeper sharedSweeper
+
+// sharedSweeper removes the expired entries of all registered caches from a single goroutine.
+type sharedSweeper struct {
+	mu       sync.Mutex
+	interval time.Duration
+	sweeps   []registeredSweep
+	stopCh   chan struct{} // closed to stop the goroutine of the current interval
+}
+
+// registeredSweep is the sweep of a cache registered with the shared sweeper until its stopCh is closed.
+type registeredSweep struct {
+	stopCh  <-chan struct{}
+	sweeper *sweeper // only consulted for Pause and Resume
+	sweep   func() (scanned, removed int)
+}
+
+// SetGlobalSweeper makes the caches created from now on remove their expired entries from a single background
+// goroutine shared by all of them, every interval, instead of starting a sweeper goroutine per cache.
+// Only caches with a cleanup interval, set with WithCleanupInterval or the interval of NewManual, are registered;
+// that interval, and WithAdaptiveCleanup, are then ignored. Caches without one are not swept in the background.
+// Caches are registered when they are created and dropped once they are closed: the shared sweeper keeps
+// a registered cache reachable, so it must be closed with Close once it is no longer used.
+// Calling it again changes the interval for all registered caches. An interval of zero or less stops
+// the shared goroutine: caches created afterwards start their own sweepers again, while the registered
+// caches are not swept in the background until SetGlobalSweeper is called with a positive interval.
+func SetGlobalSweeper(interval time.Duration) {
+	g := &globalSweeper
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stopCh != nil {
+		close(g.stopCh)
+		g.stopCh = nil
+	}
+	g.interval = interval
+	g.pruneClosed()
+	if interval > 0 {
+		g.stopCh = make(chan struct{})
+		go g.run(interval, g.stopCh)
+	}
+}
+
+// registerGlobalSweep registers the sweep of a cache with the global sweeper, until stopCh is closed.
+// It returns false, registering nothing, if SetGlobalSweeper is not in effect or the cache has no cleanup interval.
+func registerGlobalSweep(stopCh <-chan struct{}, s *sweeper, sweep func() (scanned, removed int)) bool {
+	if s.currentInterval() <= 0 {
+		return false
+	}
+
+	g := &globalSweeper
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.interval <= 0 {
+		return false
+	}
+	g.sweeps = append(g.sweeps, registeredSweep{stopCh: stopCh, sweeper: s, sweep: sweep})
+	return true
+}
+
+// run sweeps the registered caches every interval until stopCh is closed.
+func (g *sharedSweeper) run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range g.registered() {
+				if !s.sweeper.paused.Load() {
+					s.sweep()
+				}
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// registered drops the sweeps of closed caches and returns the others. The caches are swept
+// without holding the lock, so that creating a cache never waits for a sweep.
+func (g *sharedSweeper) registered() []registeredSweep {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pruneClosed()
+	return slices.Clone(g.sweeps)
+}
+
+// pruneClosed drops the sweeps of closed caches. The caller must hold g.mu.
+func (g *sharedSweeper) pruneClosed() {
+	g.sweeps = slices.DeleteFunc(g.sweeps, func(s registeredSweep) bool {
+		select {
+		case <-s.stopCh:
+			return true
+		default:
+			return false
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package incache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Group deduplicates concurrent calls for the same key, so that a function is only executed once
+// per key at a time while concurrent callers wait for and share its result.
+// It can wrap any data source, independently of a cache.
+// The zero value of Group is ready to use.
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*groupCall[V]
+}
+
+type groupCall[V any] struct {
+	wg    sync.WaitGroup
+	value V
+	err   error
+	dups  int // number of callers waiting for the result
+}
+
+// Do executes fn for the key and returns its result.
+// If a call for the same key is already in flight, Do waits for it and returns its result instead of calling fn.
+// The shared result is true if the result was given to more than one caller.
+// If fn panics, the panic is propagated to the caller that executed fn, while the callers waiting for it
+// get an error wrapping ErrPanicked. Later calls for the key execute fn again.
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err, true
+	}
+	call := g.register(key)
+	g.mu.Unlock()
+
+	panicked := call.run(fn)
+	shared = g.complete(key, call)
+	if panicked != nil {
+		panic(panicked)
+	}
+	return call.value, call.err, shared
+}
+
+// goDo executes fn for the key in a new goroutine, unless a call for the key is already in flight,
+// and reports whether it did. Callers of Do for the key wait for it like for any other call.
+// A panic of fn is recovered; the waiting callers get an error wrapping ErrPanicked.
+func (g *Group[K, V]) goDo(key K, fn func() (V, error)) bool {
+	g.mu.Lock()
+	if _, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return false
+	}
+	call := g.register(key)
+	g.mu.Unlock()
+
+	go func() {
+		call.run(fn)
+		g.complete(key, call)
+	}()
+	return true
+}
+
+// register registers a new call for the key. The caller must hold g.mu.
+func (g *Group[K, V]) register(key K) *groupCall[V] {
+	if g.calls == nil {
+		g.calls = make(map[K]*groupCall[V])
+	}
+	call := &groupCall[V]{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	return call
+}
+
+// complete unregisters the finished call for the key, releases its waiters and reports whether there were any.
+func (g *Group[K, V]) complete(key K, call *groupCall[V]) (shared bool) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	shared = call.dups > 0
+	g.mu.Unlock()
+	call.wg.Done()
+	return shared
+}
+
+// run calls fn and stores its result. If fn panics, it stores an error wrapping ErrPanicked for the waiting callers
+// and returns the recovered value, so that the call can be completed before the panic is propagated.
+func (call *groupCall[V]) run(fn func() (V, error)) (panicked any) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = r
+			call.err = fmt.Errorf("%w: %v", ErrPanicked, r)
+		}
+	}()
+	call.value, call.err = fn()
+	return nil
+}
@@ -0,0 +1,123 @@
+package incache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[string, int]
+
+	v, err, shared := g.Do("key", func() (int, error) { return 1, nil })
+	if v != 1 || err != nil || shared {
+		t.Errorf("Expected (1, nil, false), got (%v, %v, %v)", v, err, shared)
+	}
+
+	wantErr := errors.New("boom")
+	if _, err, _ := g.Do("key", func() (int, error) { return 0, wantErr }); err != wantErr {
+		t.Errorf("Expected the error of fn, got %v", err)
+	}
+}
+
+func TestGroup_DoDeduplicates(t *testing.T) {
+	var g Group[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	const n = 50
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			v, err, shared := g.Do("key", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if v != 42 || err != nil {
+				t.Errorf("Expected (42, nil), got (%v, %v)", v, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+
+	// Wait until all other callers have joined the in-flight call
+	for {
+		g.mu.Lock()
+		call := g.calls["key"]
+		joined := call != nil && call.dups == n-1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected fn to run exactly once, ran %d times", calls.Load())
+	}
+	if sharedCount.Load() != n {
+		t.Errorf("Expected all %d callers to report a shared result, got %d", n, sharedCount.Load())
+	}
+}
+
+func TestGroup_DoPanic(t *testing.T) {
+	var g Group[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	waiterErr := make(chan error, 1)
+	go func() {
+		defer func() { recover() }() // the leader's panic
+		g.Do("key", func() (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	go func() {
+		_, err, _ := g.Do("key", func() (int, error) { return 0, nil })
+		waiterErr <- err
+	}()
+	for {
+		g.mu.Lock()
+		joined := g.calls["key"] != nil && g.calls["key"].dups == 1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case err := <-waiterErr:
+		if !errors.Is(err, ErrPanicked) {
+			t.Errorf("Expected the waiter to get ErrPanicked, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the waiter not to block after the panic")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom again" {
+				t.Errorf("Expected the panic to be propagated to the caller, got %v", r)
+			}
+		}()
+		g.Do("key", func() (int, error) { panic("boom again") })
+	}()
+	if v, err, _ := g.Do("key", func() (int, error) { return 1, nil }); v != 1 || err != nil {
+		t.Errorf("Expected later calls to run fn again, got %v, %v", v, err)
+	}
+}
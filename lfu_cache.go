@@ -1,7 +1,13 @@
 package incache
 
 import (
+	"cmp"
+	"container/heap"
 	"container/list"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -9,55 +15,225 @@ import (
 // LFUCache implements a Least Frequently Used cache with O(1) operations.
 // It uses frequency buckets to efficiently track and evict items.
 type LFUCache[K comparable, V any] struct {
-	mu        sync.Mutex
-	size      uint
-	minFreq   uint
-	items     map[K]*list.Element // key → list element containing lfuItem
-	freqLists map[uint]*list.List // frequency → list of items with that frequency
+	mu          sync.Mutex
+	size        uint
+	minFreq     uint
+	items       map[K]*list.Element // key → list element containing lfuItem
+	freqLists   map[uint]*list.List // frequency → list of items with that frequency
+	stopCh      chan struct{}       // Channel to signal the expiration goroutine to stop
+	sweeper     *sweeper
+	closed      bool
+	fifo        map[uint]*seqHeap[K, V] // frequency → items of that frequency by insertion order, only with FIFOWithinBucket
+	stats       Stats
+	lastEvicted K      // key of the most recently evicted item, reported by SetReport
+	inserted    uint64 // number of items inserted so far, used to order items with FIFOWithinBucket
+	bulkLoading bool   // set by BulkLoad while eviction is deferred
+	opts        options[K, V]
 }
 
 type lfuItem[K comparable, V any] struct {
 	key      K
 	value    V
 	freq     uint
-	expireAt int64 // Unix nano timestamp, 0 means no expiration
+	expireAt int64  // Unix nano timestamp, 0 means no expiration
+	seq      uint64 // insertion order of the item, starting at 1
+	accesses uint   // number of accesses since insertion, counted up to the promotion threshold
+	index    int    // position of the item in the seqHeap of its frequency, only with FIFOWithinBucket
 }
 
 // NewLFU creates a new LFU cache with the specified maximum size.
-// If size is 0, the cache will not store any items.
-func NewLFU[K comparable, V any](size uint) *LFUCache[K, V] {
-	return &LFUCache[K, V]{
-		size:      size,
+// If size is 0, the cache will not store any items unless WithUnbounded is used; with WithStrictSizing, it panics instead.
+// If a cleanup interval is configured, a background goroutine removes expired keys until Close is called.
+func NewLFU[K comparable, V any](size uint, opts ...Option[K, V]) *LFUCache[K, V] {
+	o := applyOptions(opts)
+	l := &LFUCache[K, V]{
+		size:      o.capacity(size),
 		minFreq:   0,
-		items:     make(map[K]*list.Element),
+		items:     make(map[K]*list.Element, o.initialCapacity),
 		freqLists: make(map[uint]*list.List),
+		stopCh:    make(chan struct{}),
+		opts:      o,
 	}
+	l.sweeper = l.opts.newSweeper(0)
+	if !registerGlobalSweep(l.stopCh, l.sweeper, l.sweep) && l.sweeper.currentInterval() > 0 {
+		go l.sweeper.run(l.stopCh, l.sweep)
+	}
+	l.opts.startReporter(l.stopCh, l.Stats)
+	l.opts.startPressureMonitor(l.stopCh, l.shed)
+	return l
+}
+
+// NewLFUFrom creates a new LFU cache with the specified maximum size, filled with the entries of seed.
+// The internal map is presized for the seed entries unless WithInitialCapacity is given.
+// If the seed holds more than size entries, the cache keeps size of them; since map iteration order
+// is unspecified, which entries are kept is arbitrary.
+func NewLFUFrom[K comparable, V any](size uint, seed map[K]V, opts ...Option[K, V]) *LFUCache[K, V] {
+	l := NewLFU(size, withSeedCapacity(opts, len(seed), size)...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, v := range seed {
+		l.set(k, v, 0)
+	}
+	return l
 }
 
 // Set adds the key-value pair to the cache.
 func (l *LFUCache[K, V]) Set(key K, value V) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.opts.operationHook != nil {
+		defer l.opts.observeOperation("Set", time.Now())
+	}
+
+	l.set(key, value, 0)
+}
+
+// BulkLoad adds the key-value pairs to the cache under a single lock, e.g. to warm up the cache.
+// Unlike calling Set for each pair, it does not evict while loading: the cache may hold more entries
+// than its size until all pairs are inserted, and is then trimmed once, evicting the least frequently used
+// entries. Loaded pairs of existing keys have their frequency incremented, while new keys start with
+// a frequency of 1, so frequently used entries survive a load of new keys.
+func (l *LFUCache[K, V]) BulkLoad(items map[K]V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.bulkLoading = true
+	for k, v := range items {
+		l.set(k, v, 0)
+	}
+	l.bulkLoading = false
+
+	if over := len(l.items) - int(min(l.size, uint(math.MaxInt))); over > 0 {
+		l.evict(over)
+	}
+}
+
+// TrySet adds the key-value pair to the cache like Set, but reports why the pair could not be stored.
+// Unlike Set, it never evicts: it returns ErrCacheFull if the key is new and the cache is at capacity.
+// It returns ErrCacheClosed if the cache has been closed.
+func (l *LFUCache[K, V]) TrySet(key K, value V) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrCacheClosed
+	}
+	if _, ok := l.items[key]; !ok && uint(len(l.items)) >= l.size {
+		return ErrCacheFull
+	}
 
 	l.set(key, value, 0)
+	return nil
+}
+
+// SetReport adds the key-value pair to the cache like Set and reports whether an entry was evicted
+// to make room for it, and the key of that entry. If several entries were evicted with
+// WithEvictionBatch, the key of the last one is reported.
+func (l *LFUCache[K, V]) SetReport(key K, value V) (evicted bool, evictedKey K) {
+	return l.SetWithTimeoutReport(key, value, 0)
+}
+
+// SetWithTimeoutReport adds the key-value pair to the cache like SetWithTimeout and reports
+// whether an entry was evicted to make room for it, and the key of that entry, like SetReport.
+func (l *LFUCache[K, V]) SetWithTimeoutReport(key K, value V, exp time.Duration) (evicted bool, evictedKey K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	before := l.stats.Evictions
+	l.set(key, value, exp)
+	if l.stats.Evictions == before {
+		return false, evictedKey
+	}
+
+	evictedKey = l.lastEvicted
+	l.lastEvicted = *new(K)
+	return true, evictedKey
+}
+
+// GetSet adds the key-value pair to the cache like SetWithTimeout and returns the previous value of the key
+// if it existed and had not expired, in one atomic step. Like Get, the lookup increments the frequency
+// of the key and counts in Stats.
+func (l *LFUCache[K, V]) GetSet(key K, value V, exp time.Duration) (old V, existed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if item, ok := l.get(key); ok {
+		old, existed = l.opts.copyValue(item.value), true
+	}
+	l.set(key, value, exp)
+	return old, existed
 }
 
 // SetWithTimeout adds the key-value pair to the cache with a specified expiration time.
 func (l *LFUCache[K, V]) SetWithTimeout(key K, value V, exp time.Duration) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.opts.operationHook != nil {
+		defer l.opts.observeOperation("SetWithTimeout", time.Now())
+	}
 
 	l.set(key, value, exp)
 }
 
-func (l *LFUCache[K, V]) set(key K, value V, exp time.Duration) {
-	if l.size == 0 {
+// SetWithTimeoutIfSooner adds the key-value pair to the cache with a specified expiration time,
+// but only if the new expiration would be earlier than the current one.
+// If the key does not exist or is expired, it behaves like SetWithTimeout.
+func (l *LFUCache[K, V]) SetWithTimeoutIfSooner(key K, value V, exp time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setIf(key, value, exp, true)
+}
+
+// SetWithTimeoutIfLater adds the key-value pair to the cache with a specified expiration time,
+// but only if the new expiration would be later than the current one.
+// If the key does not exist or is expired, it behaves like SetWithTimeout.
+func (l *LFUCache[K, V]) SetWithTimeoutIfLater(key K, value V, exp time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setIf(key, value, exp, false)
+}
+
+func (l *LFUCache[K, V]) setIf(key K, value V, exp time.Duration, sooner bool) {
+	exp, ok := l.opts.ttl(exp)
+	if !ok {
 		return
 	}
 
+	if elem, ok := l.items[key]; ok {
+		item := elem.Value.(*lfuItem[K, V])
+		now := l.opts.now()
+		if item.expireAt == 0 || item.expireAt >= l.opts.expiryNow() {
+			var expireAt int64
+			if exp > 0 {
+				expireAt = now.Add(exp).UnixNano()
+			}
+			if sooner && !expiresBefore(expireAt, item.expireAt) {
+				return
+			}
+			if !sooner && !expiresBefore(item.expireAt, expireAt) {
+				return
+			}
+		}
+	}
+
+	l.set(key, value, exp)
+}
+
+func (l *LFUCache[K, V]) set(key K, value V, exp time.Duration) bool {
+	if l.size == 0 || l.closed {
+		return false
+	}
+	exp, ok := l.opts.ttl(exp)
+	if !ok {
+		return false
+	}
+
 	var expireAt int64
 	if exp > 0 {
-		expireAt = time.Now().Add(exp).UnixNano()
+		expireAt = l.opts.now().Add(exp).UnixNano()
 	}
 
 	// Check if key already exists
@@ -66,20 +242,26 @@ func (l *LFUCache[K, V]) set(key K, value V, exp time.Duration) {
 		item.value = value
 		item.expireAt = expireAt
 		l.incrementFreq(elem)
-		return
+		return true
 	}
 
 	// Evict if at capacity
-	if uint(len(l.items)) >= l.size {
-		l.evict(1)
+	before := len(l.items)
+	if uint(before) >= l.size && !l.bulkLoading {
+		if l.opts.rejectOnFull {
+			return false
+		}
+		l.evict(l.opts.evictionCount())
 	}
 
 	// Create new item with frequency 1
+	l.inserted++
 	item := &lfuItem[K, V]{
 		key:      key,
 		value:    value,
 		freq:     1,
 		expireAt: expireAt,
+		seq:      l.inserted,
 	}
 
 	// Add to frequency 1 list
@@ -88,51 +270,195 @@ func (l *LFUCache[K, V]) set(key K, value V, exp time.Duration) {
 	}
 	elem := l.freqLists[1].PushFront(item)
 	l.items[key] = elem
+	l.fifoAdd(item)
 	l.minFreq = 1
+	l.opts.observeHighWater(before, len(l.items), l.size)
+	return true
 }
 
 // Get retrieves the value associated with the given key from the cache.
 // If the key is not found or has expired, it returns (zero value of V, false).
 // Otherwise, it returns (value, true).
 func (l *LFUCache[K, V]) Get(key K) (v V, b bool) {
+	if l.opts.missHook != nil {
+		defer l.opts.observeMiss(key, &b) // runs after the lock is released
+	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.opts.operationHook != nil {
+		defer l.opts.observeOperation("Get", time.Now())
+	}
 
-	elem, ok := l.items[key]
+	item, ok := l.get(key)
+	if !ok {
+		return
+	}
+
+	return l.opts.copyValue(item.value), true
+}
+
+// HasAndTouch reports whether the key exists and has not expired. If it does, the frequency
+// of the key is incremented, like Get, without copying its value. It counts as a lookup in Stats.
+func (l *LFUCache[K, V]) HasAndTouch(key K) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.get(key)
+	return ok
+}
+
+// GetOrDefault retrieves the value associated with the given key like Get,
+// or returns def if the key is not found or has expired.
+// It counts as an access like Get, incrementing the frequency of a found key.
+func (l *LFUCache[K, V]) GetOrDefault(key K, def V) V {
+	if v, ok := l.Get(key); ok {
+		return v
+	}
+	return def
+}
+
+// GetWithExpiration retrieves the value associated with the given key together with its expiration time.
+// If the key is not found or has expired, it returns (zero value of ValueTTL[V], false).
+func (l *LFUCache[K, V]) GetWithExpiration(key K) (v ValueTTL[V], b bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	item, ok := l.get(key)
 	if !ok {
 		return
 	}
 
+	return ValueTTL[V]{Value: l.opts.copyValue(item.value), ExpireAt: expireTime(item.expireAt)}, true
+}
+
+// TTL returns the remaining time to live of the key, or NoExpiry if it never expires. It returns false
+// if the key is not found or has expired. It neither copies the value nor increments the frequency of the key.
+func (l *LFUCache[K, V]) TTL(key K) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return 0, false
+	}
+	return remainingTTL(elem.Value.(*lfuItem[K, V]).expireAt, l.opts.expiryNow())
+}
+
+// GetManyWithExpiration retrieves the values of the given keys together with their expiration times under a single lock.
+// Keys that are not found or have expired are omitted from the returned map.
+// The frequency of each found key is incremented.
+func (l *LFUCache[K, V]) GetManyWithExpiration(keys []K) map[K]ValueTTL[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V], len(keys))
+	for _, k := range keys {
+		if item, ok := l.get(k); ok {
+			m[k] = ValueTTL[V]{Value: l.opts.copyValue(item.value), ExpireAt: expireTime(item.expireAt)}
+		}
+	}
+
+	return m
+}
+
+// GetManyPartial retrieves the values of the given keys under a single lock and returns the keys that are
+// not found or have expired separately, in the order given, e.g. to load only the missing keys from a backing store.
+// The frequency of each found key is incremented.
+func (l *LFUCache[K, V]) GetManyPartial(keys []K) (found map[K]V, missing []K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found = make(map[K]V, len(keys))
+	for _, k := range keys {
+		if item, ok := l.get(k); ok {
+			found[k] = l.opts.copyValue(item.value)
+		} else {
+			missing = append(missing, k)
+		}
+	}
+
+	return found, missing
+}
+
+// GetState retrieves the value associated with the given key and reports whether it is live, expired or absent.
+// For an expired key it returns the last value and removes the key. The frequency of a live key is incremented.
+func (l *LFUCache[K, V]) GetState(key K) (v V, p Presence) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt > 0 && item.expireAt < l.opts.expiryNow() {
+			l.delete(key, elem)
+			l.stats.Misses++
+			return l.opts.copyValue(item.value), Expired
+		}
+	}
+
+	item, ok := l.get(key)
+	if !ok {
+		return v, Absent
+	}
+	return l.opts.copyValue(item.value), Live
+}
+
+// get returns the non-expired item for the key and increments its frequency.
+// Expired items are removed.
+func (l *LFUCache[K, V]) get(key K) (*lfuItem[K, V], bool) {
+	elem, ok := l.items[key]
+	if !ok {
+		l.stats.Misses++
+		return nil, false
+	}
+
 	item := elem.Value.(*lfuItem[K, V])
 
 	// Check expiration
-	if item.expireAt > 0 && item.expireAt < time.Now().UnixNano() {
+	if item.expireAt > 0 && item.expireAt < l.opts.expiryNow() {
 		l.delete(key, elem)
-		return
+		l.stats.Misses++
+		return nil, false
 	}
 
+	l.stats.Hits++
 	l.incrementFreq(elem)
-	return item.value, true
+	return item, true
 }
 
-// incrementFreq moves an item to the next frequency bucket - O(1) operation
+// incrementFreq moves an item to the next frequency bucket - O(1) operation.
+// Items below the promotion threshold or at the maximum frequency only move to the front of their bucket.
 func (l *LFUCache[K, V]) incrementFreq(elem *list.Element) {
 	item := elem.Value.(*lfuItem[K, V])
 	oldFreq := item.freq
+	if item.accesses < l.opts.promoteAfter {
+		item.accesses++
+		if item.accesses < l.opts.promoteAfter {
+			l.freqLists[oldFreq].MoveToFront(elem)
+			return
+		}
+	}
+	if l.opts.maxFreq > 0 && oldFreq >= l.opts.maxFreq {
+		l.freqLists[oldFreq].MoveToFront(elem)
+		return
+	}
 	newFreq := oldFreq + 1
 
 	// Remove from old frequency list
 	oldList := l.freqLists[oldFreq]
 	oldList.Remove(elem)
 
-	// Update minFreq if necessary
-	if oldFreq == l.minFreq && oldList.Len() == 0 {
-		l.minFreq = newFreq
+	// Drop the emptied bucket and update minFreq if necessary
+	if oldList.Len() == 0 {
 		delete(l.freqLists, oldFreq)
+		if oldFreq == l.minFreq {
+			l.minFreq = newFreq
+		}
 	}
 
 	// Add to new frequency list
+	l.fifoRemove(item)
 	item.freq = newFreq
+	l.fifoAdd(item)
 	if l.freqLists[newFreq] == nil {
 		l.freqLists[newFreq] = list.New()
 	}
@@ -146,53 +472,292 @@ func (l *LFUCache[K, V]) NotFoundSet(k K, v V) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	return l.notFoundSet(k, v, 0)
+}
+
+// NotFoundSetWithTimeout adds the key-value pair to the cache only if the key does not exist or is expired.
+// It sets an expiration time for the key-value pair.
+// It returns true if the key was added to the cache, otherwise false.
+func (l *LFUCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.notFoundSet(k, v, t)
+}
+
+// NotFoundSetMany adds each key-value pair to the cache only if the key does not exist or is expired.
+// The whole batch is applied under a single lock.
+// It returns the keys that were added to the cache, in no particular order.
+func (l *LFUCache[K, V]) NotFoundSetMany(items map[K]V) []K {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var added []K
+	for k, v := range items {
+		if l.notFoundSet(k, v, 0) {
+			added = append(added, k)
+		}
+	}
+	return added
+}
+
+func (l *LFUCache[K, V]) notFoundSet(k K, v V, t time.Duration) bool {
+	if l.closed {
+		return false
+	}
+
 	if elem, ok := l.items[k]; ok {
 		item := elem.Value.(*lfuItem[K, V])
 		// Check if existing key is expired
-		if item.expireAt == 0 || item.expireAt >= time.Now().UnixNano() {
+		if item.expireAt == 0 || item.expireAt >= l.opts.expiryNow() {
 			return false
 		}
 		// Key exists but is expired, delete it first
 		l.delete(k, elem)
 	}
 
-	l.set(k, v, 0)
-	return true
+	return l.set(k, v, t)
 }
 
-// NotFoundSetWithTimeout adds the key-value pair to the cache only if the key does not exist or is expired.
-// It sets an expiration time for the key-value pair.
-// It returns true if the key was added to the cache, otherwise false.
-func (l *LFUCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+// LoadOrStore returns the existing value for the key if it exists and is not expired, and increments its frequency.
+// Otherwise, it stores the given value and returns it.
+// The loaded result is true if the value was loaded, false if stored. If the value could not be stored,
+// e.g. with WithRejectOnFull when the cache is full, it returns the zero value and false, like SetIfAbsent.
+func (l *LFUCache[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	if elem, ok := l.items[k]; ok {
 		item := elem.Value.(*lfuItem[K, V])
-		// Check if existing key is expired
-		if item.expireAt == 0 || item.expireAt >= time.Now().UnixNano() {
-			return false
+		if item.expireAt == 0 || item.expireAt >= l.opts.expiryNow() {
+			l.incrementFreq(elem)
+			return l.opts.copyValue(item.value), true
 		}
 		// Key exists but is expired, delete it first
 		l.delete(k, elem)
 	}
 
-	l.set(k, v, t)
+	if !l.set(k, v, 0) {
+		return actual, false
+	}
+	return v, false
+}
+
+// SetIfAbsent stores the value if the key does not exist or is expired and returns (v, true).
+// If the key exists, it returns the current value and false without modifying it, and increments its frequency.
+// If the value could not be stored, e.g. because the cache is closed, it returns the zero value and false.
+func (l *LFUCache[K, V]) SetIfAbsent(k K, v V) (current V, set bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if item, ok := l.get(k); ok {
+		return l.opts.copyValue(item.value), false
+	}
+	if !l.set(k, v, 0) {
+		return current, false
+	}
+	return v, true
+}
+
+// ReplaceIfPresent updates the value of the key only if it exists and is not expired.
+// The existing expiration time is preserved and the access frequency is incremented.
+// It returns true if the value was replaced, otherwise false.
+func (l *LFUCache[K, V]) ReplaceIfPresent(k K, v V) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[k]
+	if !ok {
+		return false
+	}
+
+	item := elem.Value.(*lfuItem[K, V])
+	if item.expireAt > 0 && item.expireAt < l.opts.expiryNow() {
+		l.delete(k, elem)
+		return false
+	}
+
+	item.value = v
+	l.incrementFreq(elem)
+	return true
+}
+
+// Rename moves the entry of oldKey to newKey under a single lock, keeping its value, expiration time
+// and access frequency. An existing entry of newKey is overwritten.
+// It returns false if oldKey does not exist or has expired.
+func (l *LFUCache[K, V]) Rename(oldKey, newKey K) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[oldKey]
+	if !ok {
+		return false
+	}
+	item := elem.Value.(*lfuItem[K, V])
+	if item.expireAt > 0 && item.expireAt < l.opts.expiryNow() {
+		l.delete(oldKey, elem)
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+
+	if other, ok := l.items[newKey]; ok {
+		l.delete(newKey, other)
+	}
+	delete(l.items, oldKey)
+	item.key = newKey
+	l.items[newKey] = elem
 	return true
 }
 
 // GetAll retrieves all key-value pairs from the cache.
 // It returns a map containing all the key-value pairs that are not expired.
 func (l *LFUCache[K, V]) GetAll() map[K]V {
+	m := make(map[K]V)
+	l.GetAllInto(m)
+	return m
+}
+
+// GetAllInto clears dst and fills it with all the key-value pairs of the cache that are not expired, like GetAll.
+// Reusing dst across calls saves allocating and growing a new map each time.
+func (l *LFUCache[K, V]) GetAllInto(dst map[K]V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clear(dst)
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt == 0 || item.expireAt >= now {
+			dst[k] = l.opts.copyValue(item.value)
+		} else if l.opts.eagerScanCleanup {
+			l.delete(k, elem)
+		}
+	}
+}
+
+// GetWhere retrieves the non-expired key-value pairs of the cache for which pred returns true.
+// pred is evaluated under the cache lock and must not call methods of the cache.
+func (l *LFUCache[K, V]) GetWhere(pred func(k K, v V) bool) map[K]V {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	m := make(map[K]V)
-	now := time.Now().UnixNano()
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
+		item := elem.Value.(*lfuItem[K, V])
+		if (item.expireAt == 0 || item.expireAt >= now) && pred(k, item.value) {
+			m[k] = l.opts.copyValue(item.value)
+		}
+	}
+	return m
+}
+
+// Range calls f for each non-expired key-value pair of the cache, in no particular order, until f returns false.
+// Unlike GetAll, it does not copy the entries into a map. f is called under the cache lock and must not call
+// methods of the cache.
+func (l *LFUCache[K, V]) Range(f func(k K, v V) bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt == 0 || item.expireAt >= now {
+			if !f(k, item.value) {
+				return
+			}
+		} else if l.opts.eagerScanCleanup {
+			l.delete(k, elem)
+		}
+	}
+}
+
+// GetAllOrdered retrieves all non-expired key-value pairs from the cache,
+// ordered from the most frequently used to the least frequently used.
+// Keys with the same frequency are ordered from the most recently used to the least recently used.
+// It does not increment the frequency of the keys.
+func (l *LFUCache[K, V]) GetAllOrdered() []Entry[K, V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	freqs := make([]uint, 0, len(l.freqLists))
+	for freq := range l.freqLists {
+		freqs = append(freqs, freq)
+	}
+	slices.Sort(freqs)
+
+	entries := make([]Entry[K, V], 0, len(l.items))
+	now := l.opts.expiryNow()
+	for i := len(freqs) - 1; i >= 0; i-- {
+		for e := l.freqLists[freqs[i]].Front(); e != nil; e = e.Next() {
+			item := e.Value.(*lfuItem[K, V])
+			if item.expireAt == 0 || item.expireAt >= now {
+				entries = append(entries, Entry[K, V]{Key: item.key, Value: l.opts.copyValue(item.value)})
+			}
+		}
+	}
+
+	return entries
+}
+
+// SomeKeys returns up to n live keys in the order they would be evicted: from the least frequently used,
+// and within the same frequency according to the tie-break configured with WithLFUTieBreak.
+// Unlike Keys, it stops as soon as n keys are found.
+func (l *LFUCache[K, V]) SomeKeys(n int) []K {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	freqs := make([]uint, 0, len(l.freqLists))
+	for freq := range l.freqLists {
+		freqs = append(freqs, freq)
+	}
+	slices.Sort(freqs)
+
+	keys := make([]K, 0, min(max(n, 0), len(l.items)))
+	now := l.opts.expiryNow()
+	var bucket []*lfuItem[K, V]
+	for _, freq := range freqs {
+		if len(keys) >= n {
+			break
+		}
+
+		bucket = bucket[:0]
+		for e := l.freqLists[freq].Back(); e != nil; e = e.Prev() {
+			if item := e.Value.(*lfuItem[K, V]); item.expireAt == 0 || item.expireAt >= now {
+				bucket = append(bucket, item)
+			}
+		}
+		if l.opts.tieBreak == FIFOWithinBucket {
+			slices.SortFunc(bucket, func(a, b *lfuItem[K, V]) int { return cmp.Compare(a.seq, b.seq) })
+		}
+		for _, item := range bucket[:min(len(bucket), n-len(keys))] {
+			keys = append(keys, item.key)
+		}
+	}
+
+	return keys
+}
+
+// GetAllSorted retrieves all non-expired key-value pairs from the cache sorted by key according to less,
+// e.g. to compare the contents of the cache in tests or to export them deterministically.
+func (l *LFUCache[K, V]) GetAllSorted(less func(a, b K) bool) []Entry[K, V] {
+	return sortedEntries(l.GetAll(), less)
+}
+
+// GetAllWithExpiration retrieves all key-value pairs from the cache together with their expiration times.
+// It returns a map containing all the key-value pairs that are not expired.
+func (l *LFUCache[K, V]) GetAllWithExpiration() map[K]ValueTTL[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V])
+	now := l.opts.expiryNow()
 	for k, elem := range l.items {
 		item := elem.Value.(*lfuItem[K, V])
 		if item.expireAt == 0 || item.expireAt >= now {
-			m[k] = item.value
+			m[k] = ValueTTL[V]{Value: l.opts.copyValue(item.value), ExpireAt: expireTime(item.expireAt)}
 		}
 	}
 	return m
@@ -203,7 +768,7 @@ func (l *LFUCache[K, V]) GetAll() map[K]V {
 func (src *LFUCache[K, V]) TransferTo(dst *LFUCache[K, V]) {
 	// Collect data with source lock
 	src.mu.Lock()
-	now := time.Now().UnixNano()
+	now := src.opts.expiryNow()
 	toTransfer := make(map[K]V)
 	var keysToDelete []K
 
@@ -215,10 +780,10 @@ func (src *LFUCache[K, V]) TransferTo(dst *LFUCache[K, V]) {
 		}
 	}
 
-	// Delete transferred items from source
+	// Delete transferred items from source, their values live on in the destination
 	for _, k := range keysToDelete {
 		if elem, ok := src.items[k]; ok {
-			src.delete(k, elem)
+			src.unlink(k, elem)
 		}
 	}
 	src.mu.Unlock()
@@ -235,7 +800,7 @@ func (src *LFUCache[K, V]) TransferTo(dst *LFUCache[K, V]) {
 func (src *LFUCache[K, V]) CopyTo(dst *LFUCache[K, V]) {
 	// Collect data with source lock
 	src.mu.Lock()
-	now := time.Now().UnixNano()
+	now := src.opts.expiryNow()
 	toCopy := make(map[K]V)
 
 	for k, elem := range src.items {
@@ -261,44 +826,212 @@ func (l *LFUCache[K, V]) Keys() []K {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	now := time.Now().UnixNano()
+	now := l.opts.expiryNow()
 	keys := make([]K, 0, len(l.items))
 
 	for k, elem := range l.items {
 		item := elem.Value.(*lfuItem[K, V])
 		if item.expireAt == 0 || item.expireAt >= now {
 			keys = append(keys, k)
+		} else if l.opts.eagerScanCleanup {
+			l.delete(k, elem)
 		}
 	}
 	return keys
 }
 
-// Purge removes all key-value pairs from the cache.
+// ExpiringWithin returns the live keys that expire within d from now, sorted soonest first.
+// Keys without an expiration time are not included.
+func (l *LFUCache[K, V]) ExpiringWithin(d time.Duration) []K {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	from := l.opts.expiryNow()
+	until := from + int64(d)
+	var entries []keyExpiration[K]
+	for k, elem := range l.items {
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt >= from && item.expireAt <= until {
+			entries = append(entries, keyExpiration[K]{key: k, expireAt: item.expireAt})
+		}
+	}
+
+	return keysByExpiration(entries)
+}
+
+// TransformValues calls f for each non-expired entry under a single lock and replaces the value
+// of the entry with the returned value if f returns true. The frequencies are not changed.
+// f must not call methods of the cache.
+func (l *LFUCache[K, V]) TransformValues(f func(k K, v V) (V, bool)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt > 0 && item.expireAt < now {
+			continue
+		}
+		if v, ok := f(k, item.value); ok {
+			item.value = v
+		}
+	}
+}
+
+// Purge removes all key-value pairs from the cache. Values implementing Expirable are notified of their removal.
 func (l *LFUCache[K, V]) Purge() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.notifyAll()
+	l.items = make(map[K]*list.Element)
+	l.freqLists = make(map[uint]*list.List)
+	l.fifo = nil
+	l.minFreq = 0
+}
+
+// Flush removes all key-value pairs from the cache and notifies each value implementing Expirable of its removal,
+// so that the resources they hold are released. It currently does the same as Purge, which notifies them too.
+func (l *LFUCache[K, V]) Flush() {
+	l.Purge()
+}
+
+// Drain removes all key-value pairs from the cache and returns the ones that were not expired.
+// Unlike GetAll followed by Purge, it is performed under a single lock.
+func (l *LFUCache[K, V]) Drain() map[K]V {
+	return drainedValues(l.drainWithTTL())
+}
+
+// drainWithTTL removes all key-value pairs from the cache like Drain and returns the ones
+// that were not expired together with their remaining time to live.
+func (l *LFUCache[K, V]) drainWithTTL() map[K]drainedEntry[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := make(map[K]drainedEntry[V])
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
+		item := elem.Value.(*lfuItem[K, V])
+		if ttl, ok := remainingTTL(item.expireAt, now); ok {
+			m[k] = drainedEntry[V]{value: item.value, ttl: ttl}
+		} else {
+			l.opts.notifyRemoved(item.value)
+		}
+	}
+
 	l.items = make(map[K]*list.Element)
 	l.freqLists = make(map[uint]*list.List)
+	l.fifo = nil
+	l.minFreq = 0
+	return m
+}
+
+// Close stops the background goroutines, if any, and clears the cache.
+// After calling Close, the cache should not be used.
+func (l *LFUCache[K, V]) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+	l.closed = true
+	close(l.stopCh)
+
+	l.notifyAll()
+	l.items = nil
+	l.freqLists = nil
+	l.fifo = nil
 	l.minFreq = 0
 }
 
+// notifyAll notifies the values of all items of their removal, before the cache is cleared.
+func (l *LFUCache[K, V]) notifyAll() {
+	for _, elem := range l.items {
+		l.opts.notifyRemoved(elem.Value.(*lfuItem[K, V]).value)
+	}
+}
+
+// Pause stops the background cleanup from removing expired entries until Resume is called, e.g. to inspect
+// expired entries that have not been removed yet. Expired entries are still treated as absent by reads,
+// which may remove them. It does nothing if the cache has no background cleanup.
+func (l *LFUCache[K, V]) Pause() {
+	l.sweeper.paused.Store(true)
+}
+
+// Resume resumes the background cleanup paused by Pause or WithStartPaused.
+// Expired entries are removed by the next scheduled sweep.
+func (l *LFUCache[K, V]) Resume() {
+	l.sweeper.paused.Store(false)
+}
+
+// sweep removes all expired keys and reports how many keys were scanned and removed.
+func (l *LFUCache[K, V]) sweep() (scanned, removed int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
+		scanned++
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt > 0 && item.expireAt < now {
+			l.delete(k, elem)
+			removed++
+		}
+	}
+	return scanned, removed
+}
+
+// shed evicts the given fraction of the entries in response to memory pressure.
+func (l *LFUCache[K, V]) shed(fraction float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evict(int(math.Ceil(float64(len(l.items)) * fraction)))
+}
+
 // Count returns the number of non-expired key-value pairs currently stored in the cache.
+// With WithApproximateCount it returns the number of stored entries without checking them for expiration.
 func (l *LFUCache[K, V]) Count() int {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.opts.approxCount {
+		return len(l.items)
+	}
 	count := 0
-	now := time.Now().UnixNano()
-	for _, elem := range l.items {
+	now := l.opts.expiryNow()
+	for k, elem := range l.items {
 		item := elem.Value.(*lfuItem[K, V])
 		if item.expireAt == 0 || item.expireAt >= now {
 			count++
+		} else if l.opts.eagerScanCleanup {
+			l.delete(k, elem)
 		}
 	}
 	return count
 }
 
+// TTLStats counts the entries without an expiration time, the live entries with one,
+// and the expired entries that have not been removed yet, in a single scan.
+func (l *LFUCache[K, V]) TTLStats() (permanent, expiring, expired int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.expiryNow()
+	for _, elem := range l.items {
+		switch item := elem.Value.(*lfuItem[K, V]); {
+		case item.expireAt == 0:
+			permanent++
+		case item.expireAt >= now:
+			expiring++
+		default:
+			expired++
+		}
+	}
+	return permanent, expiring, expired
+}
+
 // Len returns the total number of elements in the cache (including expired ones).
 func (l *LFUCache[K, V]) Len() int {
 	l.mu.Lock()
@@ -307,21 +1040,99 @@ func (l *LFUCache[K, V]) Len() int {
 	return len(l.items)
 }
 
+// Name returns the name of the cache set with WithName, or an empty string.
+func (l *LFUCache[K, V]) Name() string {
+	return l.opts.name
+}
+
+// Stats returns the usage statistics of the cache.
+func (l *LFUCache[K, V]) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.stats
+	stats.Len = len(l.items)
+	return stats
+}
+
+// String returns a compact summary of the cache, e.g. incache.LFU[name=sessions size=1000 count=812].
+func (l *LFUCache[K, V]) String() string {
+	return fmt.Sprintf("incache.LFU[name=%s size=%s count=%d]", l.opts.name, sizeString(l.size), l.Count())
+}
+
 // Delete removes the key-value pair associated with the given key from the cache.
 func (l *LFUCache[K, V]) Delete(k K) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.opts.operationHook != nil {
+		defer l.opts.observeOperation("Delete", time.Now())
+	}
 
 	if elem, ok := l.items[k]; ok {
 		l.delete(k, elem)
 	}
 }
 
+// ExtendMany adds extra to the expiration time of each of the given keys that is live and expires,
+// under a single lock, and returns the number of keys extended. Keys without expiration are left untouched,
+// and missing or expired keys are skipped.
+func (l *LFUCache[K, V]) ExtendMany(keys []K, extra time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.expiryNow()
+	extended := 0
+	for _, k := range keys {
+		elem, ok := l.items[k]
+		if !ok {
+			continue
+		}
+		if item := elem.Value.(*lfuItem[K, V]); item.expireAt > 0 && item.expireAt >= now {
+			item.expireAt += int64(extra)
+			extended++
+		}
+	}
+
+	return extended
+}
+
+// DeleteExpired removes the given keys if they are expired and returns the number of keys removed.
+// Only the supplied keys are checked, which is cheaper than a full sweep when the candidates are known.
+// Live and missing keys are left untouched.
+func (l *LFUCache[K, V]) DeleteExpired(keys []K) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.expiryNow()
+	removed := 0
+	for _, k := range keys {
+		elem, ok := l.items[k]
+		if !ok {
+			continue
+		}
+		item := elem.Value.(*lfuItem[K, V])
+		if item.expireAt > 0 && item.expireAt < now {
+			l.delete(k, elem)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// delete removes the item from the cache and notifies its value of the removal.
 func (l *LFUCache[K, V]) delete(key K, elem *list.Element) {
+	l.unlink(key, elem)
+	l.opts.notifyRemoved(elem.Value.(*lfuItem[K, V]).value)
+}
+
+// unlink removes the item from the cache without notifying its value.
+func (l *LFUCache[K, V]) unlink(key K, elem *list.Element) {
 	item := elem.Value.(*lfuItem[K, V])
 	freq := item.freq
 
 	// Remove from frequency list
+	l.fifoRemove(item)
 	freqList := l.freqLists[freq]
 	if freqList != nil {
 		freqList.Remove(elem)
@@ -359,13 +1170,171 @@ func (l *LFUCache[K, V]) evict(n int) {
 			}
 		}
 
-		// Remove the least recently used item from the minimum frequency list (back of list)
+		// Remove the least recently used item from the minimum frequency list (back of list),
+		// or the oldest inserted one with FIFOWithinBucket
 		elem := minList.Back()
 		if elem == nil {
 			return
 		}
+		if h := l.fifo[l.minFreq]; h != nil {
+			elem = l.items[(*h)[0].key]
+		}
 
 		item := elem.Value.(*lfuItem[K, V])
+		l.lastEvicted = item.key
 		l.delete(item.key, elem)
+		l.stats.Evictions++
+	}
+}
+
+// fifoAdd adds the item to the insertion order of its frequency, with FIFOWithinBucket.
+func (l *LFUCache[K, V]) fifoAdd(item *lfuItem[K, V]) {
+	if l.opts.tieBreak != FIFOWithinBucket {
+		return
+	}
+	if l.fifo == nil {
+		l.fifo = make(map[uint]*seqHeap[K, V])
+	}
+	h := l.fifo[item.freq]
+	if h == nil {
+		h = &seqHeap[K, V]{}
+		l.fifo[item.freq] = h
+	}
+	heap.Push(h, item)
+}
+
+// fifoRemove removes the item from the insertion order of its frequency, if it is tracked.
+func (l *LFUCache[K, V]) fifoRemove(item *lfuItem[K, V]) {
+	h := l.fifo[item.freq]
+	if h == nil {
+		return
+	}
+	heap.Remove(h, item.index)
+	if h.Len() == 0 {
+		delete(l.fifo, item.freq)
+	}
+}
+
+// seqHeap is a min-heap of the items of a frequency bucket ordered by insertion, so that FIFOWithinBucket
+// finds the item inserted first in O(1) and maintains the order in O(log n) instead of scanning the bucket.
+type seqHeap[K comparable, V any] []*lfuItem[K, V]
+
+func (h seqHeap[K, V]) Len() int           { return len(h) }
+func (h seqHeap[K, V]) Less(i, j int) bool { return h[i].seq < h[j].seq }
+
+func (h seqHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *seqHeap[K, V]) Push(x any) {
+	item := x.(*lfuItem[K, V])
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *seqHeap[K, V]) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// BucketSizes returns the number of entries in each frequency bucket, including expired entries
+// that have not been removed yet. Unlike Inspect, it does not list the keys.
+func (l *LFUCache[K, V]) BucketSizes() map[uint]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sizes := make(map[uint]int, len(l.freqLists))
+	for freq, freqList := range l.freqLists {
+		sizes[freq] = freqList.Len()
+	}
+	return sizes
+}
+
+// MinFreq returns the lowest frequency of the entries in the cache, whose bucket is evicted from first,
+// or 0 if the cache is empty.
+func (l *LFUCache[K, V]) MinFreq() uint {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.minFreq
+}
+
+// Inspect returns a human-readable description of the frequency buckets of the cache, for debugging.
+// The buckets are listed from the lowest frequency, which is evicted first, and the keys of each bucket
+// from the most recently used to the least recently used, which is evicted first. For example:
+//
+//	incache.LFU[size=3 len=3 minFreq=1]
+//	  freq 1: c b
+//	  freq 3: a
+func (l *LFUCache[K, V]) Inspect() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	freqs := make([]uint, 0, len(l.freqLists))
+	for freq := range l.freqLists {
+		freqs = append(freqs, freq)
+	}
+	slices.Sort(freqs)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "incache.LFU[size=%s len=%d minFreq=%d]", sizeString(l.size), len(l.items), l.minFreq)
+	for _, freq := range freqs {
+		fmt.Fprintf(&b, "\n  freq %d:", freq)
+		for e := l.freqLists[freq].Front(); e != nil; e = e.Next() {
+			fmt.Fprintf(&b, " %v", e.Value.(*lfuItem[K, V]).key)
+		}
+	}
+	return b.String()
+}
+
+// validate checks the internal invariants of the cache and returns an error describing the first violation.
+// Every item must be in the bucket of its frequency exactly once, buckets must not be empty,
+// and minFreq must be the lowest frequency of a bucket. It is meant for tests and debugging.
+func (l *LFUCache[K, V]) validate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := 0
+	lowest := uint(0)
+	for freq, freqList := range l.freqLists {
+		if freqList.Len() == 0 {
+			return fmt.Errorf("frequency bucket %d is empty", freq)
+		}
+		if lowest == 0 || freq < lowest {
+			lowest = freq
+		}
+		for e := freqList.Front(); e != nil; e = e.Next() {
+			item := e.Value.(*lfuItem[K, V])
+			if item.freq != freq {
+				return fmt.Errorf("item %v with frequency %d is in bucket %d", item.key, item.freq, freq)
+			}
+			if l.items[item.key] != e {
+				return fmt.Errorf("item %v in bucket %d is not the element in the map", item.key, freq)
+			}
+			count++
+		}
+	}
+
+	if count != len(l.items) {
+		return fmt.Errorf("buckets hold %d items but the map holds %d", count, len(l.items))
+	}
+	if count > 0 && l.minFreq != lowest {
+		return fmt.Errorf("minFreq is %d but the lowest bucket is %d", l.minFreq, lowest)
+	}
+	if l.opts.tieBreak == FIFOWithinBucket {
+		if len(l.fifo) != len(l.freqLists) {
+			return fmt.Errorf("%d frequencies are ordered by insertion but there are %d buckets", len(l.fifo), len(l.freqLists))
+		}
+		for freq, freqList := range l.freqLists {
+			if h := l.fifo[freq]; h == nil || h.Len() != freqList.Len() {
+				return fmt.Errorf("insertion order of bucket %d does not hold its %d items", freq, freqList.Len())
+			}
+		}
 	}
+	return nil
 }
@@ -1,6 +1,9 @@
 package incache
 
 import (
+	"maps"
+	"math/rand/v2"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -124,6 +127,32 @@ func TestLFUCache_NotFoundSetWithExpired(t *testing.T) {
 	}
 }
 
+func TestLFUCache_NotFoundSetMany(t *testing.T) {
+	c := NewLFU[string, string](10)
+	c.Set("present", "old")
+	c.SetWithTimeout("expired", "old", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	added := c.NotFoundSetMany(map[string]string{
+		"present": "new",
+		"expired": "new",
+		"absent":  "new",
+	})
+	slices.Sort(added)
+
+	if !slices.Equal(added, []string{"absent", "expired"}) {
+		t.Errorf("Expected only absent and expired keys to be added, got %v", added)
+	}
+	if v, _ := c.Get("present"); v != "old" {
+		t.Errorf("Expected present key to keep its value, got %v", v)
+	}
+	for _, k := range added {
+		if v, ok := c.Get(k); !ok || v != "new" {
+			t.Errorf("Expected %s to be stored, got %v", k, v)
+		}
+	}
+}
+
 func TestLFUCache_TransferTo(t *testing.T) {
 	srcCache := NewLFU[int, string](10)
 	dstCache := NewLFU[int, string](10)
@@ -306,3 +335,474 @@ func TestLFUCache_Concurrent(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestLFUCache_ReplaceIfPresent(t *testing.T) {
+	c := NewLFU[string, string](2)
+
+	if c.ReplaceIfPresent("a", "1") {
+		t.Errorf("ReplaceIfPresent should return false for a missing key")
+	}
+	if c.Len() != 0 {
+		t.Errorf("ReplaceIfPresent should not create a new key")
+	}
+
+	c.Set("a", "1")
+	c.Set("b", "2")
+	if !c.ReplaceIfPresent("a", "updated") {
+		t.Errorf("ReplaceIfPresent should return true for an existing key")
+	}
+
+	// The replace counts as an access, so "b" has the lowest frequency
+	c.Set("c", "3")
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != "updated" {
+		t.Errorf("Expected updated, got %v", v)
+	}
+}
+
+func TestLFUCache_SetWithTimeoutIfSooner(t *testing.T) {
+	c := NewLFU[string, string](10)
+
+	c.SetWithTimeoutIfSooner("a", "1", time.Hour)
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("Expected 1, got %v", v)
+	}
+
+	c.SetWithTimeoutIfSooner("a", "2", 2*time.Hour)
+	if v, _ := c.Get("a"); v != "1" {
+		t.Errorf("Expected later expiration to be ignored, got %v", v)
+	}
+
+	c.SetWithTimeoutIfSooner("a", "3", time.Millisecond)
+	if v, _ := c.Get("a"); v != "3" {
+		t.Errorf("Expected sooner expiration to win, got %v", v)
+	}
+}
+
+func TestLFUCache_SetWithTimeoutIfLater(t *testing.T) {
+	c := NewLFU[string, string](10)
+
+	c.SetWithTimeoutIfLater("a", "1", time.Millisecond)
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("Expected 1, got %v", v)
+	}
+
+	c.SetWithTimeoutIfLater("a", "2", time.Hour)
+	if v, _ := c.Get("a"); v != "2" {
+		t.Errorf("Expected later expiration to win, got %v", v)
+	}
+
+	c.SetWithTimeoutIfLater("a", "3", time.Millisecond)
+	if v, _ := c.Get("a"); v != "2" {
+		t.Errorf("Expected sooner expiration to be ignored, got %v", v)
+	}
+}
+
+func TestLFUCache_Drain(t *testing.T) {
+	c := NewLFU[string, int](10)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.SetWithTimeout("c", 3, time.Microsecond)
+	time.Sleep(time.Millisecond)
+
+	m := c.Drain()
+	if len(m) != 2 || m["a"] != 1 || m["b"] != 2 {
+		t.Errorf("Drain returned unexpected entries: %v", m)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Expected cache to be empty after Drain, got Len=%d", c.Len())
+	}
+
+	c.Set("d", 4)
+	if v, ok := c.Get("d"); !ok || v != 4 {
+		t.Errorf("Expected to use cache after drain")
+	}
+}
+
+func TestLFUCache_WithClock(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLFU(10, WithClock[string, int](clock))
+
+	c.SetWithTimeout("a", 1, time.Minute)
+	c.Set("b", 2)
+
+	clock.Advance(time.Hour)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to have expired")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("Expected b to never expire")
+	}
+}
+
+func TestLFUCache_WithCleanupInterval(t *testing.T) {
+	c := NewLFU(10, WithCleanupInterval[string, int](time.Millisecond))
+	defer c.Close()
+
+	c.SetWithTimeout("a", 1, time.Millisecond)
+	c.Set("b", 2)
+
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected sweeper to remove the expired key, got Len=%d", c.Len())
+	}
+}
+
+func TestLFUCache_LoadOrStore(t *testing.T) {
+	c := NewLFU[string, int](2)
+
+	if v, loaded := c.LoadOrStore("a", 1); loaded || v != 1 {
+		t.Errorf("Expected 1 to be stored, got %v, %v", v, loaded)
+	}
+	c.Set("b", 2)
+
+	if v, loaded := c.LoadOrStore("a", 100); !loaded || v != 1 {
+		t.Errorf("Expected 1 to be loaded, got %v, %v", v, loaded)
+	}
+
+	// The load counts as an access, so b has the lowest frequency
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+
+	c.SetWithTimeout("c", 3, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if v, loaded := c.LoadOrStore("c", 30); loaded || v != 30 {
+		t.Errorf("Expected expired key to be stored, got %v, %v", v, loaded)
+	}
+}
+
+func TestLFUCache_LoadOrStore_NotStored(t *testing.T) {
+	c := NewLFU(1, WithRejectOnFull[string, int]())
+	c.Set("x", 1)
+
+	if v, loaded := c.LoadOrStore("y", 2); loaded || v != 0 {
+		t.Errorf("Expected the zero value for a rejected key, got %v, %v", v, loaded)
+	}
+	if _, ok := c.Get("y"); ok {
+		t.Errorf("Expected the rejected key not to be stored")
+	}
+}
+
+func TestLFUCache_GetAllOrdered(t *testing.T) {
+	cache := NewLFU[string, int](10)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Set("d", 4)
+
+	// b is accessed three times, c and a twice with a accessed last, d once
+	for i := 0; i < 3; i++ {
+		cache.Get("b")
+	}
+	cache.Get("c")
+	cache.Get("a")
+
+	want := []Entry[string, int]{{"b", 2}, {"a", 1}, {"c", 3}, {"d", 4}}
+	if got := cache.GetAllOrdered(); !slices.Equal(got, want) {
+		t.Errorf("Expected entries in frequency order %v, got %v", want, got)
+	}
+}
+
+func TestLFUCache_Validate(t *testing.T) {
+	clock := NewMockClock()
+	cache := NewLFU(16, WithClock[int, int](clock))
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 10000; i++ {
+		k, v := r.IntN(32), r.IntN(100)
+		switch r.IntN(10) {
+		case 0:
+			cache.Set(k, v)
+		case 1:
+			cache.SetWithTimeout(k, v, time.Duration(r.IntN(5))*time.Second)
+		case 2, 3:
+			cache.Get(k)
+		case 4:
+			cache.Delete(k)
+		case 5:
+			cache.NotFoundSet(k, v)
+		case 6:
+			cache.ReplaceIfPresent(k, v)
+		case 7:
+			cache.DeleteExpired([]int{k, k + 1})
+		case 8:
+			clock.Advance(time.Second)
+		case 9:
+			if r.IntN(50) == 0 {
+				cache.Purge()
+			}
+		}
+
+		if err := cache.validate(); err != nil {
+			t.Fatalf("Invariant violated after %d operations: %v", i+1, err)
+		}
+		if cache.Len() > 16 {
+			t.Fatalf("Cache exceeded its size after %d operations: Len=%d", i+1, cache.Len())
+		}
+	}
+}
+
+func TestLFUCache_Inspect(t *testing.T) {
+	cache := NewLFU[string, int](3)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("a")
+	cache.Get("a")
+
+	want := "incache.LFU[size=3 len=3 minFreq=1]\n" +
+		"  freq 1: c b\n" +
+		"  freq 3: a"
+	if got := cache.Inspect(); got != want {
+		t.Errorf("Expected dump:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Adding d evicts b, the least recently used key of the lowest frequency
+	cache.Set("d", 4)
+	cache.Get("c")
+
+	want = "incache.LFU[size=3 len=3 minFreq=1]\n" +
+		"  freq 1: d\n" +
+		"  freq 2: c\n" +
+		"  freq 3: a"
+	if got := cache.Inspect(); got != want {
+		t.Errorf("Expected dump:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestLFUCache_BucketSizes(t *testing.T) {
+	cache := NewLFU[string, int](5)
+	if got := cache.MinFreq(); got != 0 {
+		t.Errorf("Expected MinFreq 0 for an empty cache, got %d", got)
+	}
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+
+	want := map[uint]int{1: 1, 2: 1, 3: 1}
+	if got := cache.BucketSizes(); !maps.Equal(got, want) {
+		t.Errorf("Expected bucket sizes %v, got %v", want, got)
+	}
+	if got := cache.MinFreq(); got != 1 {
+		t.Errorf("Expected MinFreq 1, got %d", got)
+	}
+
+	cache.Get("c")
+	cache.Delete("b")
+	want = map[uint]int{2: 1, 3: 1}
+	if got := cache.BucketSizes(); !maps.Equal(got, want) {
+		t.Errorf("Expected bucket sizes %v, got %v", want, got)
+	}
+	if got := cache.MinFreq(); got != 2 {
+		t.Errorf("Expected MinFreq 2, got %d", got)
+	}
+}
+
+func TestLFUCache_TransformValues(t *testing.T) {
+	cache := NewLFU[string, int](10)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("b")
+	cache.Get("b")
+	cache.Get("c")
+
+	cache.TransformValues(func(k string, v int) (int, bool) {
+		return v * 2, true
+	})
+
+	want := []Entry[string, int]{{"b", 4}, {"c", 6}, {"a", 2}}
+	if got := cache.GetAllOrdered(); !slices.Equal(got, want) {
+		t.Errorf("Expected doubled values with unchanged frequency order %v, got %v", want, got)
+	}
+}
+
+func TestLFUCache_SetReport(t *testing.T) {
+	cache := NewLFU[string, int](2)
+	cache.SetReport("a", 1)
+	cache.SetReport("b", 2)
+	cache.Get("a")
+
+	evicted, key := cache.SetReport("c", 3)
+	if !evicted || key != "b" {
+		t.Errorf("Expected 'b' to be evicted as least frequently used, got (%v, %q)", evicted, key)
+	}
+
+	evicted, key = cache.SetWithTimeoutReport("d", 4, time.Minute)
+	if !evicted || key != "c" {
+		t.Errorf("Expected 'c' to be evicted as least frequently used, got (%v, %q)", evicted, key)
+	}
+
+	if evicted, _ := cache.SetReport("a", 10); evicted {
+		t.Errorf("Expected no eviction when updating an existing key")
+	}
+}
+
+func TestLFUCache_WithLFUTieBreak(t *testing.T) {
+	tests := []struct {
+		mode   TieBreak
+		victim string
+	}{
+		{LRUWithinBucket, "b"},
+		{FIFOWithinBucket, "a"},
+	}
+
+	for _, tt := range tests {
+		cache := NewLFU(3, WithLFUTieBreak[string, int](tt.mode))
+		// All keys end up with frequency 2; "a" was inserted first but "b" was used least recently
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.Get("b")
+		cache.Set("c", 3)
+		cache.Get("a")
+		cache.Get("c")
+
+		evicted, key := cache.SetReport("d", 4)
+		if !evicted || key != tt.victim {
+			t.Errorf("mode %d: expected %q to be evicted, got (%v, %q)", tt.mode, tt.victim, evicted, key)
+		}
+		if err := cache.validate(); err != nil {
+			t.Errorf("mode %d: %v", tt.mode, err)
+		}
+	}
+}
+
+func TestLFUCache_FIFOWithinBucketOrder(t *testing.T) {
+	cache := NewLFU(100, WithLFUTieBreak[int, int](FIFOWithinBucket))
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+	// Promote the keys to frequency 2 in reverse insertion order, and delete a few of them
+	for i := 99; i >= 0; i-- {
+		cache.Get(i)
+	}
+	for i := 0; i < 100; i += 10 {
+		cache.Delete(i)
+		cache.Set(1000+i, 0) // refill the cache, keeping the new key out of the lowest frequency
+		cache.Get(1000 + i)
+		cache.Get(1000 + i)
+	}
+
+	for want := 1; want < 100; want++ {
+		if want%10 == 0 {
+			continue
+		}
+		evicted, key := cache.SetReport(1000+want, 0)
+		if !evicted || key != want {
+			t.Fatalf("Expected %d to be evicted in insertion order, got (%v, %d)", want, evicted, key)
+		}
+		cache.Get(1000 + want)
+		cache.Get(1000 + want)
+		if err := cache.validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLFUCache_WithLFUPromotionThreshold(t *testing.T) {
+	cache := NewLFU(3, WithLFUPromotionThreshold[string, int](3))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	for range 3 {
+		cache.Get("a") // crosses the threshold
+	}
+	cache.Get("b")
+	cache.Get("b") // still below the threshold, counts no more than c's single access
+	cache.Get("c")
+
+	if evicted, key := cache.SetReport("d", 4); !evicted || key != "b" {
+		t.Errorf("Expected the least recently used entry below the threshold to be evicted, got (%v, %q)", evicted, key)
+	}
+	if evicted, key := cache.SetReport("e", 5); !evicted || key != "c" {
+		t.Errorf("Expected the once accessed entry to be evicted, got (%v, %q)", evicted, key)
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("Expected the entry accessed 3 times to be kept")
+	}
+	if err := cache.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLFUCache_WithMaxFrequency(t *testing.T) {
+	cache := NewLFU(3, WithMaxFrequency[string, int](4))
+	cache.Set("hot", 1)
+	cache.Set("warm", 2)
+	for i := 0; i < 1000; i++ {
+		cache.Get("hot")
+		for freq := range cache.BucketSizes() {
+			if freq > 4 {
+				t.Fatalf("Expected no bucket above the maximum frequency, got %d", freq)
+			}
+		}
+	}
+	for i := 0; i < 10; i++ {
+		cache.Get("warm")
+	}
+
+	// Both keys plateau at the maximum frequency and share its bucket
+	if got, want := cache.BucketSizes(), map[uint]int{4: 2}; !maps.Equal(got, want) {
+		t.Errorf("Expected bucket sizes %v, got %v", want, got)
+	}
+	if err := cache.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLFUCache_Rename_KeepsFrequency(t *testing.T) {
+	c := NewLFU[string, int](2)
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("a")
+	c.Set("b", 2)
+
+	c.Rename("a", "z")
+	c.Set("c", 3) // evicts b, the least frequently used entry
+
+	if v, ok := c.Get("z"); !ok || v != 1 {
+		t.Errorf("Expected renamed z to keep the frequency of a, got %v, %v", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+}
+
+func TestLFUCache_BulkLoad(t *testing.T) {
+	c := NewLFU[string, int](3)
+	c.Set("hot", 0)
+	c.Get("hot")
+	c.Get("hot")
+	c.Set("cold", 0)
+
+	c.BulkLoad(map[string]int{"a": 1, "b": 2, "c": 3, "d": 4})
+	if c.Count() != 3 {
+		t.Errorf("Expected the cache to be trimmed to 3 entries, got %d", c.Count())
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Errorf("Expected the frequently used entry to survive the load")
+	}
+	if _, ok := c.Get("cold"); ok {
+		t.Errorf("Expected cold, the least frequently used entry, to be evicted")
+	}
+	if s := c.Stats(); s.Evictions != 3 {
+		t.Errorf("Expected 3 evictions, got %d", s.Evictions)
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,212 @@
+package incache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Loader loads the value for a key that is missing from a LoadingCache.
+// It returns the value, an error if the value could not be loaded, and the time to live of the value.
+// A zero or negative time to live means the value does not expire.
+type Loader[K comparable, V any] func(k K) (V, error, time.Duration)
+
+// LoadingOption configures optional behavior of a LoadingCache.
+type LoadingOption[K comparable, V any] func(*LoadingCache[K, V])
+
+// WithRefreshAhead makes the LoadingCache reload a value in the background when it is read
+// within refreshBefore of its expiration. The current value is returned immediately,
+// so reads of frequently accessed keys never block on the loader.
+func WithRefreshAhead[K comparable, V any](refreshBefore time.Duration) LoadingOption[K, V] {
+	return func(c *LoadingCache[K, V]) {
+		c.refreshBefore = refreshBefore
+	}
+}
+
+// WithNegativeCaching makes the LoadingCache remember for ttl that a key does not exist,
+// once the loader has returned an error wrapping ErrNotFound for it. Within ttl, GetOrLoad returns
+// the error again without calling the loader. Other errors of the loader are never cached.
+func WithNegativeCaching[K comparable, V any](ttl time.Duration) LoadingOption[K, V] {
+	return func(c *LoadingCache[K, V]) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithLoadingClock sets the clock the LoadingCache uses to decide when to refresh a value with WithRefreshAhead
+// and when a key remembered with WithNegativeCaching expires. It should be the clock of the wrapped cache,
+// see WithClock, e.g. a MockClock in tests. The default is the system clock.
+func WithLoadingClock[K comparable, V any](clock Clock) LoadingOption[K, V] {
+	return func(c *LoadingCache[K, V]) {
+		c.clock = clock
+	}
+}
+
+// LoadingCache is a read-through cache: it wraps a Cache and loads missing values using a Loader.
+// Concurrent loads of the same key are deduplicated so the loader runs once per key at a time.
+// Errors returned by the loader are not cached, except that absent keys are remembered with WithNegativeCaching.
+type LoadingCache[K comparable, V any] struct {
+	cache         Cache[K, V]
+	loader        Loader[K, V]
+	refreshBefore time.Duration // reload values this long before they expire, 0 disables refresh-ahead
+	negativeTTL   time.Duration // remember absent keys this long, 0 disables negative caching
+	clock         Clock
+
+	loads Group[K, V] // in-flight loads
+
+	mu            sync.Mutex
+	negatives     map[K]negativeEntry // keys the loader reported as absent, only with WithNegativeCaching
+	negativeLimit int                 // expired negatives are pruned once this many are recorded
+}
+
+// negativeEntry is a key reported as absent by the loader, with the error of the loader.
+type negativeEntry struct {
+	err      error
+	expireAt time.Time
+}
+
+// NewLoadingCache creates a new LoadingCache that stores values in cache and loads missing values with loader.
+func NewLoadingCache[K comparable, V any](cache Cache[K, V], loader Loader[K, V], opts ...LoadingOption[K, V]) *LoadingCache[K, V] {
+	c := &LoadingCache[K, V]{
+		cache:  cache,
+		loader: loader,
+		clock:  realClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get returns the cached value for the key, or loads it with the loader and stores it with the returned TTL.
+// If the loader returns an error, the error is returned and nothing is stored.
+func (c *LoadingCache[K, V]) Get(k K) (V, error) {
+	return c.get(k, false)
+}
+
+// GetOrLoad returns the cached value for the key, or loads it like Get, but tells absent keys apart
+// from failed loads: if the loader returns an error wrapping ErrNotFound, GetOrLoad returns an error
+// wrapping it, so errors.Is(err, ErrNotFound) reports that the key does not exist. Any other error
+// is a failure of the loader and is returned as is. Failures are not cached and the next call retries
+// the loader; with WithNegativeCaching, absent keys are remembered without calling the loader again.
+func (c *LoadingCache[K, V]) GetOrLoad(k K) (V, error) {
+	v, err := c.get(k, true)
+	if errors.Is(err, ErrNotFound) {
+		return v, fmt.Errorf("incache: loading key %v: %w", k, err)
+	}
+	return v, err
+}
+
+// get returns the cached value for the key or loads it. If negatives is true, keys remembered as absent
+// are not loaded again.
+func (c *LoadingCache[K, V]) get(k K, negatives bool) (V, error) {
+	if c.refreshBefore <= 0 {
+		if v, ok := c.cache.Get(k); ok {
+			return v, nil
+		}
+		return c.miss(k, negatives)
+	}
+
+	e, ok := c.cache.GetWithExpiration(k)
+	if !ok {
+		return c.miss(k, negatives)
+	}
+	if !e.ExpireAt.IsZero() && e.ExpireAt.Sub(c.clock.Now()) <= c.refreshBefore {
+		c.refresh(k)
+	}
+	return e.Value, nil
+}
+
+// Cache returns the underlying cache.
+func (c *LoadingCache[K, V]) Cache() Cache[K, V] {
+	return c.cache
+}
+
+// miss loads a key missing from the cache, unless negatives is true and the key is remembered as absent,
+// in which case it returns the error of the loader that reported it absent.
+func (c *LoadingCache[K, V]) miss(k K, negatives bool) (V, error) {
+	if negatives && c.negativeTTL > 0 {
+		c.mu.Lock()
+		n, ok := c.negatives[k]
+		if ok && !c.clock.Now().Before(n.expireAt) {
+			delete(c.negatives, k)
+			ok = false
+		}
+		c.mu.Unlock()
+		if ok {
+			var zero V
+			return zero, n.err
+		}
+	}
+	return c.load(k)
+}
+
+// load runs the loader for the key, or waits for an in-flight load of the same key.
+// If the loader panics, the panic is propagated and callers waiting for the load get an error wrapping ErrPanicked.
+func (c *LoadingCache[K, V]) load(k K) (V, error) {
+	v, err, _ := c.loads.Do(k, func() (V, error) {
+		return c.run(k)
+	})
+	return v, err
+}
+
+// refresh starts a background load of the key unless a load of the key is already in flight.
+// A panic of the loader is recovered and the current value is kept until it expires.
+func (c *LoadingCache[K, V]) refresh(k K) {
+	c.loads.goDo(k, func() (V, error) {
+		return c.run(k)
+	})
+}
+
+// run invokes the loader for the key and stores the result.
+func (c *LoadingCache[K, V]) run(k K) (V, error) {
+	v, err, ttl := c.loader(k)
+	if err == nil {
+		c.cache.SetWithTimeout(k, v, ttl)
+	} else if c.negativeTTL > 0 && errors.Is(err, ErrNotFound) {
+		c.mu.Lock()
+		c.recordNegative(k, err)
+		c.mu.Unlock()
+	}
+	return v, err
+}
+
+// recordNegative remembers that the key is absent, as reported by err. Expired entries are pruned whenever
+// the number of entries doubles, so the entries stay proportional to the absent keys looked up within the TTL.
+// The caller must hold c.mu.
+func (c *LoadingCache[K, V]) recordNegative(k K, err error) {
+	now := c.clock.Now()
+	if len(c.negatives) >= c.negativeLimit {
+		for key, n := range c.negatives {
+			if !now.Before(n.expireAt) {
+				delete(c.negatives, key)
+			}
+		}
+		c.negativeLimit = max(2*len(c.negatives), 64)
+	}
+	if c.negatives == nil {
+		c.negatives = make(map[K]negativeEntry)
+	}
+	c.negatives[k] = negativeEntry{err: err, expireAt: now.Add(c.negativeTTL)}
+}
+
+// CacheFunc returns a function that reads through c: it returns the cached value for a key,
+// or loads it with loader and stores it without expiration. Concurrent calls for the same key
+// share a single load. Errors returned by the loader are not cached.
+// It packages the read-through pattern of LoadingCache as a plain function.
+func CacheFunc[K comparable, V any](c Cache[K, V], loader func(K) (V, error)) func(K) (V, error) {
+	var g Group[K, V]
+	return func(k K) (V, error) {
+		if v, ok := c.Get(k); ok {
+			return v, nil
+		}
+		v, err, _ := g.Do(k, func() (V, error) {
+			v, err := loader(k)
+			if err == nil {
+				c.Set(k, v)
+			}
+			return v, err
+		})
+		return v, err
+	}
+}
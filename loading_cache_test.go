@@ -0,0 +1,346 @@
+package incache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCache_Get(t *testing.T) {
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU[string, int](10), func(k string) (int, error, time.Duration) {
+		calls.Add(1)
+		return len(k), nil, 0
+	})
+
+	for i := 0; i < 3; i++ {
+		if v, err := c.Get("abc"); err != nil || v != 3 {
+			t.Errorf("Expected 3, got %v, %v", v, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected loader to be called once, got %d", calls.Load())
+	}
+}
+
+func TestLoadingCache_ConcurrentGet(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	c := NewLoadingCache(NewLRU[string, int](10), func(k string) (int, error, time.Duration) {
+		calls.Add(1)
+		<-release
+		return 42, nil, 0
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get("key"); err != nil || v != 42 {
+				t.Errorf("Expected 42, got %v, %v", v, err)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected loader to be called once under concurrent Gets, got %d", calls.Load())
+	}
+}
+
+func TestLoadingCache_TTL(t *testing.T) {
+	clock := NewMockClock()
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU(10, WithClock[string, int](clock)), func(k string) (int, error, time.Duration) {
+		return int(calls.Add(1)), nil, time.Minute
+	})
+
+	c.Get("key")
+	entries := c.Cache().GetAllWithExpiration()
+	if !entries["key"].ExpireAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Expected the loader TTL to be applied, got %v", entries["key"].ExpireAt)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if v, _ := c.Get("key"); v != 2 {
+		t.Errorf("Expected value to be reloaded after expiring, got %v", v)
+	}
+}
+
+func TestLoadingCache_ErrorNotCached(t *testing.T) {
+	errBackend := errors.New("backend failed")
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU[string, int](10), func(k string) (int, error, time.Duration) {
+		if calls.Add(1) == 1 {
+			return 0, errBackend, 0
+		}
+		return 1, nil, 0
+	})
+
+	if _, err := c.Get("key"); err != errBackend {
+		t.Errorf("Expected backend error, got %v", err)
+	}
+	if c.Cache().Len() != 0 {
+		t.Errorf("Expected error to not be cached")
+	}
+	if v, err := c.Get("key"); err != nil || v != 1 {
+		t.Errorf("Expected retry to load 1, got %v, %v", v, err)
+	}
+}
+
+func TestLoadingCache_GetOrLoad(t *testing.T) {
+	errBackend := errors.New("backend failed")
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU[string, int](10), func(k string) (int, error, time.Duration) {
+		calls.Add(1)
+		switch k {
+		case "down":
+			return 0, errBackend, 0
+		case "absent":
+			return 0, ErrNotFound, 0
+		}
+		return len(k), nil, 0
+	})
+
+	if v, err := c.GetOrLoad("abc"); err != nil || v != 3 {
+		t.Errorf("Expected 3, got %v, %v", v, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetOrLoad("down"); err != errBackend {
+			t.Errorf("Expected the backend error, got %v", err)
+		}
+		if _, err := c.GetOrLoad("absent"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	if calls.Load() != 5 {
+		t.Errorf("Expected failures and absent keys to be loaded on every call, got %d loads", calls.Load())
+	}
+	if c.Cache().Len() != 1 {
+		t.Errorf("Expected only abc to be cached, got %d entries", c.Cache().Len())
+	}
+}
+
+func TestLoadingCache_WithNegativeCaching(t *testing.T) {
+	errBackend := errors.New("backend failed")
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU[string, int](10), func(k string) (int, error, time.Duration) {
+		calls.Add(1)
+		if k == "down" {
+			return 0, errBackend, 0
+		}
+		return 0, ErrNotFound, 0
+	}, WithNegativeCaching[string, int](20*time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad("absent"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the absent key to be loaded once, got %d loads", calls.Load())
+	}
+
+	c.GetOrLoad("down")
+	c.GetOrLoad("down")
+	if calls.Load() != 3 {
+		t.Errorf("Expected failures not to be cached, got %d loads", calls.Load())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.GetOrLoad("absent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("Expected the absent key to be loaded again once the negative TTL elapsed, got %d loads", calls.Load())
+	}
+}
+
+func TestLoadingCache_RefreshAhead(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{}, 10)
+	c := NewLoadingCache(NewLRU[string, int32](10), func(k string) (int32, error, time.Duration) {
+		n := calls.Add(1)
+		if n > 1 {
+			<-release
+		}
+		return n, nil, 50 * time.Millisecond
+	}, WithRefreshAhead[string, int32](40*time.Millisecond))
+
+	if v, _ := c.Get("key"); v != 1 {
+		t.Fatalf("Expected initial load to return 1, got %v", v)
+	}
+
+	// Within the refresh window, the current value is returned without blocking on the loader
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if v, _ := c.Get("key"); v != 1 {
+			t.Errorf("Expected current value 1 while refreshing, got %v", v)
+		}
+	}
+
+	release <- struct{}{}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if v, _ := c.Cache().Get("key"); v == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if v, ok := c.Cache().Get("key"); !ok || v != 2 {
+		t.Errorf("Expected value to be refreshed to 2, got %v", v)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected a single refresh, got %d loader calls", calls.Load())
+	}
+}
+
+func TestLoadingCache_WithLoadingClock(t *testing.T) {
+	clock := NewMockClock()
+	clock.Advance(24 * time.Hour)
+	var calls atomic.Int32
+	refreshed := make(chan struct{}, 10)
+	c := NewLoadingCache(NewLRU(10, WithClock[string, int32](clock)), func(k string) (int32, error, time.Duration) {
+		n := calls.Add(1)
+		if k == "absent" {
+			return 0, ErrNotFound, 0
+		}
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return n, nil, time.Hour
+	}, WithRefreshAhead[string, int32](10*time.Minute),
+		WithNegativeCaching[string, int32](time.Minute),
+		WithLoadingClock[string, int32](clock))
+
+	c.Get("key")
+	c.Get("key")
+	if calls.Load() != 1 {
+		t.Fatalf("Expected no refresh outside the refresh window of the cache clock, got %d loader calls", calls.Load())
+	}
+	clock.Advance(55 * time.Minute)
+	c.Get("key")
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a refresh within the refresh window of the cache clock")
+	}
+
+	c.GetOrLoad("absent")
+	c.GetOrLoad("absent")
+	if calls.Load() != 3 {
+		t.Fatalf("Expected the absent key to be remembered, got %d loader calls", calls.Load())
+	}
+	clock.Advance(2 * time.Minute)
+	c.GetOrLoad("absent")
+	if calls.Load() != 4 {
+		t.Errorf("Expected the absent key to be loaded again once the negative TTL elapsed on the clock, got %d loader calls", calls.Load())
+	}
+}
+
+func TestCacheFunc(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	cache := NewLRU[string, int](10)
+	get := CacheFunc(cache, func(k string) (int, error) {
+		calls.Add(1)
+		<-release
+		if k == "fail" {
+			return 0, errors.New("load failed")
+		}
+		return len(k), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := get("abc"); err != nil || v != 3 {
+				t.Errorf("Expected 3, got %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected loader to be called once under concurrent calls, got %d", calls.Load())
+	}
+	if v, err := get("abc"); err != nil || v != 3 || calls.Load() != 1 {
+		t.Errorf("Expected the cached value without loading again, got %v, %v", v, err)
+	}
+	if v, ok := cache.Get("abc"); !ok || v != 3 {
+		t.Errorf("Expected the loaded value to be stored in the cache, got %v, %v", v, ok)
+	}
+
+	if _, err := get("fail"); err == nil {
+		t.Errorf("Expected the loader error")
+	}
+	if _, ok := cache.Get("fail"); ok {
+		t.Errorf("Expected errors not to be cached")
+	}
+}
+
+func TestLoadingCache_LoaderPanic(t *testing.T) {
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU[string, int32](10), func(k string) (int32, error, time.Duration) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		return 1, nil, 0
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected the loader panic to be propagated, got %v", r)
+			}
+		}()
+		c.Get("key")
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, err := c.Get("key"); v != 1 || err != nil {
+			t.Errorf("Expected the key to be loaded again after the panic, got %v, %v", v, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Get not to block after a loader panic")
+	}
+}
+
+func TestLoadingCache_RefreshPanic(t *testing.T) {
+	var calls atomic.Int32
+	c := NewLoadingCache(NewLRU[string, int32](10), func(k string) (int32, error, time.Duration) {
+		if calls.Add(1) == 2 {
+			panic("boom")
+		}
+		return calls.Load(), nil, 50 * time.Millisecond
+	}, WithRefreshAhead[string, int32](40*time.Millisecond))
+
+	c.Get("key")
+	time.Sleep(20 * time.Millisecond)
+	if v, _ := c.Get("key"); v != 1 { // starts a refresh that panics in the background
+		t.Errorf("Expected the current value while refreshing, got %v", v)
+	}
+
+	// Once the entry expires, Get loads the key again instead of waiting for the failed refresh
+	time.Sleep(50 * time.Millisecond)
+	if v, err := c.Get("key"); err != nil || v != 3 {
+		t.Errorf("Expected the key to be loaded again after a refresh panic, got %v, %v", v, err)
+	}
+}
@@ -1,152 +1,1360 @@
 package incache
 
 import (
-	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type lruItem[K comparable, V any] struct {
 	key      K
 	value    V
-	expireAt int64 // Unix nano timestamp, 0 means no expiration
+	expireAt int64  // Unix nano timestamp, 0 means no expiration
+	cost     int64  // cost of the item as reported by the cost function or WithAutoCost
+	version  uint64 // taken from the version counter of the cache on every modification
+	protect  bool   // set by SetProtected, the item is skipped by eviction
+	inserted int64  // Unix nano timestamp of the insertion, only recorded with WithAgeTracking
+	class    int    // index of the class set by SetClass plus 1, 0 if the item has no class
+
+	prev, next           int // indices of the neighbours in the eviction list
+	classPrev, classNext int // indices of the neighbours in the list of the class
 }
 
 // LRUCache implements a Least Recently Used cache with O(1) operations.
 type LRUCache[K comparable, V any] struct {
-	mu           sync.Mutex
+	mu           sync.RWMutex // only read-locked by Get with WithReadBuffer
 	size         uint
-	m            map[K]*list.Element // where the key-value pairs are stored
-	evictionList *list.List
+	m            map[K]int // where the key-value pairs are stored
+	evictionList lruList[K, V]
+	cost         int64         // total cost of all items, only tracked with a cost function or WithAutoCost
+	stopCh       chan struct{} // Channel to signal the expiration goroutine to stop
+	sweeper      *sweeper
+	removed      *sync.Cond // signalled whenever items are removed from the cache
+	stats        Stats
+	lastEvicted  K                        // key of the most recently evicted item, reported by SetReport
+	evicted      *keyRing[K]              // recently evicted keys, only recorded with WithEvictionHistory
+	throttled    map[K]*throttledWrite[V] // keys written by SetThrottled within their minimum interval
+	protected    int                      // number of items protected from eviction by SetProtected
+	tombstones   map[K]int64              // keys deleted by DeleteWithTombstone → Unix nano expiration of the tombstone
+	tombstoneLog []insertRecord[K]        // tombstones in the order they were recorded with their expiration, including stale ones
+	computing    Group[K, timedValue[V]]  // in-flight computations of GetOrCompute, one per key
+	inserts      []insertRecord[K]        // insertions in order, including stale ones, only recorded with WithAgeTracking
+	dirty        map[K]struct{}           // keys written or removed since the last DirtyKeys, only with WithDirtyTracking
+	reads        *readBuffer[K]           // hits not yet applied to the eviction list, only with WithReadBuffer
+	classes      []lruClass               // classes configured with WithClass, see SetClass
+	bulkLoading  bool                     // set by BulkLoad while eviction is deferred
+	writeBehind  *writeBehind[K, V]       // queue of written values configured by WithWriteBehind, or nil
+	dropped      uint64                   // events not sent because the channel of WithEventChannel was full
+	version      uint64                   // last version given to an item, see GetWithVersion
+	closed       bool
+	opts         options[K, V]
+}
+
+// insertRecord is the insertion of a key at a Unix nano timestamp. It is stale once the key has been removed,
+// or removed and inserted again.
+type insertRecord[K comparable] struct {
+	key K
+	at  int64
+}
+
+// timedValue is a value computed by GetOrComputeTimed together with the time the computation took.
+type timedValue[V any] struct {
+	value V
+	took  time.Duration
+}
+
+// throttledWrite tracks a key written by SetThrottled until its minimum interval has elapsed.
+type throttledWrite[V any] struct {
+	timer      *time.Timer // fires when the minimum interval since the last write has elapsed
+	pending    V           // latest value buffered during the interval
+	hasPending bool
+}
+
+// NewLRU creates a new LRU cache with the specified maximum size.
+// If size is 0, the cache will not store any items unless WithUnbounded is used; with WithStrictSizing, it panics instead.
+// If a cleanup interval is configured, a background goroutine removes expired keys until Close is called.
+func NewLRU[K comparable, V any](size uint, opts ...Option[K, V]) *LRUCache[K, V] {
+	o := applyOptions(opts)
+	c := &LRUCache[K, V]{
+		size:   o.capacity(size),
+		m:      make(map[K]int, o.initialCapacity),
+		stopCh: make(chan struct{}),
+		opts:   o,
+	}
+	c.evictionList.Init(o.initialCapacity)
+	c.classes = newClasses(o.classes)
+	c.evicted = newKeyRing[K](o.evictionHistory)
+	c.removed = sync.NewCond(&c.mu)
+	if o.readBuffer > 0 {
+		c.reads = newReadBuffer[K](o.readBuffer)
+	}
+	c.sweeper = c.opts.newSweeper(0)
+	if !registerGlobalSweep(c.stopCh, c.sweeper, c.sweep) && c.sweeper.currentInterval() > 0 {
+		go c.sweeper.run(c.stopCh, c.sweep)
+	}
+	c.opts.startReporter(c.stopCh, c.Stats)
+	c.opts.startPressureMonitor(c.stopCh, c.shed)
+	c.writeBehind = newWriteBehind(&c.opts)
+	if c.writeBehind != nil {
+		go c.writeBehind.run(c.stopCh)
+	}
+	return c
+}
+
+// NewLRUFrom creates a new LRU cache with the specified maximum size, filled with the entries of seed.
+// The internal map is presized for the seed entries unless WithInitialCapacity is given.
+// If the seed holds more than size entries, the cache keeps size of them; since map iteration order
+// is unspecified, which entries are kept is arbitrary.
+func NewLRUFrom[K comparable, V any](size uint, seed map[K]V, opts ...Option[K, V]) *LRUCache[K, V] {
+	c := NewLRU(size, withSeedCapacity(opts, len(seed), size)...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range seed {
+		c.set(k, v, 0)
+	}
+	return c
+}
+
+// Get retrieves the value associated with the given key from the cache.
+// If the key is not found or has expired, it returns (zero value of V, false).
+// Otherwise, it returns (value, true).
+func (c *LRUCache[K, V]) Get(k K) (v V, b bool) {
+	if c.opts.missHook != nil {
+		defer c.opts.observeMiss(k, &b) // runs after the lock is released
+	}
+	if c.reads != nil {
+		if v, b, done := c.getShared(k); done {
+			return v, b
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Get", time.Now())
+	}
+
+	lruItem, ok := c.get(k)
+	if !ok {
+		return
+	}
+
+	return c.opts.copyValue(lruItem.value), true
+}
+
+// getShared looks up the key under the read lock for Get with WithReadBuffer and records a hit in the read buffer.
+// It reports done as false for an expired key, which Get must handle under the exclusive lock.
+// The statistics are updated atomically since other Gets may hold the read lock as well.
+func (c *LRUCache[K, V]) getShared(k K) (v V, b, done bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Get", time.Now())
+	}
+
+	i, ok := c.m[k]
+	if !ok {
+		atomic.AddUint64(&c.stats.Misses, 1)
+		return v, false, true
+	}
+	item := c.evictionList.at(i)
+	if item.expireAt > 0 && item.expireAt < c.opts.expiryNow() {
+		return v, false, false
+	}
+
+	atomic.AddUint64(&c.stats.Hits, 1)
+	c.reads.record(i, k)
+	return c.opts.copyValue(item.value), true, true
+}
+
+// applyReads moves the items read by Get since the last call to the front of the eviction list,
+// skipping items that have been removed since.
+func (c *LRUCache[K, V]) applyReads() {
+	if c.reads == nil {
+		return
+	}
+	c.reads.drain(func(r readRecord[K]) {
+		if i, ok := c.m[r.key]; ok && i == r.i {
+			c.touch(i)
+		}
+	})
+}
+
+// HasAndTouch reports whether the key exists and has not expired. If it does, the key is marked
+// as recently used, like Get, without copying its value. It counts as a lookup in Stats.
+func (c *LRUCache[K, V]) HasAndTouch(k K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.get(k)
+	return ok
+}
+
+// GetOrDefault retrieves the value associated with the given key like Get,
+// or returns def if the key is not found or has expired.
+// It counts as an access like Get, marking a found key as recently used.
+func (c *LRUCache[K, V]) GetOrDefault(k K, def V) V {
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	return def
+}
+
+// GetWithExpiration retrieves the value associated with the given key together with its expiration time.
+// If the key is not found or has expired, it returns (zero value of ValueTTL[V], false).
+func (c *LRUCache[K, V]) GetWithExpiration(k K) (v ValueTTL[V], b bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lruItem, ok := c.get(k)
+	if !ok {
+		return
+	}
+
+	return ValueTTL[V]{Value: c.opts.copyValue(lruItem.value), ExpireAt: expireTime(lruItem.expireAt)}, true
+}
+
+// TTL returns the remaining time to live of the key, or NoExpiry if it never expires. It returns false
+// if the key is not found or has expired. It neither copies the value nor marks the key as recently used.
+func (c *LRUCache[K, V]) TTL(k K) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.m[k]
+	if !ok {
+		return 0, false
+	}
+	return remainingTTL(c.evictionList.at(i).expireAt, c.opts.expiryNow())
+}
+
+// GetManyWithExpiration retrieves the values of the given keys together with their expiration times under a single lock.
+// Keys that are not found or have expired are omitted from the returned map.
+// Each found key is marked as recently used.
+func (c *LRUCache[K, V]) GetManyWithExpiration(keys []K) map[K]ValueTTL[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V], len(keys))
+	for _, k := range keys {
+		if lruItem, ok := c.get(k); ok {
+			m[k] = ValueTTL[V]{Value: c.opts.copyValue(lruItem.value), ExpireAt: expireTime(lruItem.expireAt)}
+		}
+	}
+
+	return m
+}
+
+// GetManyPartial retrieves the values of the given keys under a single lock and returns the keys that are
+// not found or have expired separately, in the order given, e.g. to load only the missing keys from a backing store.
+// Each found key is marked as recently used.
+func (c *LRUCache[K, V]) GetManyPartial(keys []K) (found map[K]V, missing []K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found = make(map[K]V, len(keys))
+	for _, k := range keys {
+		if lruItem, ok := c.get(k); ok {
+			found[k] = c.opts.copyValue(lruItem.value)
+		} else {
+			missing = append(missing, k)
+		}
+	}
+
+	return found, missing
+}
+
+// GetState retrieves the value associated with the given key and reports whether it is live, expired or absent.
+// For an expired key it returns the last value and removes the key. A live key is marked as recently used.
+func (c *LRUCache[K, V]) GetState(k K) (v V, p Presence) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, ok := c.m[k]; ok {
+		item := c.evictionList.at(i)
+		if item.expireAt > 0 && item.expireAt < c.opts.expiryNow() {
+			v = item.value
+			c.removeExpired(i)
+			c.stats.Misses++
+			return c.opts.copyValue(v), Expired
+		}
+	}
+
+	item, ok := c.get(k)
+	if !ok {
+		return v, Absent
+	}
+	return c.opts.copyValue(item.value), Live
+}
+
+// get returns the non-expired item for the key and marks it as recently used.
+// Expired items are removed unless WithRetainExpired is used.
+func (c *LRUCache[K, V]) get(k K) (*lruItem[K, V], bool) {
+	i, ok := c.m[k]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	item := c.evictionList.at(i)
+	if item.expireAt > 0 && item.expireAt < c.opts.expiryNow() {
+		c.removeExpired(i)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.touch(i)
+	return item, true
+}
+
+// GetWithVersion retrieves the value associated with the given key together with its version.
+// The version changes every time the value of the key is modified and can be passed to SetWithVersion.
+// Versions are taken from a counter of the cache, so a key that is deleted and inserted again never gets
+// a version it had before, and a stale version is always rejected.
+// If the key is not found or has expired, it returns (zero value of V, 0, false).
+func (c *LRUCache[K, V]) GetWithVersion(k K) (v V, version uint64, b bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lruItem, ok := c.get(k)
+	if !ok {
+		return
+	}
+
+	return c.opts.copyValue(lruItem.value), lruItem.version, true
+}
+
+// SetWithVersion sets the key like Set, but only if its current version equals expectedVersion.
+// A key that does not exist or is expired has version 0, so an expectedVersion of 0 inserts a new key.
+// It returns true if the value was set, in which case the key gets a new version.
+func (c *LRUCache[K, V]) SetWithVersion(k K, v V, expectedVersion uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var version uint64
+	if i, ok := c.m[k]; ok {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= c.opts.expiryNow() {
+			version = item.version
+		} else {
+			c.removeElement(i, ReasonExpired)
+		}
+	}
+
+	if version != expectedVersion {
+		return false
+	}
+	return c.set(k, v, 0)
+}
+
+// nextVersion returns a version that no item of the cache has had before.
+func (c *LRUCache[K, V]) nextVersion() uint64 {
+	c.version++
+	return c.version
+}
+
+// GetOrCompute returns the value of the key, or computes it with compute and stores it if the key is missing or expired.
+// compute runs without holding the cache lock, so computing one key blocks neither other operations
+// nor the computation of other keys. Concurrent calls for the same key wait for a single computation
+// and share its result. If compute returns an error, the error is returned and nothing is stored.
+func (c *LRUCache[K, V]) GetOrCompute(k K, compute func(k K) (V, error)) (V, error) {
+	v, _, err := c.GetOrComputeTimed(k, func() (V, error) {
+		return compute(k)
+	})
+	return v, err
+}
+
+// GetOrComputeTimed works like GetOrCompute and also returns how long loader took, e.g. to record
+// the latency of a backend. The duration is zero if the value was found in the cache. Concurrent calls
+// for the same key share a single computation and all receive its duration, which is returned even if
+// loader fails.
+func (c *LRUCache[K, V]) GetOrComputeTimed(k K, loader func() (V, error)) (V, time.Duration, error) {
+	if v, ok := c.Get(k); ok {
+		return v, 0, nil
+	}
+
+	r, err, _ := c.computing.Do(k, func() (timedValue[V], error) {
+		start := time.Now()
+		v, err := loader()
+		r := timedValue[V]{value: v, took: time.Since(start)}
+		if err == nil {
+			c.Set(k, v)
+		}
+		return r, err
+	})
+	return r.value, r.took, err
+}
+
+// GetAll retrieves all key-value pairs from the cache.
+// It returns a map containing all the key-value pairs that are not expired.
+func (c *LRUCache[K, V]) GetAll() map[K]V {
+	m := make(map[K]V)
+	c.GetAllInto(m)
+	return m
+}
+
+// GetAllInto clears dst and fills it with all the key-value pairs of the cache that are not expired, like GetAll.
+// Reusing dst across calls saves allocating and growing a new map each time.
+func (c *LRUCache[K, V]) GetAllInto(dst map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(dst)
+	now := c.opts.expiryNow()
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
+			dst[k] = c.opts.copyValue(item.value)
+		} else if c.opts.eagerScanCleanup {
+			c.removeExpired(i)
+		}
+	}
+}
+
+// GetWhere retrieves the non-expired key-value pairs of the cache for which pred returns true.
+// pred is evaluated under the cache lock and must not call methods of the cache.
+func (c *LRUCache[K, V]) GetWhere(pred func(k K, v V) bool) map[K]V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]V)
+	now := c.opts.expiryNow()
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if (item.expireAt == 0 || item.expireAt >= now) && pred(k, item.value) {
+			m[k] = c.opts.copyValue(item.value)
+		}
+	}
+
+	return m
+}
+
+// Range calls f for each non-expired key-value pair of the cache, in no particular order, until f returns false.
+// Unlike GetAll, it does not copy the entries into a map. f is called under the cache lock and must not call
+// methods of the cache.
+func (c *LRUCache[K, V]) Range(f func(k K, v V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
+			if !f(k, item.value) {
+				return
+			}
+		} else if c.opts.eagerScanCleanup {
+			c.removeExpired(i)
+		}
+	}
+}
+
+// GetAllOrdered retrieves all non-expired key-value pairs from the cache,
+// ordered from the most recently used to the least recently used.
+// It does not mark the keys as recently used.
+func (c *LRUCache[K, V]) GetAllOrdered() []Entry[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applyReads()
+
+	entries := make([]Entry[K, V], 0, len(c.m))
+	now := c.opts.expiryNow()
+	for i := c.evictionList.Front(); i != 0; i = c.evictionList.Next(i) {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
+			entries = append(entries, Entry[K, V]{Key: item.key, Value: c.opts.copyValue(item.value)})
+		}
+	}
+
+	return entries
+}
+
+// GetAllSorted retrieves all non-expired key-value pairs from the cache sorted by key according to less,
+// e.g. to compare the contents of the cache in tests or to export them deterministically.
+func (c *LRUCache[K, V]) GetAllSorted(less func(a, b K) bool) []Entry[K, V] {
+	return sortedEntries(c.GetAll(), less)
+}
+
+// GetAllWithExpiration retrieves all key-value pairs from the cache together with their expiration times.
+// It returns a map containing all the key-value pairs that are not expired.
+func (c *LRUCache[K, V]) GetAllWithExpiration() map[K]ValueTTL[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V])
+	now := c.opts.expiryNow()
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
+			m[k] = ValueTTL[V]{Value: c.opts.copyValue(item.value), ExpireAt: expireTime(item.expireAt)}
+		}
+	}
+
+	return m
+}
+
+// Set adds the key-value pair to the cache.
+func (c *LRUCache[K, V]) Set(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Set", time.Now())
+	}
+
+	c.set(k, v, 0)
+}
+
+// BulkLoad adds the key-value pairs to the cache under a single lock, e.g. to warm up the cache.
+// Unlike calling Set for each pair, it does not evict while loading: the cache may hold more entries
+// than its size until all pairs are inserted, and is then trimmed once, evicting the least recently used
+// entries. The loaded pairs are more recent than the existing entries, so existing entries are evicted first;
+// among the loaded pairs, which are inserted in map order, the ones inserted first are evicted.
+func (c *LRUCache[K, V]) BulkLoad(items map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.bulkLoading = true
+	for k, v := range items {
+		c.set(k, v, 0)
+	}
+	c.bulkLoading = false
+
+	if over := len(c.m) - int(min(c.size, uint(math.MaxInt))); over > 0 {
+		c.evict(over)
+	}
+	c.evictOverLimits()
+}
+
+// TrySet adds the key-value pair to the cache like Set, but reports why the pair could not be stored.
+// Unlike Set, it never evicts: it returns ErrCacheFull if storing the pair would exceed the capacity of the cache,
+// the limit of WithMaxKeys or the budget of WithMaxCost, where Set would evict other entries instead.
+// Updates of existing keys are only rejected if their new cost exceeds the budget.
+// It returns ErrValueTooLarge if the value exceeds the size configured with WithMaxValueSize,
+// ErrTombstoned if the key was deleted with DeleteWithTombstone, and ErrCacheClosed if the cache has been closed.
+func (c *LRUCache[K, V]) TrySet(k K, v V) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrCacheClosed
+	}
+	if c.opts.tooLarge(v) {
+		return ErrValueTooLarge
+	}
+	if c.tombstoned(k) && !c.opts.tombstoneWrites {
+		return ErrTombstoned
+	}
+	if c.wouldEvict(k, v) {
+		return ErrCacheFull
+	}
+
+	c.set(k, v, 0)
+	return nil
+}
+
+// wouldEvict reports whether setting the key to v would evict other entries to respect the capacity of the cache,
+// the limit of WithMaxKeys or the budget of WithMaxCost.
+func (c *LRUCache[K, V]) wouldEvict(k K, v V) bool {
+	i, exists := c.m[k]
+	if !exists {
+		if high, _ := c.opts.watermarks(c.size); uint(len(c.m)) >= high {
+			return true
+		}
+		if c.opts.maxKeys > 0 && uint(len(c.m)) >= c.opts.maxKeys {
+			return true
+		}
+	}
+	if c.opts.maxCost <= 0 {
+		return false
+	}
+	cost := c.cost + c.opts.entryCost(k, v)
+	if exists {
+		cost -= c.evictionList.at(i).cost
+	}
+	return cost > c.opts.maxCost
+}
+
+// SetThrottled adds the key-value pair to the cache like Set, but writes each key at most once
+// per minInterval. The first write of a key is applied immediately; values set within minInterval
+// of the last write are buffered, and only the latest of them is written by a background flush
+// once the interval has elapsed. Delete, Purge, Drain and Close discard buffered values, and any other
+// write of the key, e.g. with Set, discards its buffered value, so that it is not overwritten by an older value.
+// This coalesces bursts of updates to the same key into a few writes.
+func (c *LRUCache[K, V]) SetThrottled(k K, v V, minInterval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	if w, ok := c.throttled[k]; ok {
+		w.pending = v
+		w.hasPending = true
+		return
+	}
+
+	c.set(k, v, 0)
+	if minInterval <= 0 {
+		return
+	}
+	if c.throttled == nil {
+		c.throttled = make(map[K]*throttledWrite[V])
+	}
+	w := &throttledWrite[V]{}
+	w.timer = time.AfterFunc(minInterval, func() { c.flushThrottled(k, w, minInterval) })
+	c.throttled[k] = w
+}
+
+// flushThrottled writes the value buffered for the key, if any, and starts a new interval.
+// Without a buffered value the key is no longer throttled.
+func (c *LRUCache[K, V]) flushThrottled(k K, w *throttledWrite[V], minInterval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.throttled[k] != w {
+		// The write was discarded by Delete, Purge or Close
+		return
+	}
+	if !w.hasPending {
+		delete(c.throttled, k)
+		return
+	}
+
+	c.set(k, w.pending, 0)
+	w.pending = *new(V)
+	w.hasPending = false
+	w.timer.Reset(minInterval)
+}
+
+// dropThrottled discards the buffered value of the key, if any.
+func (c *LRUCache[K, V]) dropThrottled(k K) {
+	if w, ok := c.throttled[k]; ok {
+		w.timer.Stop()
+		delete(c.throttled, k)
+	}
+}
+
+// discardPending discards the value buffered for the key by SetThrottled, if any, without ending its interval,
+// so that a later write of the key is not overwritten by the older buffered value.
+func (c *LRUCache[K, V]) discardPending(k K) {
+	if w, ok := c.throttled[k]; ok {
+		w.pending = *new(V)
+		w.hasPending = false
+	}
+}
+
+// dropAllThrottled discards all buffered values.
+func (c *LRUCache[K, V]) dropAllThrottled() {
+	for _, w := range c.throttled {
+		w.timer.Stop()
+	}
+	c.throttled = nil
+}
+
+// SetReport adds the key-value pair to the cache like Set and reports whether an entry was evicted
+// to make room for it, and the key of that entry. If several entries were evicted, e.g. with
+// WithEvictionBatch or WithMaxCost, the key of the last one is reported.
+func (c *LRUCache[K, V]) SetReport(k K, v V) (evicted bool, evictedKey K) {
+	return c.SetWithTimeoutReport(k, v, 0)
+}
+
+// SetWithTimeoutReport adds the key-value pair to the cache like SetWithTimeout and reports
+// whether an entry was evicted to make room for it, and the key of that entry, like SetReport.
+func (c *LRUCache[K, V]) SetWithTimeoutReport(k K, v V, t time.Duration) (evicted bool, evictedKey K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	before := c.stats.Evictions
+	c.set(k, v, t)
+	if c.stats.Evictions == before {
+		return false, evictedKey
+	}
+
+	evictedKey = c.lastEvicted
+	c.lastEvicted = *new(K)
+	return true, evictedKey
+}
+
+// GetSet adds the key-value pair to the cache like SetWithTimeout and returns the previous value of the key
+// if it existed and had not expired, in one atomic step. Like Get, the lookup counts in Stats.
+func (c *LRUCache[K, V]) GetSet(k K, v V, ttl time.Duration) (old V, existed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.get(k); ok {
+		old, existed = c.opts.copyValue(item.value), true
+	}
+	c.set(k, v, ttl)
+	return old, existed
+}
+
+// SetProtected adds the key-value pair to the cache like SetWithTimeout and protects it from eviction
+// until Unprotect is called. Protected entries still expire and can be deleted.
+// Eviction skips protected entries, so once every entry is protected, new keys are no longer stored:
+// SetProtected returns false and Set drops them. It returns true if the pair was stored.
+// Eviction scans past the protected entries at the back of the eviction list, so protect few entries at a time.
+func (c *LRUCache[K, V]) SetProtected(k K, v V, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.set(k, v, ttl) {
+		return false
+	}
+	if item := c.evictionList.at(c.m[k]); !item.protect {
+		item.protect = true
+		c.protected++
+	}
+	return true
+}
+
+// SetClass adds the key-value pair to the cache like SetWithTimeout, with the TTL of the class,
+// and assigns the entry to the class configured with WithClass. Each class evicts its own least recently used
+// entries once it holds more entries than its capacity, so a flood of entries of one class cannot evict the
+// entries of another. All entries still share the size of the cache, which evicts the least recently used entry
+// regardless of its class; keep the sum of the class capacities within the size to fully isolate the classes.
+// A key keeps its class when it is set again with Set. If the class is not configured, the pair is set like Set
+// without a class.
+func (c *LRUCache[K, V]) SetClass(k K, v V, class string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ci := classIndex(c.classes, class)
+	if ci == 0 {
+		c.set(k, v, 0)
+		return
+	}
+	cl := &c.classes[ci-1]
+	if !c.set(k, v, cl.ttl) {
+		return
+	}
+
+	i := c.m[k]
+	item := c.evictionList.at(i)
+	if item.class != ci {
+		if item.class != 0 {
+			c.evictionList.classUnlink(&c.classes[item.class-1], i)
+		}
+		item.class = ci
+		c.evictionList.classLink(cl, i)
+	}
+	c.evictClass(cl)
+}
+
+// evictClass evicts the least recently used entries of the class until it is within its capacity.
+// The most recently used entry of the class and protected entries are never evicted.
+func (c *LRUCache[K, V]) evictClass(cl *lruClass) {
+	for cl.full() {
+		b := cl.back
+		for b != 0 && c.evictionList.at(b).protect {
+			b = c.evictionList.at(b).classPrev
+		}
+		if b == 0 || b == cl.front {
+			return
+		}
+		c.evictElement(b)
+	}
+}
+
+// touch marks the item at index i as the most recently used, in the eviction list and in the list of its class.
+func (c *LRUCache[K, V]) touch(i int) {
+	c.evictionList.MoveToFront(i)
+	if class := c.evictionList.at(i).class; class != 0 {
+		c.evictionList.classMoveToFront(&c.classes[class-1], i)
+	}
+}
+
+// resetClasses empties the lists of all classes, once the eviction list has been cleared.
+func (c *LRUCache[K, V]) resetClasses() {
+	for i := range c.classes {
+		cl := &c.classes[i]
+		cl.front, cl.back, cl.len = 0, 0, 0
+	}
+}
+
+// Unprotect makes the entry of the key evictable again after SetProtected. It does nothing if the key
+// does not exist or is not protected.
+func (c *LRUCache[K, V]) Unprotect(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, ok := c.m[k]; ok {
+		if item := c.evictionList.at(i); item.protect {
+			item.protect = false
+			c.protected--
+		}
+	}
+}
+
+// SetWithTimeout adds the key-value pair to the cache with a specified expiration time.
+func (c *LRUCache[K, V]) SetWithTimeout(k K, v V, t time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("SetWithTimeout", time.Now())
+	}
+
+	c.set(k, v, t)
+}
+
+// SetWithTimeoutIfSooner adds the key-value pair to the cache with a specified expiration time,
+// but only if the new expiration would be earlier than the current one.
+// If the key does not exist or is expired, it behaves like SetWithTimeout.
+func (c *LRUCache[K, V]) SetWithTimeoutIfSooner(k K, v V, t time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setIf(k, v, t, true)
+}
+
+// SetWithTimeoutIfLater adds the key-value pair to the cache with a specified expiration time,
+// but only if the new expiration would be later than the current one.
+// If the key does not exist or is expired, it behaves like SetWithTimeout.
+func (c *LRUCache[K, V]) SetWithTimeoutIfLater(k K, v V, t time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setIf(k, v, t, false)
+}
+
+func (c *LRUCache[K, V]) setIf(k K, v V, t time.Duration, sooner bool) {
+	t, ok := c.opts.ttl(t)
+	if !ok {
+		return
+	}
+
+	if i, ok := c.m[k]; ok {
+		item := c.evictionList.at(i)
+		now := c.opts.now()
+		if item.expireAt == 0 || item.expireAt >= c.opts.expiryNow() {
+			var expireAt int64
+			if t > 0 {
+				expireAt = now.Add(t).UnixNano()
+			}
+			if sooner && !expiresBefore(expireAt, item.expireAt) {
+				return
+			}
+			if !sooner && !expiresBefore(item.expireAt, expireAt) {
+				return
+			}
+		}
+	}
+
+	c.set(k, v, t)
+}
+
+// NotFoundSet adds the key-value pair to the cache only if the key does not exist or is expired.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *LRUCache[K, V]) NotFoundSet(k K, v V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.notFoundSet(k, v, 0)
+}
+
+// NotFoundSetWithTimeout adds the key-value pair to the cache only if the key does not exist or is expired.
+// It sets an expiration time for the key-value pair.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *LRUCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.notFoundSet(k, v, t)
+}
+
+// NotFoundSetMany adds each key-value pair to the cache only if the key does not exist or is expired.
+// The whole batch is applied under a single lock.
+// It returns the keys that were added to the cache, in no particular order.
+func (c *LRUCache[K, V]) NotFoundSetMany(items map[K]V) []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var added []K
+	for k, v := range items {
+		if c.notFoundSet(k, v, 0) {
+			added = append(added, k)
+		}
+	}
+	return added
+}
+
+func (c *LRUCache[K, V]) notFoundSet(k K, v V, t time.Duration) bool {
+	if c.closed {
+		return false
+	}
+
+	if i, ok := c.m[k]; ok {
+		item := c.evictionList.at(i)
+		// Check if existing key is expired
+		if item.expireAt == 0 || item.expireAt >= c.opts.expiryNow() {
+			if item.expireAt > 0 && c.opts.notFoundRefresh {
+				c.refreshTTL(item, t)
+			}
+			return false
+		}
+		// Key exists but is expired, delete it first
+		c.removeElement(i, ReasonExpired)
+	}
+
+	return c.set(k, v, t)
+}
+
+// refreshTTL sets the expiration of the live item to t from now for WithNotFoundSetRefreshesTTL, or to the TTL
+// of its class if t is 0. Without a TTL, or with one rejected by WithRejectShortTTL, the item is left unchanged.
+func (c *LRUCache[K, V]) refreshTTL(item *lruItem[K, V], t time.Duration) {
+	if t == 0 && item.class != 0 {
+		t = c.classes[item.class-1].ttl
+	}
+	if t, ok := c.opts.ttl(t); ok && t > 0 {
+		item.expireAt = c.opts.now().Add(t).UnixNano()
+	}
+}
+
+// LoadOrStore returns the existing value for the key if it exists and is not expired, and marks it as recently used.
+// Otherwise, it stores the given value and returns it.
+// The loaded result is true if the value was loaded, false if stored. If the value could not be stored,
+// e.g. with WithRejectOnFull when the cache is full, it returns the zero value and false, like SetIfAbsent.
+func (c *LRUCache[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, ok := c.m[k]; ok {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= c.opts.expiryNow() {
+			c.touch(i)
+			return c.opts.copyValue(item.value), true
+		}
+	}
+
+	if !c.set(k, v, 0) {
+		return actual, false
+	}
+	return v, false
+}
+
+// SetIfAbsent stores the value if the key does not exist or is expired and returns (v, true).
+// If the key exists, it returns the current value and false without modifying it, and marks the key as recently used.
+// If the value could not be stored, e.g. because the cache is closed, it returns the zero value and false.
+func (c *LRUCache[K, V]) SetIfAbsent(k K, v V) (current V, set bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if lruItem, ok := c.get(k); ok {
+		return c.opts.copyValue(lruItem.value), false
+	}
+	if !c.set(k, v, 0) {
+		return current, false
+	}
+	return v, true
+}
+
+// ReplaceIfPresent updates the value of the key only if it exists and is not expired.
+// The existing expiration time is preserved and the key is marked as recently used.
+// It returns true if the value was replaced, otherwise false.
+func (c *LRUCache[K, V]) ReplaceIfPresent(k K, v V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.m[k]
+	if !ok || c.opts.tooLarge(v) {
+		return false
+	}
+
+	item := c.evictionList.at(i)
+	if item.expireAt > 0 && item.expireAt < c.opts.expiryNow() {
+		c.removeExpired(i)
+		return false
+	}
+
+	c.discardPending(k)
+	item.value = v
+	item.version = c.nextVersion()
+	cost := c.opts.entryCost(k, v)
+	c.cost += cost - item.cost
+	item.cost = cost
+	c.touch(i)
+	c.markDirty(k)
+	if c.writeBehind != nil {
+		c.writeBehind.enqueue(k, v)
+	}
+	c.evictOverLimits()
+	return true
+}
+
+// Rename moves the entry of oldKey to newKey under a single lock, keeping its value, expiration time,
+// version and position in the eviction order. An existing entry of newKey is overwritten.
+// It returns false if oldKey does not exist or has expired, or if newKey is tombstoned (see DeleteWithTombstone).
+func (c *LRUCache[K, V]) Rename(oldKey, newKey K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.m[oldKey]
+	if !ok {
+		return false
+	}
+	item := c.evictionList.at(i)
+	if item.expireAt > 0 && item.expireAt < c.opts.expiryNow() {
+		c.removeExpired(i)
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if c.tombstoned(newKey) {
+		if !c.opts.tombstoneWrites {
+			return false
+		}
+		delete(c.tombstones, newKey)
+	}
+
+	if j, ok := c.m[newKey]; ok {
+		c.removeElement(j, ReasonDeleted)
+	}
+	c.dropThrottled(oldKey)
+	delete(c.m, oldKey)
+	item.key = newKey
+	c.m[newKey] = i
+	if c.opts.trackAge {
+		c.renameInsert(oldKey, newKey, item.inserted)
+	}
+	c.markDirty(oldKey)
+	c.markDirty(newKey)
+	if c.writeBehind != nil {
+		c.writeBehind.enqueue(newKey, item.value)
+	}
+	return true
+}
+
+// Delete removes the key-value pair associated with the given key from the cache.
+func (c *LRUCache[K, V]) Delete(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Delete", time.Now())
+	}
+
+	c.dropThrottled(k)
+	c.delete(k)
+}
+
+// DeleteWithTombstone removes the key-value pair like Delete and records a tombstone for the key,
+// e.g. to replicate a delete: until the tombstone expires after ttl, writes of the key are rejected,
+// so a late write cannot bring the key back. With WithTombstoneOverwrite, a write succeeds and clears the tombstone.
+// Expired tombstones are removed by the background cleanup and by the writes that check them. At most as many tombstones
+// are kept as the cache can hold entries: beyond that, the tombstone recorded first is dropped even if it has not expired,
+// so a cache created with WithUnbounded keeps every tombstone until it expires.
+func (c *LRUCache[K, V]) DeleteWithTombstone(k K, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropThrottled(k)
+	c.delete(k)
+	if ttl <= 0 {
+		return
+	}
+
+	now := c.opts.now().UnixNano()
+	if c.tombstones == nil {
+		c.tombstones = make(map[K]int64)
+	}
+	c.tombstones[k] = now + int64(ttl)
+	c.recordTombstone(k, now+int64(ttl))
+	if uint(len(c.tombstones)) > c.size {
+		c.trimTombstones(now)
+	}
+}
+
+// recordTombstone appends the tombstone of the key to the tombstone log, dropping the stale records
+// once they make up most of the log.
+func (c *LRUCache[K, V]) recordTombstone(k K, expireAt int64) {
+	if len(c.tombstoneLog) > 2*len(c.tombstones)+16 {
+		c.tombstoneLog = slices.DeleteFunc(c.tombstoneLog, c.staleTombstone)
+	}
+	c.tombstoneLog = append(c.tombstoneLog, insertRecord[K]{key: k, at: expireAt})
 }
 
-// NewLRU creates a new LRU cache with the specified maximum size.
-// If size is 0, the cache will not store any items.
-func NewLRU[K comparable, V any](size uint) *LRUCache[K, V] {
-	return &LRUCache[K, V]{
-		size:         size,
-		m:            make(map[K]*list.Element),
-		evictionList: list.New(),
+// trimTombstones removes tombstones in the order they were recorded until no more tombstones are kept than
+// the cache can hold entries: first the ones that expired before now, then the oldest unexpired one - O(1) amortized.
+func (c *LRUCache[K, V]) trimTombstones(now int64) {
+	for len(c.tombstoneLog) > 0 {
+		r := c.tombstoneLog[0]
+		live := !c.staleTombstone(r)
+		if live && r.at >= now && uint(len(c.tombstones)) <= c.size {
+			return
+		}
+		c.tombstoneLog = c.tombstoneLog[1:]
+		if live {
+			delete(c.tombstones, r.key)
+		}
 	}
 }
 
-// Get retrieves the value associated with the given key from the cache.
-// If the key is not found or has expired, it returns (zero value of V, false).
-// Otherwise, it returns (value, true).
-func (c *LRUCache[K, V]) Get(k K) (v V, b bool) {
+// staleTombstone reports whether the record of the tombstone log no longer belongs to a tombstone of the cache.
+func (c *LRUCache[K, V]) staleTombstone(r insertRecord[K]) bool {
+	expireAt, ok := c.tombstones[r.key]
+	return !ok || expireAt != r.at
+}
+
+// IsTombstoned reports whether the key has an unexpired tombstone recorded by DeleteWithTombstone.
+func (c *LRUCache[K, V]) IsTombstoned(k K) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	item, ok := c.m[k]
+	return c.tombstoned(k)
+}
+
+// tombstoned reports whether the key has an unexpired tombstone and removes it if it has expired.
+func (c *LRUCache[K, V]) tombstoned(k K) bool {
+	expireAt, ok := c.tombstones[k]
 	if !ok {
-		return
+		return false
+	}
+	if expireAt < c.opts.now().UnixNano() {
+		delete(c.tombstones, k)
+		return false
 	}
+	return true
+}
 
-	lruItem := item.Value.(*lruItem[K, V])
-	if lruItem.expireAt > 0 && lruItem.expireAt < time.Now().UnixNano() {
-		delete(c.m, k)
-		c.evictionList.Remove(item)
-		return
+// pruneTombstones removes the tombstones that expired before now.
+func (c *LRUCache[K, V]) pruneTombstones(now int64) {
+	for k, expireAt := range c.tombstones {
+		if expireAt < now {
+			delete(c.tombstones, k)
+		}
 	}
+	c.tombstoneLog = slices.DeleteFunc(c.tombstoneLog, c.staleTombstone)
+}
 
-	c.evictionList.MoveToFront(item)
+// GetExpired returns the value of the key if its entry has expired but has not been removed yet,
+// which WithRetainExpired guarantees until PurgeExpired runs. It returns false for live and missing keys.
+// It does not mark the entry as recently used.
+func (c *LRUCache[K, V]) GetExpired(k K) (v V, b bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return lruItem.value, true
+	if i, ok := c.m[k]; ok {
+		item := c.evictionList.at(i)
+		if item.expireAt > 0 && item.expireAt < c.opts.expiryNow() {
+			return c.opts.copyValue(item.value), true
+		}
+	}
+	return v, false
 }
 
-// GetAll retrieves all key-value pairs from the cache.
-// It returns a map containing all the key-value pairs that are not expired.
-func (c *LRUCache[K, V]) GetAll() map[K]V {
+// PurgeExpired removes all expired entries and returns the number of entries removed.
+func (c *LRUCache[K, V]) PurgeExpired() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	m := make(map[K]V)
-	now := time.Now().UnixNano()
-	for k, v := range c.m {
-		lruItem := v.Value.(*lruItem[K, V])
-		if lruItem.expireAt == 0 || lruItem.expireAt >= now {
-			m[k] = lruItem.value
+	now := c.opts.expiryNow()
+	removed := 0
+	for _, i := range c.m {
+		if item := c.evictionList.at(i); item.expireAt > 0 && item.expireAt < now {
+			c.removeElement(i, ReasonExpired)
+			removed++
 		}
 	}
+	return removed
+}
 
-	return m
+// removeExpired removes the expired item at index i, unless WithRetainExpired is used.
+func (c *LRUCache[K, V]) removeExpired(i int) {
+	if !c.opts.retainExpired {
+		c.removeElement(i, ReasonExpired)
+	}
 }
 
-// Set adds the key-value pair to the cache.
-func (c *LRUCache[K, V]) Set(k K, v V) {
+// DirtyKeys returns the keys whose values have been written or removed since the last call, or since the cache
+// was created, and starts tracking anew. Evicted and expired keys count as removed once they leave the cache;
+// refreshing the expiration of a key does not mark it. It requires WithDirtyTracking and returns nil without it.
+// The order of keys in the slice is not guaranteed.
+func (c *LRUCache[K, V]) DirtyKeys() []K {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.set(k, v, 0)
+	if len(c.dirty) == 0 {
+		return nil
+	}
+	keys := make([]K, 0, len(c.dirty))
+	for k := range c.dirty {
+		keys = append(keys, k)
+	}
+	c.dirty = nil
+	return keys
 }
 
-// SetWithTimeout adds the key-value pair to the cache with a specified expiration time.
-func (c *LRUCache[K, V]) SetWithTimeout(k K, v V, t time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// markDirty records that the value of the key has been written or removed, with WithDirtyTracking.
+func (c *LRUCache[K, V]) markDirty(k K) {
+	if !c.opts.trackDirty {
+		return
+	}
+	if c.dirty == nil {
+		c.dirty = make(map[K]struct{})
+	}
+	c.dirty[k] = struct{}{}
+}
 
-	c.set(k, v, t)
+// markAllDirty marks every key of the cache as dirty, before the cache is cleared.
+func (c *LRUCache[K, V]) markAllDirty() {
+	if !c.opts.trackDirty {
+		return
+	}
+	for k := range c.m {
+		c.markDirty(k)
+	}
 }
 
-// NotFoundSet adds the key-value pair to the cache only if the key does not exist or is expired.
-// It returns true if the key was added to the cache, otherwise false.
-func (c *LRUCache[K, V]) NotFoundSet(k K, v V) bool {
+// AgeRange returns the insertion times of the oldest and newest entries of the cache, including expired entries
+// that have not been removed yet. It requires WithAgeTracking and returns zero times without it or if the cache is empty.
+func (c *LRUCache[K, V]) AgeRange() (oldest, newest time.Time) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if item, ok := c.m[k]; ok {
-		lruItem := item.Value.(*lruItem[K, V])
-		// Check if existing key is expired
-		if lruItem.expireAt == 0 || lruItem.expireAt >= time.Now().UnixNano() {
-			return false
+	// Drop the stale records at both ends, the remaining ends are the oldest and newest entries
+	for len(c.inserts) > 0 && c.staleInsert(c.inserts[0]) {
+		c.inserts = c.inserts[1:]
+	}
+	for len(c.inserts) > 0 && c.staleInsert(c.inserts[len(c.inserts)-1]) {
+		c.inserts = c.inserts[:len(c.inserts)-1]
+	}
+	if len(c.inserts) == 0 {
+		return oldest, newest
+	}
+	return time.Unix(0, c.inserts[0].at), time.Unix(0, c.inserts[len(c.inserts)-1].at)
+}
+
+// recordInsert appends the insertion of the key to the insertion records. Stale records are dropped
+// once they make up more than half of the records, so the records stay proportional to the cache.
+func (c *LRUCache[K, V]) recordInsert(k K, at int64) {
+	if len(c.inserts) > 2*len(c.m)+16 {
+		c.inserts = slices.DeleteFunc(c.inserts, c.staleInsert)
+	}
+	c.inserts = append(c.inserts, insertRecord[K]{key: k, at: at})
+}
+
+// renameInsert moves the insertion record of the entry inserted at the given time from oldKey to newKey.
+func (c *LRUCache[K, V]) renameInsert(oldKey, newKey K, at int64) {
+	for j := len(c.inserts) - 1; j >= 0; j-- {
+		if r := &c.inserts[j]; r.key == oldKey && r.at == at {
+			r.key = newKey
+			return
 		}
-		// Key exists but is expired, delete it first
-		delete(c.m, k)
-		c.evictionList.Remove(item)
 	}
+}
 
-	c.set(k, v, 0)
-	return true
+// staleInsert reports whether the insertion record no longer belongs to an entry of the cache.
+func (c *LRUCache[K, V]) staleInsert(r insertRecord[K]) bool {
+	i, ok := c.m[r.key]
+	return !ok || c.evictionList.at(i).inserted != r.at
 }
 
-// NotFoundSetWithTimeout adds the key-value pair to the cache only if the key does not exist or is expired.
-// It sets an expiration time for the key-value pair.
-// It returns true if the key was added to the cache, otherwise false.
-func (c *LRUCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+// ExtendMany adds extra to the expiration time of each of the given keys that is live and expires,
+// under a single lock, and returns the number of keys extended. Keys without expiration are left untouched,
+// and missing or expired keys are skipped.
+func (c *LRUCache[K, V]) ExtendMany(keys []K, extra time.Duration) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if item, ok := c.m[k]; ok {
-		lruItem := item.Value.(*lruItem[K, V])
-		// Check if existing key is expired
-		if lruItem.expireAt == 0 || lruItem.expireAt >= time.Now().UnixNano() {
-			return false
+	now := c.opts.expiryNow()
+	extended := 0
+	for _, k := range keys {
+		i, ok := c.m[k]
+		if !ok {
+			continue
+		}
+		if item := c.evictionList.at(i); item.expireAt > 0 && item.expireAt >= now {
+			item.expireAt += int64(extra)
+			extended++
 		}
-		// Key exists but is expired, delete it first
-		delete(c.m, k)
-		c.evictionList.Remove(item)
 	}
 
-	c.set(k, v, t)
-	return true
+	return extended
 }
 
-// Delete removes the key-value pair associated with the given key from the cache.
-func (c *LRUCache[K, V]) Delete(k K) {
+// DeleteExpired removes the given keys if they are expired and returns the number of keys removed.
+// Only the supplied keys are checked, which is cheaper than a full sweep when the candidates are known.
+// Live and missing keys are left untouched.
+func (c *LRUCache[K, V]) DeleteExpired(keys []K) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.delete(k)
+	now := c.opts.expiryNow()
+	removed := 0
+	for _, k := range keys {
+		i, ok := c.m[k]
+		if !ok {
+			continue
+		}
+		if item := c.evictionList.at(i); item.expireAt > 0 && item.expireAt < now {
+			c.removeElement(i, ReasonExpired)
+			removed++
+		}
+	}
+
+	return removed
 }
 
 func (c *LRUCache[K, V]) delete(k K) {
-	item, ok := c.m[k]
+	i, ok := c.m[k]
 	if !ok {
 		return
 	}
 
-	delete(c.m, k)
-	c.evictionList.Remove(item)
+	c.removeElement(i, ReasonDeleted)
+}
+
+// removeElement removes the item at index i from both the map and the eviction list,
+// notifies its value of the removal and sends an event for it.
+func (c *LRUCache[K, V]) removeElement(i int, reason RemovalReason) {
+	k := c.evictionList.at(i).key
+	v := c.unlink(i)
+	c.opts.notifyRemoved(v)
+	c.sendEvent(k, v, reason)
+}
+
+// sendEvent sends a removal event on the channel of WithEventChannel without blocking,
+// counting the event as dropped if the channel is full. No events are sent once the cache is closed.
+func (c *LRUCache[K, V]) sendEvent(k K, v V, reason RemovalReason) {
+	if c.opts.events == nil || c.closed {
+		return
+	}
+	select {
+	case c.opts.events <- Event[K, V]{Key: k, Value: v, Reason: reason}:
+	default:
+		c.dropped++
+	}
+}
+
+// DroppedEvents returns the number of removal events that were dropped because the channel
+// of WithEventChannel was full.
+func (c *LRUCache[K, V]) DroppedEvents() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.dropped
+}
+
+// unlink removes the item at index i from both the map and the eviction list without notifying its value,
+// and returns the value.
+func (c *LRUCache[K, V]) unlink(i int) V {
+	item := c.evictionList.at(i)
+	v := item.value
+	delete(c.m, item.key)
+	c.markDirty(item.key)
+	c.cost -= item.cost
+	if item.protect {
+		c.protected--
+	}
+	if item.class != 0 {
+		c.evictionList.classUnlink(&c.classes[item.class-1], i)
+	}
+	c.evictionList.Remove(i)
+	c.removed.Broadcast()
+	return v
 }
 
 // TransferTo transfers all non-expired key-value pairs from the source cache to the destination cache.
@@ -154,21 +1362,21 @@ func (c *LRUCache[K, V]) delete(k K) {
 func (src *LRUCache[K, V]) TransferTo(dst *LRUCache[K, V]) {
 	// Collect data with source lock
 	src.mu.Lock()
-	now := time.Now().UnixNano()
+	now := src.opts.expiryNow()
 	toTransfer := make(map[K]V)
-	var keysToDelete []K
+	var itemsToDelete []int
 
-	for k, v := range src.m {
-		lruItem := v.Value.(*lruItem[K, V])
-		if lruItem.expireAt == 0 || lruItem.expireAt >= now {
-			toTransfer[k] = lruItem.value
-			keysToDelete = append(keysToDelete, k)
+	for k, i := range src.m {
+		item := src.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
+			toTransfer[k] = item.value
+			itemsToDelete = append(itemsToDelete, i)
 		}
 	}
 
-	// Delete transferred items from source
-	for _, k := range keysToDelete {
-		src.delete(k)
+	// Delete transferred items from source, their values live on in the destination
+	for _, i := range itemsToDelete {
+		src.unlink(i)
 	}
 	src.mu.Unlock()
 
@@ -185,13 +1393,13 @@ func (src *LRUCache[K, V]) TransferTo(dst *LRUCache[K, V]) {
 func (src *LRUCache[K, V]) CopyTo(dst *LRUCache[K, V]) {
 	// Collect data with source lock
 	src.mu.Lock()
-	now := time.Now().UnixNano()
+	now := src.opts.expiryNow()
 	toCopy := make(map[K]V)
 
-	for k, v := range src.m {
-		lruItem := v.Value.(*lruItem[K, V])
-		if lruItem.expireAt == 0 || lruItem.expireAt >= now {
-			toCopy[k] = lruItem.value
+	for k, i := range src.m {
+		item := src.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
+			toCopy[k] = item.value
 		}
 	}
 	src.mu.Unlock()
@@ -211,45 +1419,324 @@ func (c *LRUCache[K, V]) Keys() []K {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := time.Now().UnixNano()
+	now := c.opts.expiryNow()
 	keys := make([]K, 0, len(c.m))
 
-	for k, v := range c.m {
-		lruItem := v.Value.(*lruItem[K, V])
-		if lruItem.expireAt == 0 || lruItem.expireAt >= now {
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
 			keys = append(keys, k)
+		} else if c.opts.eagerScanCleanup {
+			c.removeExpired(i)
+		}
+	}
+
+	return keys
+}
+
+// SomeKeys returns up to n live keys, starting with the least recently used, i.e. the keys that would be
+// evicted first. Unlike Keys, it stops as soon as n keys are found. Keys protected by SetProtected are skipped.
+func (c *LRUCache[K, V]) SomeKeys(n int) []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applyReads()
+
+	now := c.opts.expiryNow()
+	keys := make([]K, 0, min(max(n, 0), len(c.m)))
+	for i := c.evictionList.Back(); i != 0 && len(keys) < n; i = c.evictionList.Prev(i) {
+		item := c.evictionList.at(i)
+		if !item.protect && (item.expireAt == 0 || item.expireAt >= now) {
+			keys = append(keys, item.key)
 		}
 	}
 
 	return keys
 }
 
-// Purge removes all key-value pairs from the cache.
+// ExpiringWithin returns the live keys that expire within d from now, sorted soonest first.
+// Keys without an expiration time are not included.
+func (c *LRUCache[K, V]) ExpiringWithin(d time.Duration) []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from := c.opts.expiryNow()
+	until := from + int64(d)
+	var entries []keyExpiration[K]
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt >= from && item.expireAt <= until {
+			entries = append(entries, keyExpiration[K]{key: k, expireAt: item.expireAt})
+		}
+	}
+
+	return keysByExpiration(entries)
+}
+
+// TransformValues calls f for each non-expired entry under a single lock and replaces the value
+// of the entry with the returned value if f returns true. The recency order is not changed.
+// f must not call methods of the cache.
+func (c *LRUCache[K, V]) TransformValues(f func(k K, v V) (V, bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt > 0 && item.expireAt < now {
+			continue
+		}
+		v, ok := f(k, item.value)
+		if !ok {
+			continue
+		}
+
+		c.discardPending(k)
+		item.value = v
+		item.version = c.nextVersion()
+		cost := c.opts.entryCost(k, v)
+		c.cost += cost - item.cost
+		item.cost = cost
+		c.markDirty(k)
+		if c.writeBehind != nil {
+			c.writeBehind.enqueue(k, v)
+		}
+	}
+}
+
+// Purge removes all key-value pairs from the cache. Values implementing Expirable are notified
+// of their removal, and each removal is sent with ReasonDeleted on the channel of WithEventChannel.
 func (c *LRUCache[K, V]) Purge() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.m = make(map[K]*list.Element)
-	c.evictionList.Init()
+	c.purge(ReasonDeleted)
+}
+
+// Flush removes all key-value pairs from the cache like Purge, but sends each removal with ReasonPurged
+// on the channel of WithEventChannel, so that consumers releasing resources can tell a flush apart from deletes.
+// Values implementing Expirable are notified of their removal.
+func (c *LRUCache[K, V]) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge(ReasonPurged)
+}
+
+// purge removes all key-value pairs from the cache, reporting each removal with the given reason.
+func (c *LRUCache[K, V]) purge(reason RemovalReason) {
+	c.dropAllThrottled()
+	c.notifyAll(reason)
+	c.markAllDirty()
+	c.m = make(map[K]int)
+	c.evictionList.Init(0)
+	c.cost = 0
+	c.protected = 0
+	c.tombstones = nil
+	c.tombstoneLog = nil
+	c.inserts = nil
+	c.resetClasses()
+	c.removed.Broadcast()
+}
+
+// Drain removes all key-value pairs from the cache and returns the ones that were not expired.
+// Unlike GetAll followed by Purge, it is performed under a single lock.
+func (c *LRUCache[K, V]) Drain() map[K]V {
+	return drainedValues(c.drainWithTTL())
+}
+
+// drainWithTTL removes all key-value pairs from the cache like Drain and returns the ones
+// that were not expired together with their remaining time to live.
+func (c *LRUCache[K, V]) drainWithTTL() map[K]drainedEntry[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]drainedEntry[V])
+	now := c.opts.expiryNow()
+	for k, i := range c.m {
+		item := c.evictionList.at(i)
+		if ttl, ok := remainingTTL(item.expireAt, now); ok {
+			m[k] = drainedEntry[V]{value: item.value, ttl: ttl}
+		} else {
+			c.opts.notifyRemoved(item.value)
+			c.sendEvent(k, item.value, ReasonExpired)
+		}
+	}
+
+	c.dropAllThrottled()
+	c.markAllDirty()
+	c.m = make(map[K]int)
+	c.evictionList.Init(0)
+	c.cost = 0
+	c.protected = 0
+	c.tombstones = nil
+	c.tombstoneLog = nil
+	c.inserts = nil
+	c.resetClasses()
+	c.removed.Broadcast()
+	return m
+}
+
+// Close stops the background goroutines, if any, and clears the cache.
+// With WithWriteBehind, it waits until the queued values have been flushed.
+// After calling Close, the cache should not be used.
+func (c *LRUCache[K, V]) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.stopCh)
+	c.clear()
+	c.mu.Unlock()
+
+	// The final flush runs without holding the lock, so that it does not block other calls of the cache
+	if c.writeBehind != nil {
+		<-c.writeBehind.done
+	}
+}
+
+// clear notifies the values of all items of their removal and releases the state of the closed cache.
+func (c *LRUCache[K, V]) clear() {
+	c.dropAllThrottled()
+	c.notifyAll(ReasonDeleted) // sends no events, the cache is closed
+	c.m = nil
+	c.dirty = nil
+	c.evictionList.Init(0)
+	c.cost = 0
+	c.protected = 0
+	c.tombstones = nil
+	c.tombstoneLog = nil
+	c.inserts = nil
+	c.resetClasses()
+	c.removed.Broadcast()
+}
+
+// notifyAll notifies the values of all items of their removal and sends an event with the given reason for each,
+// before the cache is cleared.
+func (c *LRUCache[K, V]) notifyAll(reason RemovalReason) {
+	for i := c.evictionList.Front(); i != 0; i = c.evictionList.Next(i) {
+		item := c.evictionList.at(i)
+		c.opts.notifyRemoved(item.value)
+		c.sendEvent(item.key, item.value, reason)
+	}
+}
+
+// Pause stops the background cleanup from removing expired entries until Resume is called, e.g. to inspect
+// expired entries that have not been removed yet. Expired entries are still treated as absent by reads,
+// which may remove them. It does nothing if the cache has no background cleanup.
+func (c *LRUCache[K, V]) Pause() {
+	c.sweeper.paused.Store(true)
+}
+
+// Resume resumes the background cleanup paused by Pause or WithStartPaused.
+// Expired entries are removed by the next scheduled sweep.
+func (c *LRUCache[K, V]) Resume() {
+	c.sweeper.paused.Store(false)
+}
+
+// sweep removes all expired keys and reports how many keys were scanned and removed.
+func (c *LRUCache[K, V]) sweep() (scanned, removed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applyReads()
+
+	c.pruneTombstones(c.opts.now().UnixNano())
+	if c.opts.retainExpired {
+		return 0, 0
+	}
+	now := c.opts.expiryNow()
+	for _, i := range c.m {
+		item := c.evictionList.at(i)
+		scanned++
+		if item.expireAt > 0 && item.expireAt < now {
+			c.removeElement(i, ReasonExpired)
+			removed++
+		}
+	}
+	return scanned, removed
+}
+
+// shed evicts the given fraction of the entries in response to memory pressure.
+func (c *LRUCache[K, V]) shed(fraction float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applyReads()
+
+	c.evict(int(math.Ceil(float64(len(c.m)) * fraction)))
 }
 
 // Count returns the number of non-expired key-value pairs currently stored in the cache.
+// With WithApproximateCount it returns the number of stored entries without checking them for expiration.
 func (c *LRUCache[K, V]) Count() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.opts.approxCount {
+		return len(c.m)
+	}
+	return c.count()
+}
+
+func (c *LRUCache[K, V]) count() int {
 	count := 0
-	now := time.Now().UnixNano()
-	for _, v := range c.m {
-		lruItem := v.Value.(*lruItem[K, V])
-		if lruItem.expireAt == 0 || lruItem.expireAt >= now {
+	now := c.opts.expiryNow()
+	for _, i := range c.m {
+		item := c.evictionList.at(i)
+		if item.expireAt == 0 || item.expireAt >= now {
 			count++
+		} else if c.opts.eagerScanCleanup {
+			c.removeExpired(i)
 		}
 	}
 
 	return count
 }
 
+// TTLStats counts the entries without an expiration time, the live entries with one,
+// and the expired entries that have not been removed yet, in a single scan.
+func (c *LRUCache[K, V]) TTLStats() (permanent, expiring, expired int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for _, i := range c.m {
+		switch item := c.evictionList.at(i); {
+		case item.expireAt == 0:
+			permanent++
+		case item.expireAt >= now:
+			expiring++
+		default:
+			expired++
+		}
+	}
+	return permanent, expiring, expired
+}
+
+// WaitEmpty blocks until the cache holds no non-expired items or the context is done,
+// in which case it returns the context's error.
+// It is woken up whenever items are deleted, evicted or removed because they expired,
+// so expired items are only noticed once they are accessed or removed by the background sweeper.
+func (c *LRUCache[K, V]) WaitEmpty(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.removed.Broadcast()
+	})
+	defer stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.count() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.removed.Wait()
+	}
+	return nil
+}
+
 // Len returns the total number of elements in the cache (including expired ones).
 func (c *LRUCache[K, V]) Len() int {
 	c.mu.Lock()
@@ -258,45 +1745,226 @@ func (c *LRUCache[K, V]) Len() int {
 	return len(c.m)
 }
 
-func (c *LRUCache[K, V]) set(k K, v V, exp time.Duration) {
-	if c.size == 0 {
-		return
+// Name returns the name of the cache set with WithName, or an empty string.
+func (c *LRUCache[K, V]) Name() string {
+	return c.opts.name
+}
+
+// Stats returns the usage statistics of the cache.
+func (c *LRUCache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Len = len(c.m)
+	return stats
+}
+
+// String returns a compact summary of the cache, e.g. incache.LRU[name=sessions size=1000 count=812].
+func (c *LRUCache[K, V]) String() string {
+	return fmt.Sprintf("incache.LRU[name=%s size=%s count=%d]", c.opts.name, sizeString(c.size), c.Count())
+}
+
+// set adds or updates the key in the cache.
+// It returns false if the key could not be added.
+func (c *LRUCache[K, V]) set(k K, v V, exp time.Duration) bool {
+	if c.size == 0 || c.closed || c.opts.tooLarge(v) {
+		return false
+	}
+	exp, ok := c.opts.ttl(exp)
+	if !ok {
+		return false
+	}
+	if c.tombstoned(k) {
+		if !c.opts.tombstoneWrites {
+			return false
+		}
+		delete(c.tombstones, k)
 	}
+	c.discardPending(k)
+	c.applyReads()
 
 	var expireAt int64
 	if exp > 0 {
-		expireAt = time.Now().Add(exp).UnixNano()
+		expireAt = c.opts.now().Add(exp).UnixNano()
+	}
+
+	i, ok := c.m[k]
+	if ok && c.opts.valueEquals != nil {
+		// An equal value only refreshes the expiration, leaving the recency order and version untouched.
+		if item := c.evictionList.at(i); c.opts.valueEquals(item.value, v) {
+			item.expireAt = expireAt
+			return true
+		}
 	}
 
-	item, ok := c.m[k]
+	cost := c.opts.entryCost(k, v)
+
 	if ok {
-		lruItem := item.Value.(*lruItem[K, V])
-		lruItem.value = v
-		lruItem.expireAt = expireAt
-		c.evictionList.MoveToFront(item)
+		item := c.evictionList.at(i)
+		item.value = v
+		item.expireAt = expireAt
+		c.cost += cost - item.cost
+		item.cost = cost
+		item.version = c.nextVersion()
+		c.touch(i)
 	} else {
-		if uint(len(c.m)) >= c.size {
-			c.evict(1)
+		before := len(c.m)
+		if high, low := c.opts.watermarks(c.size); uint(before) >= high && !c.bulkLoading {
+			if c.opts.rejectOnFull {
+				return false
+			}
+			c.evict(before - int(low))
+			if uint(len(c.m)) >= high {
+				return false // every item is protected
+			}
 		}
 
-		lruItem := &lruItem[K, V]{
+		var inserted int64
+		if c.opts.trackAge {
+			inserted = c.opts.now().UnixNano()
+			c.recordInsert(k, inserted)
+		}
+		c.m[k] = c.evictionList.PushFront(lruItem[K, V]{
 			key:      k,
 			value:    v,
 			expireAt: expireAt,
+			cost:     cost,
+			version:  c.nextVersion(),
+			inserted: inserted,
+		})
+		c.cost += cost
+		c.opts.observeHighWater(before, len(c.m), c.size)
+	}
+
+	c.markDirty(k)
+	if c.writeBehind != nil {
+		c.writeBehind.enqueue(k, v)
+	}
+	if !c.bulkLoading {
+		c.evictOverLimits()
+	}
+	return true
+}
+
+// evictOverLimits evicts least recently used items until both the key limit and the cost budget are satisfied.
+// The most recently used item is never evicted, even if it exceeds the cost budget on its own.
+func (c *LRUCache[K, V]) evictOverLimits() {
+	for c.evictionList.Len() > 1 && c.overLimits() {
+		b := c.victim()
+		if b == 0 || b == c.evictionList.Front() {
+			return
 		}
+		c.evictElement(b)
+	}
+}
 
-		insertedItem := c.evictionList.PushFront(lruItem)
-		c.m[k] = insertedItem
+func (c *LRUCache[K, V]) overLimits() bool {
+	if c.opts.maxKeys > 0 && uint(len(c.m)) > c.opts.maxKeys {
+		return true
 	}
+	return c.opts.maxCost > 0 && c.cost > c.opts.maxCost
 }
 
 func (c *LRUCache[K, V]) evict(i int) {
 	for j := 0; j < i; j++ {
-		if b := c.evictionList.Back(); b != nil {
-			delete(c.m, b.Value.(*lruItem[K, V]).key)
-			c.evictionList.Remove(b)
+		if b := c.victim(); b != 0 {
+			c.evictElement(b)
 		} else {
 			return
 		}
 	}
 }
+
+// evictElement evicts the item at index i and records its key as the most recently evicted one.
+func (c *LRUCache[K, V]) evictElement(i int) {
+	k := c.evictionList.at(i).key
+	c.lastEvicted = k
+	if c.evicted != nil {
+		c.evicted.add(k)
+	}
+	c.removeElement(i, ReasonEvicted)
+	c.stats.Evictions++
+}
+
+// RecentlyEvicted returns the keys of the most recently evicted entries, the most recent first,
+// up to the number given to WithEvictionHistory. Keys evicted more than once appear as often.
+// Entries removed because they expired, were deleted or purged are not included.
+// It requires WithEvictionHistory and returns nil without it.
+func (c *LRUCache[K, V]) RecentlyEvicted() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.evicted == nil {
+		return nil
+	}
+	return c.evicted.recent()
+}
+
+// victim returns the index of the least recently used item that is not protected, or 0 if there is none.
+func (c *LRUCache[K, V]) victim() int {
+	i := c.evictionList.Back()
+	if c.protected == 0 {
+		return i
+	}
+	for i != 0 && c.evictionList.at(i).protect {
+		i = c.evictionList.Prev(i)
+	}
+	return i
+}
+
+// validate checks the internal invariants of the cache and returns an error describing the first violation.
+// Every item in the map must be in the eviction list exactly once and the total cost and protected count must match the items.
+// It is meant for tests and debugging.
+func (c *LRUCache[K, V]) validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, protected := 0, 0
+	var cost int64
+	for i := c.evictionList.Front(); i != 0; i = c.evictionList.Next(i) {
+		item := c.evictionList.at(i)
+		if c.m[item.key] != i {
+			return fmt.Errorf("item %v in the eviction list is not the item in the map", item.key)
+		}
+		if item.protect {
+			protected++
+		}
+		cost += item.cost
+		count++
+	}
+
+	if count != len(c.m) {
+		return fmt.Errorf("eviction list holds %d items but the map holds %d", count, len(c.m))
+	}
+	if count != c.evictionList.Len() {
+		return fmt.Errorf("eviction list holds %d items but its length is %d", count, c.evictionList.Len())
+	}
+	free := 0
+	for i := c.evictionList.free; i != 0; i = c.evictionList.Next(i) {
+		free++
+	}
+	if count+free != len(c.evictionList.items)-1 {
+		return fmt.Errorf("eviction list holds %d items and %d free slots but has %d slots", count, free, len(c.evictionList.items)-1)
+	}
+	if cost != c.cost {
+		return fmt.Errorf("items cost %d but the tracked cost is %d", cost, c.cost)
+	}
+	if protected != c.protected {
+		return fmt.Errorf("%d items are protected but the tracked count is %d", protected, c.protected)
+	}
+	for ci := range c.classes {
+		cl := &c.classes[ci]
+		n := 0
+		for i := cl.front; i != 0; i = c.evictionList.at(i).classNext {
+			if c.evictionList.at(i).class != ci+1 {
+				return fmt.Errorf("item %v in the list of class %s has another class", c.evictionList.at(i).key, cl.name)
+			}
+			n++
+		}
+		if n != cl.len {
+			return fmt.Errorf("class %s lists %d items but its length is %d", cl.name, n, cl.len)
+		}
+	}
+	return nil
+}
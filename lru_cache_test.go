@@ -1,9 +1,17 @@
 package incache
 
 import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestSet_LRU(t *testing.T) {
@@ -101,6 +109,32 @@ func TestNotFoundSetWithTimeout_LRU(t *testing.T) {
 	}
 }
 
+func TestNotFoundSetMany_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+	c.Set("present", "old")
+	c.SetWithTimeout("expired", "old", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	added := c.NotFoundSetMany(map[string]string{
+		"present": "new",
+		"expired": "new",
+		"absent":  "new",
+	})
+	slices.Sort(added)
+
+	if !slices.Equal(added, []string{"absent", "expired"}) {
+		t.Errorf("Expected only absent and expired keys to be added, got %v", added)
+	}
+	if v, _ := c.Get("present"); v != "old" {
+		t.Errorf("Expected present key to keep its value, got %v", v)
+	}
+	for _, k := range added {
+		if v, ok := c.Get(k); !ok || v != "new" {
+			t.Errorf("Expected %s to be stored, got %v", k, v)
+		}
+	}
+}
+
 func TestDelete_LRU(t *testing.T) {
 	c := NewLRU[string, string](10)
 
@@ -335,3 +369,1601 @@ func TestUpdateExisting_LRU(t *testing.T) {
 		t.Errorf("Expected Len=1 after update, got %d", c.Len())
 	}
 }
+
+func TestReplaceIfPresent_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	if c.ReplaceIfPresent("key1", "value1") {
+		t.Errorf("ReplaceIfPresent should return false for a missing key")
+	}
+	if _, ok := c.Get("key1"); ok {
+		t.Errorf("ReplaceIfPresent should not create a new key")
+	}
+
+	c.Set("key1", "value1")
+	if !c.ReplaceIfPresent("key1", "value2") {
+		t.Errorf("ReplaceIfPresent should return true for an existing key")
+	}
+	if v, ok := c.Get("key1"); !ok || v != "value2" {
+		t.Errorf("Expected value2, got %v", v)
+	}
+
+	c.SetWithTimeout("key2", "value1", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if c.ReplaceIfPresent("key2", "value2") {
+		t.Errorf("ReplaceIfPresent should return false for an expired key")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected Len=1, got %d", c.Len())
+	}
+}
+
+func TestSetWithTimeoutIfSooner_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	// Absent key behaves like SetWithTimeout
+	c.SetWithTimeoutIfSooner("key1", "value1", time.Hour)
+	if v, ok := c.Get("key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Later expiration is ignored
+	c.SetWithTimeoutIfSooner("key1", "value2", 2*time.Hour)
+	if v, _ := c.Get("key1"); v != "value1" {
+		t.Errorf("Expected later expiration to be ignored, got %v", v)
+	}
+
+	// Sooner expiration wins
+	c.SetWithTimeoutIfSooner("key1", "value3", time.Millisecond)
+	if v, _ := c.Get("key1"); v != "value3" {
+		t.Errorf("Expected sooner expiration to win, got %v", v)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := c.Get("key1"); ok {
+		t.Errorf("Expected key1 to expire")
+	}
+}
+
+func TestSetWithTimeoutIfLater_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	// Absent key behaves like SetWithTimeout
+	c.SetWithTimeoutIfLater("key1", "value1", time.Millisecond)
+	if v, ok := c.Get("key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	// Later expiration wins
+	c.SetWithTimeoutIfLater("key1", "value2", time.Hour)
+	if v, _ := c.Get("key1"); v != "value2" {
+		t.Errorf("Expected later expiration to win, got %v", v)
+	}
+
+	// Sooner expiration is ignored
+	c.SetWithTimeoutIfLater("key1", "value3", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if v, ok := c.Get("key1"); !ok || v != "value2" {
+		t.Errorf("Expected sooner expiration to be ignored, got %v", v)
+	}
+}
+
+func TestDrain_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.SetWithTimeout("key3", "value3", time.Microsecond)
+	time.Sleep(time.Millisecond)
+
+	m := c.Drain()
+	if len(m) != 2 || m["key1"] != "value1" || m["key2"] != "value2" {
+		t.Errorf("Drain returned unexpected entries: %v", m)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Expected cache to be empty after Drain, got Len=%d", c.Len())
+	}
+
+	// Should be able to use cache after drain
+	c.Set("key4", "value4")
+	if v, ok := c.Get("key4"); !ok || v != "value4" {
+		t.Errorf("Expected to use cache after drain")
+	}
+}
+
+func TestWithClock_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(10, WithClock[string, string](clock))
+
+	c.SetWithTimeout("key1", "value1", time.Minute)
+
+	clock.Advance(59 * time.Second)
+	if _, ok := c.Get("key1"); !ok {
+		t.Errorf("Expected key1 to still be live")
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, ok := c.Get("key1"); ok {
+		t.Errorf("Expected key1 to have expired")
+	}
+}
+
+func TestWithCleanupInterval_LRU(t *testing.T) {
+	c := NewLRU(10, WithCleanupInterval[string, string](time.Millisecond))
+	defer c.Close()
+
+	c.SetWithTimeout("key1", "value1", time.Millisecond)
+	c.Set("key2", "value2")
+
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected sweeper to remove the expired key, got Len=%d", c.Len())
+	}
+}
+
+func TestWithMaxCost_LRU(t *testing.T) {
+	cost := func(v string) int64 { return int64(len(v)) }
+	c := NewLRU(100, WithMaxCost[string, string](10, cost))
+
+	c.Set("key1", "aaaa")
+	c.Set("key2", "bbbb")
+	c.Set("key3", "cccc") // total cost 12 > 10, key1 is evicted
+
+	if _, ok := c.Get("key1"); ok {
+		t.Errorf("Expected key1 to be evicted by the cost budget")
+	}
+	if c.Len() != 2 || c.cost != 8 {
+		t.Errorf("Expected Len=2 and cost=8, got Len=%d cost=%d", c.Len(), c.cost)
+	}
+
+	// Updating a value adjusts the total cost
+	c.Set("key2", "b")
+	if c.cost != 5 {
+		t.Errorf("Expected cost=5 after update, got %d", c.cost)
+	}
+
+	c.Delete("key2")
+	if c.cost != 4 {
+		t.Errorf("Expected cost=4 after delete, got %d", c.cost)
+	}
+}
+
+func TestWithMaxCost_ReplaceIfPresent_LRU(t *testing.T) {
+	c := NewLRU(100, WithMaxCost[string, int](10, func(v int) int64 { return int64(v) }))
+	c.Set("a", 5)
+	c.Set("b", 5)
+
+	if !c.ReplaceIfPresent("a", 8) {
+		t.Fatalf("Expected ReplaceIfPresent to replace a")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted once the replaced value exceeds the cost budget")
+	}
+	if v, ok := c.Get("a"); !ok || v != 8 {
+		t.Errorf("Expected a=8 to be kept, got %v, %v", v, ok)
+	}
+	if c.cost != 8 {
+		t.Errorf("Expected cost=8 after replacing a, got %d", c.cost)
+	}
+}
+
+func TestTrySet_MaxCost_LRU(t *testing.T) {
+	c := NewLRU(100, WithMaxCost[string, int](10, func(v int) int64 { return int64(v) }))
+	c.Set("a", 6)
+
+	if err := c.TrySet("b", 6); err != ErrCacheFull {
+		t.Errorf("Expected ErrCacheFull when the new entry exceeds the cost budget, got %v", err)
+	}
+	if err := c.TrySet("a", 11); err != ErrCacheFull {
+		t.Errorf("Expected ErrCacheFull when an update exceeds the cost budget, got %v", err)
+	}
+	if v, ok := c.Get("a"); !ok || v != 6 {
+		t.Errorf("Expected TrySet not to evict or modify a, got %v, %v", v, ok)
+	}
+
+	if err := c.TrySet("b", 4); err != nil {
+		t.Errorf("Expected TrySet within the cost budget to succeed, got %v", err)
+	}
+	if err := c.TrySet("a", 5); err != nil {
+		t.Errorf("Expected an update within the cost budget to succeed, got %v", err)
+	}
+}
+
+func TestTrySet_MaxKeys_LRU(t *testing.T) {
+	c := NewLRU(100, WithMaxKeys[string, int](2))
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	if err := c.TrySet("c", 3); err != ErrCacheFull {
+		t.Errorf("Expected ErrCacheFull at the key limit, got %v", err)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected TrySet not to evict, got Len=%d", c.Len())
+	}
+	if err := c.TrySet("a", 10); err != nil {
+		t.Errorf("Expected an update at the key limit to succeed, got %v", err)
+	}
+
+	c.Delete("b")
+	if err := c.TrySet("c", 3); err != nil {
+		t.Errorf("Expected TrySet to succeed once a key is deleted, got %v", err)
+	}
+}
+
+func TestWithMaxKeys_LRU(t *testing.T) {
+	cost := func(v string) int64 { return int64(len(v)) }
+
+	// The key cap binds before the cost budget
+	c := NewLRU(100,
+		WithMaxCost[string, string](100, cost),
+		WithMaxKeys[string, string](2),
+	)
+	c.Set("key1", "a")
+	c.Set("key2", "b")
+	c.Set("key3", "c")
+
+	if c.Len() != 2 {
+		t.Errorf("Expected key cap to limit Len to 2, got %d", c.Len())
+	}
+	if _, ok := c.Get("key1"); ok {
+		t.Errorf("Expected key1 to be evicted by the key cap")
+	}
+
+	// The cost budget binds before the key cap
+	c = NewLRU(100,
+		WithMaxCost[string, string](5, cost),
+		WithMaxKeys[string, string](10),
+	)
+	c.Set("key1", "aaa")
+	c.Set("key2", "bbb")
+
+	if c.Len() != 1 {
+		t.Errorf("Expected cost budget to limit Len to 1, got %d", c.Len())
+	}
+	if _, ok := c.Get("key2"); !ok {
+		t.Errorf("Expected key2 to remain")
+	}
+}
+
+func TestLoadOrStore_LRU(t *testing.T) {
+	c := NewLRU[string, string](2)
+
+	if v, loaded := c.LoadOrStore("key1", "value1"); loaded || v != "value1" {
+		t.Errorf("Expected value1 to be stored, got %v, %v", v, loaded)
+	}
+	c.Set("key2", "value2")
+
+	if v, loaded := c.LoadOrStore("key1", "other"); !loaded || v != "value1" {
+		t.Errorf("Expected value1 to be loaded, got %v, %v", v, loaded)
+	}
+
+	// The load counts as an access, so key2 is evicted
+	c.Set("key3", "value3")
+	if _, ok := c.Get("key2"); ok {
+		t.Errorf("Expected key2 to be evicted")
+	}
+
+	c.SetWithTimeout("key3", "value3", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if v, loaded := c.LoadOrStore("key3", "new"); loaded || v != "new" {
+		t.Errorf("Expected expired key to be stored, got %v, %v", v, loaded)
+	}
+	if v, _ := c.Get("key3"); v != "new" {
+		t.Errorf("Expected new, got %v", v)
+	}
+}
+
+func TestWaitEmpty_LRU(t *testing.T) {
+	c := NewLRU(10, WithCleanupInterval[string, string](time.Millisecond))
+	defer c.Close()
+
+	c.SetWithTimeout("key1", "value1", 5*time.Millisecond)
+	c.SetWithTimeout("key2", "value2", 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := c.WaitEmpty(ctx); err != nil {
+		t.Errorf("Expected WaitEmpty to return once the sweeper drained the cache, got %v", err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected Len=0, got %d", c.Len())
+	}
+}
+
+func TestWaitEmptyTimeout_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	c.Set("key1", "value1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	if err := c.WaitEmpty(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGetManyWithExpiration_LRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// The hit on a makes b the least recently used key
+	c.GetManyWithExpiration([]string{"a"})
+	c.Set("c", 3)
+
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Expected a to survive eviction after being read")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+}
+
+func TestSetWithVersion_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	if _, version, ok := c.GetWithVersion("key"); ok || version != 0 {
+		t.Errorf("Expected a missing key to have version 0, got %d", version)
+	}
+	if c.SetWithVersion("key", "v1", 1) {
+		t.Errorf("Expected SetWithVersion to reject a non-zero version for a new key")
+	}
+	if !c.SetWithVersion("key", "v1", 0) {
+		t.Errorf("Expected SetWithVersion with version 0 to insert a new key")
+	}
+
+	v, version, ok := c.GetWithVersion("key")
+	if !ok || v != "v1" || version != 1 {
+		t.Errorf("Expected (v1, 1), got (%v, %d)", v, version)
+	}
+
+	// A concurrent writer modifies the key, making the version read above stale
+	c.Set("key", "other")
+	if c.SetWithVersion("key", "v2", version) {
+		t.Errorf("Expected SetWithVersion with a stale version to be rejected")
+	}
+	if v, _ := c.Get("key"); v != "other" {
+		t.Errorf("Expected the rejected set not to modify the value, got %v", v)
+	}
+
+	_, version, _ = c.GetWithVersion("key")
+	if !c.SetWithVersion("key", "v2", version) {
+		t.Errorf("Expected SetWithVersion with the current version to succeed")
+	}
+	if v, newVersion, _ := c.GetWithVersion("key"); v != "v2" || newVersion != version+1 {
+		t.Errorf("Expected (v2, %d), got (%v, %d)", version+1, v, newVersion)
+	}
+}
+
+func TestSetWithVersion_Reinsert_LRU(t *testing.T) {
+	c := NewLRU[string, string](10)
+
+	c.Set("key", "v1")
+	_, version, _ := c.GetWithVersion("key")
+
+	// Another writer deletes the key and inserts it again
+	c.Delete("key")
+	c.Set("key", "other")
+	if c.SetWithVersion("key", "v2", version) {
+		t.Errorf("Expected the version read before the key was deleted and inserted again to be rejected")
+	}
+	if v, _ := c.Get("key"); v != "other" {
+		t.Errorf("Expected the rejected set not to modify the value, got %v", v)
+	}
+}
+
+func TestLoadOrStore_NotStored_LRU(t *testing.T) {
+	c := NewLRU(1, WithRejectOnFull[string, int]())
+	c.Set("x", 1)
+
+	if v, loaded := c.LoadOrStore("y", 2); loaded || v != 0 {
+		t.Errorf("Expected the zero value for a rejected key, got %v, %v", v, loaded)
+	}
+	if _, ok := c.Get("y"); ok {
+		t.Errorf("Expected the rejected key not to be stored")
+	}
+
+	c.Close()
+	if v, loaded := c.LoadOrStore("z", 3); loaded || v != 0 {
+		t.Errorf("Expected the zero value from a closed cache, got %v, %v", v, loaded)
+	}
+}
+
+func TestGetAllOrdered_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.SetWithTimeout("expired", 4, time.Millisecond)
+	c.Get("a")
+	time.Sleep(2 * time.Millisecond)
+
+	want := []Entry[string, int]{{"a", 1}, {"c", 3}, {"b", 2}}
+	if got := c.GetAllOrdered(); !slices.Equal(got, want) {
+		t.Errorf("Expected entries in recency order %v, got %v", want, got)
+	}
+}
+
+func TestWithMaxValueSize_LRU(t *testing.T) {
+	c := NewLRU(10, WithMaxValueSize[string, []byte](4, func(v []byte) int64 { return int64(len(v)) }))
+
+	c.Set("small", []byte("ok"))
+	if v, ok := c.Get("small"); !ok || string(v) != "ok" {
+		t.Errorf("Expected in-limit value to be stored, got %q", v)
+	}
+
+	c.Set("big", []byte("too large"))
+	if _, ok := c.Get("big"); ok {
+		t.Errorf("Expected oversized value to be rejected")
+	}
+	if err := c.TrySet("big", []byte("too large")); err != ErrValueTooLarge {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+
+	// Oversized updates leave the existing value in place
+	c.Set("small", []byte("too large"))
+	if c.ReplaceIfPresent("small", []byte("too large")) {
+		t.Errorf("Expected ReplaceIfPresent to reject an oversized value")
+	}
+	if v, _ := c.Get("small"); string(v) != "ok" {
+		t.Errorf("Expected existing value to be kept, got %q", v)
+	}
+}
+
+func TestValidate_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(16,
+		WithClock[int, int](clock),
+		WithMaxCost[int, int](40, func(v int) int64 { return int64(v % 7) }),
+	)
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 10000; i++ {
+		k, v := r.IntN(32), r.IntN(100)
+		switch r.IntN(10) {
+		case 0:
+			c.Set(k, v)
+		case 1:
+			c.SetWithTimeout(k, v, time.Duration(r.IntN(5))*time.Second)
+		case 2:
+			c.Get(k)
+		case 3:
+			c.Delete(k)
+		case 4:
+			c.NotFoundSet(k, v)
+		case 5:
+			c.ReplaceIfPresent(k, v)
+		case 6:
+			c.SetWithTimeoutIfSooner(k, v, time.Second)
+		case 7:
+			c.DeleteExpired([]int{k, k + 1})
+		case 8:
+			clock.Advance(time.Second)
+		case 9:
+			if r.IntN(50) == 0 {
+				c.Purge()
+			}
+		}
+
+		if err := c.validate(); err != nil {
+			t.Fatalf("Invariant violated after %d operations: %v", i+1, err)
+		}
+	}
+}
+
+func TestTransformValues_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+	before := c.GetAllOrdered()
+
+	c.TransformValues(func(k string, v int) (int, bool) {
+		return v * 2, k != "c"
+	})
+
+	after := c.GetAllOrdered()
+	want := []Entry[string, int]{{"a", 2}, {"c", 3}, {"b", 4}}
+	if !slices.Equal(after, want) {
+		t.Errorf("Expected %v, got %v", want, after)
+	}
+	for i := range before {
+		if before[i].Key != after[i].Key {
+			t.Errorf("Expected recency order to be unchanged, was %v, got %v", before, after)
+			break
+		}
+	}
+}
+
+func TestWithValueEquals_LRU(t *testing.T) {
+	c := NewLRU[string, int](2, WithValueEquals[string, int](func(a, b int) bool { return a == b }))
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	c.Set("a", 1) // equal value, "a" must stay least recently used
+	c.Set("c", 3)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected 'a' to be evicted since an equal Set does not reorder")
+	}
+
+	c.Set("b", 20) // different value, "b" moves to the front
+	c.Set("d", 4)
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("Expected 'b' to be kept after a Set with a different value")
+	}
+
+	_, version, _ := c.GetWithVersion("b")
+	c.Set("b", 20)
+	if _, v, _ := c.GetWithVersion("b"); v != version {
+		t.Errorf("Expected version %d to be unchanged by an equal Set, got %d", version, v)
+	}
+}
+
+func TestSetReport_LRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	if evicted, _ := c.SetReport("a", 1); evicted {
+		t.Errorf("Expected no eviction when the cache has room")
+	}
+	c.SetReport("b", 2)
+	c.Get("a")
+
+	evicted, key := c.SetReport("c", 3)
+	if !evicted || key != "b" {
+		t.Errorf("Expected 'b' to be evicted as least recently used, got (%v, %q)", evicted, key)
+	}
+
+	if evicted, _ := c.SetWithTimeoutReport("a", 10, time.Minute); evicted {
+		t.Errorf("Expected no eviction when updating an existing key")
+	}
+	evicted, key = c.SetWithTimeoutReport("d", 4, time.Minute)
+	if !evicted || key != "c" {
+		t.Errorf("Expected 'c' to be evicted as least recently used, got (%v, %q)", evicted, key)
+	}
+}
+
+func TestSetThrottled_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	defer c.Close()
+
+	const interval = 20 * time.Millisecond
+	for i := 1; i <= 100; i++ {
+		c.SetThrottled("sensor", i, interval)
+	}
+
+	// The first value is written immediately, the rest of the burst is buffered
+	if v, version, _ := c.GetWithVersion("sensor"); v != 1 || version != 1 {
+		t.Fatalf("Expected the first value to be written once, got value %d version %d", v, version)
+	}
+
+	// The background flush writes only the latest value of the burst
+	deadline := time.Now().Add(time.Second)
+	for {
+		v, version, _ := c.GetWithVersion("sensor")
+		if version == 2 {
+			if v != 100 {
+				t.Errorf("Expected the latest value 100 to be flushed, got %d", v)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the buffered value to be flushed, got value %d version %d", v, version)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Without further updates the key stops being tracked and the next write is immediate
+	for {
+		c.mu.Lock()
+		n := len(c.throttled)
+		c.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the key to stop being throttled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.SetThrottled("sensor", 101, interval)
+	if v, version, _ := c.GetWithVersion("sensor"); v != 101 || version != 3 {
+		t.Errorf("Expected an immediate write after the interval, got value %d version %d", v, version)
+	}
+}
+
+func TestSetThrottled_DeleteDiscardsPending_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	defer c.Close()
+
+	c.SetThrottled("a", 1, 10*time.Millisecond)
+	c.SetThrottled("a", 2, 10*time.Millisecond)
+	c.Delete("a")
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected the buffered value to be discarded by Delete")
+	}
+}
+
+func TestSetThrottled_SetDiscardsPending_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	defer c.Close()
+
+	c.SetThrottled("a", 1, 10*time.Millisecond)
+	c.SetThrottled("a", 2, 10*time.Millisecond)
+	c.Set("a", 3)
+
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := c.Get("a"); v != 3 {
+		t.Errorf("Expected the buffered value not to overwrite a later Set, got %d", v)
+	}
+
+	c.SetThrottled("b", 1, 10*time.Millisecond)
+	c.SetThrottled("b", 2, 10*time.Millisecond)
+	c.SetWithTimeout("b", 3, time.Hour)
+
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := c.Get("b"); v != 3 {
+		t.Errorf("Expected the buffered value not to overwrite a later SetWithTimeout, got %d", v)
+	}
+}
+
+func TestEvictionListReusesSlots_LRU(t *testing.T) {
+	c := NewLRU[int, int](10)
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+		if i%3 == 0 {
+			c.Delete(i - 1)
+		}
+	}
+
+	if slots := len(c.evictionList.items) - 1; slots > 10 {
+		t.Errorf("Expected evicted and deleted slots to be reused, got %d slots for 10 entries", slots)
+	}
+	if err := c.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetProtected_LRU(t *testing.T) {
+	c := NewLRU[string, int](3)
+	if !c.SetProtected("a", 1, 0) {
+		t.Fatalf("Expected SetProtected to store a")
+	}
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	// a is the least recently used entry, but the unprotected ones are evicted instead
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected protected a to survive eviction, got %v, %v", v, ok)
+	}
+	if c.Len() != 3 {
+		t.Errorf("Expected 3 entries, got %d", c.Len())
+	}
+
+	c.Unprotect("a")
+	c.Set("x", 0)
+	c.Set("y", 0)
+	c.Set("z", 0)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to be evicted after Unprotect")
+	}
+	if err := c.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetProtected_Full_LRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.SetProtected("a", 1, 0)
+	c.SetProtected("b", 2, time.Minute)
+
+	if c.SetProtected("c", 3, 0) {
+		t.Errorf("Expected SetProtected to fail when every entry is protected")
+	}
+	c.Set("d", 4)
+	if _, ok := c.Get("d"); ok {
+		t.Errorf("Expected Set to drop the key when every entry is protected")
+	}
+	if !c.SetProtected("a", 10, 0) {
+		t.Errorf("Expected SetProtected to update an existing key")
+	}
+
+	c.Delete("b")
+	if !c.SetProtected("c", 3, 0) {
+		t.Errorf("Expected SetProtected to store c after a delete")
+	}
+	if err := c.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// flushStore records the values flushed by WithWriteBehind.
+type flushStore struct {
+	mu     sync.Mutex
+	values map[string]int
+}
+
+func (s *flushStore) flush(k string, v int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[k] = v
+	return nil
+}
+
+func (s *flushStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.values)
+}
+
+func (s *flushStore) flushed(k string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[k]
+}
+
+func TestWithWriteBehind_LRU(t *testing.T) {
+	store := &flushStore{values: make(map[string]int)}
+	c := NewLRU(10, WithWriteBehind(store.flush, 8, 10*time.Millisecond))
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), i) // most keys are evicted, but their values still reach the store
+	}
+	c.Set("0", -1)
+
+	deadline := time.Now().Add(time.Second)
+	for store.len() < 100 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if store.len() != 100 {
+		t.Fatalf("Expected all 100 keys to be flushed, got %d", store.len())
+	}
+	for store.flushed("0") != -1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if v := store.flushed("0"); v != -1 {
+		t.Errorf("Expected the latest value of 0 to be flushed, got %d", v)
+	}
+}
+
+func TestWithWriteBehind_CloseDrains_LRU(t *testing.T) {
+	store := &flushStore{values: make(map[string]int)}
+	c := NewLRU(10, WithWriteBehind(store.flush, 100, time.Hour))
+
+	c.Set("a", 1)
+	c.SetWithTimeout("b", 2, time.Minute)
+	c.ReplaceIfPresent("a", 3)
+	c.Close()
+
+	if store.len() != 2 || store.flushed("a") != 3 || store.flushed("b") != 2 {
+		t.Errorf("Expected Close to flush a=3 and b=2, got %v", store.values)
+	}
+}
+
+func TestWithWriteBehind_CloseDoesNotHoldLock_LRU(t *testing.T) {
+	flushing := make(chan struct{})
+	release := make(chan struct{})
+	flush := func(k string, v int) error {
+		close(flushing)
+		<-release
+		return nil
+	}
+	c := NewLRU(10, WithWriteBehind(flush, 100, time.Hour))
+	c.Set("a", 1)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	<-flushing
+
+	got := make(chan struct{})
+	go func() {
+		c.Get("a")
+		c.Len()
+		close(got)
+	}()
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the cache not to be locked while Close waits for the final flush")
+	}
+
+	close(release)
+	<-closed
+}
+
+func TestWithWriteBehind_RetriesFailedFlush_LRU(t *testing.T) {
+	store := &flushStore{values: make(map[string]int)}
+	var mu sync.Mutex
+	var failures []string
+	fail := true
+	flush := func(k string, v int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			fail = false
+			return errors.New("store unavailable")
+		}
+		return store.flush(k, v)
+	}
+	onError := func(k string, v int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, k)
+	}
+
+	c := NewLRU(10, WithWriteBehind(flush, 1, time.Millisecond), WithWriteBehindErrorHook(onError))
+	c.Set("a", 1)
+
+	deadline := time.Now().Add(time.Second)
+	for store.len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	c.Close()
+
+	if store.flushed("a") != 1 {
+		t.Errorf("Expected the failed flush to be retried")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(failures, []string{"a"}) {
+		t.Errorf("Expected one failure reported for a, got %v", failures)
+	}
+}
+
+func TestWithWriteBehindRetry_LRU(t *testing.T) {
+	store := &flushStore{values: make(map[string]int)}
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	var failures []string
+	flush := func(k string, v int) error {
+		mu.Lock()
+		attempts[k]++
+		n := attempts[k]
+		mu.Unlock()
+		if k == "down" || n <= 3 {
+			return errors.New("store unavailable")
+		}
+		return store.flush(k, v)
+	}
+	onError := func(k string, v int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, k)
+	}
+
+	c := NewLRU(10,
+		WithWriteBehind(flush, 1, time.Millisecond),
+		WithWriteBehindErrorHook(onError),
+		WithWriteBehindRetry[string, int](5, time.Millisecond),
+	)
+	c.Set("a", 1)
+	deadline := time.Now().Add(time.Second)
+	for store.len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if store.flushed("a") != 1 {
+		t.Fatalf("Expected a to be delivered after 3 failed attempts")
+	}
+
+	c.Set("b", 2)
+	c.Set("down", 3)
+	c.Close() // drains b and gives up on down after 5 attempts
+
+	if store.flushed("b") != 2 {
+		t.Errorf("Expected Close to deliver b after retrying")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["a"] != 4 || attempts["b"] != 4 || attempts["down"] != 5 {
+		t.Errorf("Expected 4 attempts for a and b and 5 for down, got %v", attempts)
+	}
+	if !slices.Equal(failures, []string{"down"}) {
+		t.Errorf("Expected only down to be reported as failed, got %v", failures)
+	}
+}
+
+func TestDeleteWithTombstone_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(10, WithClock[string, int](clock))
+	c.Set("a", 1)
+	c.DeleteWithTombstone("a", time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to be deleted")
+	}
+	if !c.IsTombstoned("a") {
+		t.Errorf("Expected a to be tombstoned")
+	}
+
+	c.Set("a", 2)
+	if c.NotFoundSet("a", 3) {
+		t.Errorf("Expected NotFoundSet of a tombstoned key to be rejected")
+	}
+	if err := c.TrySet("a", 4); err != ErrTombstoned {
+		t.Errorf("Expected ErrTombstoned, got %v", err)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected writes of a tombstoned key to be rejected")
+	}
+
+	clock.Advance(2 * time.Minute)
+	if c.IsTombstoned("a") {
+		t.Errorf("Expected the tombstone to expire")
+	}
+	c.Set("a", 5)
+	if v, ok := c.Get("a"); !ok || v != 5 {
+		t.Errorf("Expected a to be written after the tombstone expired, got %v, %v", v, ok)
+	}
+}
+
+func TestDeleteWithTombstone_Overwrite_LRU(t *testing.T) {
+	c := NewLRU(10, WithTombstoneOverwrite[string, int]())
+	c.Set("a", 1)
+	c.DeleteWithTombstone("a", time.Minute)
+
+	c.Set("a", 2)
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("Expected the write to be accepted, got %v, %v", v, ok)
+	}
+	if c.IsTombstoned("a") {
+		t.Errorf("Expected the write to clear the tombstone")
+	}
+}
+
+func TestDeleteWithTombstone_Bounded_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(2, WithClock[string, int](clock))
+	c.DeleteWithTombstone("a", time.Second)
+	c.DeleteWithTombstone("b", time.Second)
+	clock.Advance(2 * time.Second)
+	c.DeleteWithTombstone("c", time.Second)
+
+	c.mu.Lock()
+	n := len(c.tombstones)
+	c.mu.Unlock()
+	if n != 1 {
+		t.Errorf("Expected expired tombstones to be pruned, got %d tombstones", n)
+	}
+}
+
+func TestDeleteWithTombstone_Capped_LRU(t *testing.T) {
+	c := NewLRU[int, int](2)
+	for i := 0; i < 1000; i++ {
+		c.DeleteWithTombstone(i%10, time.Hour)
+	}
+
+	c.mu.Lock()
+	n, logged := len(c.tombstones), len(c.tombstoneLog)
+	c.mu.Unlock()
+	if n != 2 {
+		t.Errorf("Expected the tombstones to be capped at the cache size, got %d tombstones", n)
+	}
+	if logged > 2*n+16 {
+		t.Errorf("Expected the tombstone log to stay bounded, got %d records", logged)
+	}
+	if c.IsTombstoned(7) || !c.IsTombstoned(8) || !c.IsTombstoned(9) {
+		t.Errorf("Expected the most recent tombstones to be kept")
+	}
+}
+
+func TestGetOrCompute_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	compute := func(k string) (int, error) {
+		calls.Add(1)
+		<-release
+		return len(k), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrCompute("abc", compute); err != nil || v != 3 {
+				t.Errorf("Expected 3, got %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected concurrent calls for the same key to compute once, got %d", n)
+	}
+	if v, err := c.GetOrCompute("abc", compute); err != nil || v != 3 || calls.Load() != 1 {
+		t.Errorf("Expected the stored value without computing again, got %v, %v", v, err)
+	}
+
+	if _, err := c.GetOrCompute("fail", func(string) (int, error) { return 0, errors.New("boom") }); err == nil {
+		t.Errorf("Expected the error of compute")
+	}
+	if _, ok := c.Get("fail"); ok {
+		t.Errorf("Expected nothing to be stored for a failed computation")
+	}
+}
+
+func TestGetOrComputeTimed_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	release := make(chan struct{})
+	loader := func() (int, error) {
+		<-release
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	durations := make([]time.Duration, 5)
+	for i := range durations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, durations[i], _ = c.GetOrComputeTimed("a", loader)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, d := range durations {
+		if d < 10*time.Millisecond || d != durations[0] {
+			t.Errorf("Expected every caller to receive the duration of the single load, got %v", durations)
+			break
+		}
+	}
+
+	v, d, err := c.GetOrComputeTimed("a", loader)
+	if err != nil || v != 1 || d != 0 {
+		t.Errorf("Expected a hit to report a zero duration, got %v, %v, %v", v, d, err)
+	}
+}
+
+func TestGetOrCompute_DifferentKeysInParallel_LRU(t *testing.T) {
+	c := NewLRU[string, int](10)
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	compute := func(k string) (int, error) {
+		started <- k
+		<-release
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	for _, k := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.GetOrCompute(k, compute)
+		}()
+	}
+
+	// Both computations must be running at the same time before either is released
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected computations of different keys to run in parallel")
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if c.Count() != 2 {
+		t.Errorf("Expected both keys to be stored, got %d", c.Count())
+	}
+	c.computing.mu.Lock()
+	defer c.computing.mu.Unlock()
+	if len(c.computing.calls) != 0 {
+		t.Errorf("Expected no in-flight computations to be left, got %d", len(c.computing.calls))
+	}
+}
+
+func TestWithRetainExpired_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(10, WithClock[string, int](clock), WithRetainExpired[string, int]())
+	c.SetWithTimeout("a", 1, time.Second)
+	c.Set("b", 2)
+	clock.Advance(2 * time.Second)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected expired a to be treated as absent")
+	}
+	if _, p := c.GetState("a"); p != Expired {
+		t.Errorf("Expected a to be reported as expired, got %v", p)
+	}
+	c.sweep()
+	if v, ok := c.GetExpired("a"); !ok || v != 1 {
+		t.Errorf("Expected the expired value of a to be retained, got %v, %v", v, ok)
+	}
+	if _, ok := c.GetExpired("b"); ok {
+		t.Errorf("Expected GetExpired to ignore live keys")
+	}
+	if c.Len() != 2 || c.Count() != 1 {
+		t.Errorf("Expected 2 stored entries of which 1 is live, got Len=%d Count=%d", c.Len(), c.Count())
+	}
+
+	if n := c.PurgeExpired(); n != 1 {
+		t.Errorf("Expected PurgeExpired to remove 1 entry, got %d", n)
+	}
+	if _, ok := c.GetExpired("a"); ok {
+		t.Errorf("Expected a to be removed by PurgeExpired")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected 1 entry left, got %d", c.Len())
+	}
+}
+
+func TestGetExpired_RemovedWithoutRetain_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(10, WithClock[string, int](clock))
+	c.SetWithTimeout("a", 1, time.Second)
+	clock.Advance(2 * time.Second)
+
+	if v, ok := c.GetExpired("a"); !ok || v != 1 {
+		t.Errorf("Expected the expired value before it is observed, got %v, %v", v, ok)
+	}
+	c.Get("a")
+	if _, ok := c.GetExpired("a"); ok {
+		t.Errorf("Expected Get to remove the expired entry without WithRetainExpired")
+	}
+}
+
+func TestAgeRange_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(3, WithClock[string, int](clock), WithAgeTracking[string, int]())
+	if oldest, newest := c.AgeRange(); !oldest.IsZero() || !newest.IsZero() {
+		t.Errorf("Expected zero times for an empty cache, got %v, %v", oldest, newest)
+	}
+
+	start := clock.Now()
+	for i, k := range []string{"a", "b", "c"} {
+		clock.Set(start.Add(time.Duration(i) * time.Minute))
+		c.Set(k, i)
+	}
+	clock.Set(start.Add(10 * time.Minute))
+	c.Set("b", 10) // updates keep their insertion time
+
+	oldest, newest := c.AgeRange()
+	if !oldest.Equal(start) || !newest.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected range [%v, %v], got [%v, %v]", start, start.Add(2*time.Minute), oldest, newest)
+	}
+
+	// Evicting a and deleting d moves both ends of the range
+	clock.Set(start.Add(20 * time.Minute))
+	c.Set("d", 3)
+	c.Delete("d")
+	oldest, newest = c.AgeRange()
+	if !oldest.Equal(start.Add(time.Minute)) || !newest.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected range [%v, %v], got [%v, %v]", start.Add(time.Minute), start.Add(2*time.Minute), oldest, newest)
+	}
+
+	// Stale records of churned keys do not accumulate
+	for i := 0; i < 1000; i++ {
+		c.Set("x", i)
+		c.Delete("x")
+	}
+	c.mu.Lock()
+	n := len(c.inserts)
+	c.mu.Unlock()
+	if n > 2*c.Len()+17 {
+		t.Errorf("Expected stale insertion records to be dropped, got %d records", n)
+	}
+}
+
+func TestWithReadBuffer_LRU(t *testing.T) {
+	c := NewLRU(3, WithReadBuffer[string, int](64))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected 1, got %v, %v", v, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("Expected missing key to be missing")
+	}
+
+	// The buffered hit of a is applied before d is inserted, so b is the least recently used key
+	if evicted, key := c.SetReport("d", 4); !evicted || key != "b" {
+		t.Errorf("Expected b to be evicted, got (%v, %q)", evicted, key)
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", s)
+	}
+	if err := c.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithReadBuffer_Concurrent_LRU(t *testing.T) {
+	c := NewLRU(100, WithReadBuffer[int, int](256))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if i%4 == 0 {
+					c.Set(i%200, i)
+				} else {
+					c.Get(i % 200)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.validate(); err != nil {
+		t.Error(err)
+	}
+	if c.Len() > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", c.Len())
+	}
+}
+
+func TestWithEventChannel_LRU(t *testing.T) {
+	clock := NewMockClock()
+	events := make(chan Event[string, int], 10)
+	c := NewLRU(2, WithClock[string, int](clock), WithEventChannel(events))
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3) // evicts a
+	c.Delete("b")
+	c.SetWithTimeout("d", 4, time.Second)
+	clock.Advance(2 * time.Second)
+	c.Get("d") // removes the expired d
+	c.Purge()  // removes c
+
+	want := []Event[string, int]{
+		{Key: "a", Value: 1, Reason: ReasonEvicted},
+		{Key: "b", Value: 2, Reason: ReasonDeleted},
+		{Key: "d", Value: 4, Reason: ReasonExpired},
+		{Key: "c", Value: 3, Reason: ReasonDeleted},
+	}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e != w {
+				t.Errorf("Expected event %+v, got %+v", w, e)
+			}
+		default:
+			t.Fatalf("Expected event %+v, got none", w)
+		}
+	}
+	if name := ReasonExpired.String(); name != "expired" {
+		t.Errorf("Expected reason name expired, got %s", name)
+	}
+
+	// Events are dropped while the channel is full
+	for i := 0; i < 12; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+	if len(events) != 10 || c.DroppedEvents() != 0 {
+		t.Fatalf("Expected 10 queued events and none dropped, got %d and %d", len(events), c.DroppedEvents())
+	}
+	c.Set("x", 0)
+	if c.DroppedEvents() != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", c.DroppedEvents())
+	}
+
+	// Close sends no events, so the channel can be closed afterwards
+	for len(events) > 0 {
+		<-events
+	}
+	c.Close()
+	close(events)
+	if e, ok := <-events; ok {
+		t.Errorf("Expected no events after Close, got %+v", e)
+	}
+}
+
+func TestRename_KeepsRecency_LRU(t *testing.T) {
+	c := NewLRU[string, int](3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	c.Rename("a", "z")
+	c.Set("d", 4) // evicts z, which is still the least recently used entry
+
+	if _, ok := c.Get("z"); ok {
+		t.Errorf("Expected renamed z to keep the recency of a and be evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("Expected b to be present")
+	}
+}
+
+func TestSetClass_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(10,
+		WithClock[string, int](clock),
+		WithClass[string, int]("high", 3, 0),
+		WithClass[string, int]("low", 3, time.Minute),
+	)
+
+	for i := 0; i < 3; i++ {
+		c.SetClass("high"+strconv.Itoa(i), i, "high")
+	}
+	for i := 0; i < 100; i++ {
+		c.SetClass("low"+strconv.Itoa(i), i, "low")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, ok := c.Get("high" + strconv.Itoa(i)); !ok {
+			t.Errorf("Expected high%d to survive the flood of low entries", i)
+		}
+	}
+	if got := c.Count(); got != 6 {
+		t.Errorf("Expected 3 high and 3 low entries, got %d entries", got)
+	}
+	for _, k := range []string{"low97", "low98", "low99"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("Expected %s, one of the most recent low entries, to be present", k)
+		}
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The high class evicts its own least recently used entry
+	c.Get("high0")
+	c.SetClass("high3", 3, "high")
+	if _, ok := c.Get("high1"); ok {
+		t.Errorf("Expected high1 to be evicted from the high class")
+	}
+	if _, ok := c.Get("low97"); !ok {
+		t.Errorf("Expected low entries to be unaffected by the high class")
+	}
+
+	// Moving a key to another class
+	c.SetClass("low99", 99, "high")
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("high2"); ok {
+		t.Errorf("Expected high2 to be evicted once low99 joined the high class")
+	}
+
+	// The class TTL applies
+	clock.Advance(2 * time.Minute)
+	if _, ok := c.Get("low98"); ok {
+		t.Errorf("Expected low98 to expire with the TTL of its class")
+	}
+	if _, ok := c.Get("low99"); !ok {
+		t.Errorf("Expected low99 to keep the TTL of the high class")
+	}
+
+	c.Purge()
+	c.SetClass("a", 1, "unknown")
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Expected a key of an unknown class to be set without a class")
+	}
+}
+
+func TestBulkLoad_LRU(t *testing.T) {
+	c := NewLRU[string, int](4)
+	c.Set("x", 0)
+	c.Set("y", 0)
+	c.Get("x")
+
+	c.BulkLoad(map[string]int{"a": 1, "b": 2, "c": 3})
+	if _, ok := c.Get("y"); ok {
+		t.Errorf("Expected y, the least recently used entry, to be evicted")
+	}
+	for _, k := range []string{"x", "a", "b", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("Expected %s to survive the load", k)
+		}
+	}
+
+	items := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		items[strconv.Itoa(i)] = i
+	}
+	c.BulkLoad(items)
+	if c.Count() != 4 || c.Stats().Evictions != 11 {
+		t.Errorf("Expected 4 entries after 11 evictions, got %d entries and %+v", c.Count(), c.Stats())
+	}
+	for k, v := range c.GetAll() {
+		if items[k] != v {
+			t.Errorf("Expected only loaded entries to survive, got %s=%d", k, v)
+		}
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithAutoCost_LRU(t *testing.T) {
+	value := strings.Repeat("x", 100)
+	// The string headers of the key and value, plus their bytes
+	entryCost := int64(unsafe.Sizeof("")*2) + 3 + 100
+	c := NewLRU(100, WithMaxCost[string, string](10*entryCost, nil), WithAutoCost[string, string]())
+
+	for i := 0; i < 20; i++ {
+		c.Set("k"+strconv.Itoa(10+i), value)
+	}
+
+	if c.Count() != 10 {
+		t.Errorf("Expected the byte budget to hold 10 entries, got %d", c.Count())
+	}
+	if _, ok := c.Get("k19"); ok {
+		t.Errorf("Expected the least recently used entries to be evicted")
+	}
+	if _, ok := c.Get("k29"); !ok {
+		t.Errorf("Expected the most recent entry to be present")
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirtyKeys_LRU(t *testing.T) {
+	c := NewLRU(2, WithDirtyTracking[string, int]())
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	if got := c.DirtyKeys(); !equalKeys(got, []string{"a", "b"}) {
+		t.Errorf("Expected a and b to be dirty, got %v", got)
+	}
+	if got := c.DirtyKeys(); got != nil {
+		t.Errorf("Expected the dirty set to be reset, got %v", got)
+	}
+
+	c.Get("a") // reads do not mark keys
+	c.ReplaceIfPresent("a", 3)
+	c.Delete("missing")
+	c.Set("c", 4) // evicts b
+	if got := c.DirtyKeys(); !equalKeys(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected a, b and c to be dirty, got %v", got)
+	}
+
+	c.Delete("a")
+	if got := c.DirtyKeys(); !equalKeys(got, []string{"a"}) {
+		t.Errorf("Expected the deleted a to be dirty, got %v", got)
+	}
+
+	if got := NewLRU[string, int](2).DirtyKeys(); got != nil {
+		t.Errorf("Expected no dirty keys without WithDirtyTracking, got %v", got)
+	}
+}
+
+// equalKeys reports whether got holds the keys of want in any order.
+func equalKeys(got, want []string) bool {
+	got = slices.Clone(got)
+	slices.Sort(got)
+	return slices.Equal(got, want)
+}
+
+func TestRecentlyEvicted_LRU(t *testing.T) {
+	c := NewLRU(2, WithEvictionHistory[string, int](3))
+	defer c.Close()
+
+	if got := c.RecentlyEvicted(); len(got) != 0 {
+		t.Errorf("Expected no evicted keys, got %v", got)
+	}
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3) // evicts a
+	c.Set("d", 4) // evicts b
+	if got := c.RecentlyEvicted(); !slices.Equal(got, []string{"b", "a"}) {
+		t.Errorf("Expected [b a], got %v", got)
+	}
+
+	c.Delete("c")
+	c.Set("e", 5)
+	c.Set("f", 6) // evicts d
+	c.Set("g", 7) // evicts e
+	c.Set("h", 8) // evicts f
+	if got := c.RecentlyEvicted(); !slices.Equal(got, []string{"f", "e", "d"}) {
+		t.Errorf("Expected the history to be bounded to [f e d], got %v", got)
+	}
+
+	plain := NewLRU[string, int](1)
+	defer plain.Close()
+	plain.Set("a", 1)
+	plain.Set("b", 2)
+	if got := plain.RecentlyEvicted(); got != nil {
+		t.Errorf("Expected nil without WithEvictionHistory, got %v", got)
+	}
+}
+
+func TestNotFoundSet_RefreshesTTL_LRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewLRU(10, WithClock[string, int](clock), WithNotFoundSetRefreshesTTL[string, int]())
+	defer c.Close()
+	plain := NewLRU(10, WithClock[string, int](clock))
+	defer plain.Close()
+
+	for _, cache := range []*LRUCache[string, int]{c, plain} {
+		cache.SetWithTimeout("a", 1, time.Minute)
+		cache.Set("forever", 2)
+	}
+	clock.Advance(50 * time.Second)
+
+	if c.NotFoundSetWithTimeout("a", 10, time.Minute) || plain.NotFoundSetWithTimeout("a", 10, time.Minute) {
+		t.Errorf("Expected NotFoundSetWithTimeout to report a live key as not set")
+	}
+	if c.NotFoundSetWithTimeout("forever", 20, time.Minute) {
+		t.Errorf("Expected NotFoundSetWithTimeout to report a live key as not set")
+	}
+	clock.Advance(50 * time.Second)
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected the TTL to be refreshed without overwriting the value, got %v, %v", v, ok)
+	}
+	if _, ok := plain.Get("a"); ok {
+		t.Errorf("Expected the TTL not to be refreshed without the option")
+	}
+	if e, ok := c.GetWithExpiration("forever"); !ok || e.Value != 2 || !e.ExpireAt.IsZero() {
+		t.Errorf("Expected an entry without expiration to be left unchanged, got %+v, %v", e, ok)
+	}
+}
+
+func TestWithWatermarks_LRU(t *testing.T) {
+	c := NewLRU(100, WithWatermarks[int, int](0.9, 0.5))
+	defer c.Close()
+
+	for i := 0; i < 90; i++ {
+		c.Set(i, i)
+	}
+	if c.Len() != 90 || c.Stats().Evictions != 0 {
+		t.Fatalf("Expected 90 entries without evictions below the high watermark, got %d, %+v", c.Len(), c.Stats())
+	}
+
+	c.Set(90, 90)
+	if c.Len() != 51 {
+		t.Errorf("Expected the cache to evict down to the low watermark before adding, got Len=%d", c.Len())
+	}
+	if ev := c.Stats().Evictions; ev != 40 {
+		t.Errorf("Expected 40 evictions in one batch, got %d", ev)
+	}
+	if _, ok := c.Get(39); ok {
+		t.Errorf("Expected the least recently used entries to be evicted")
+	}
+	if _, ok := c.Get(40); !ok {
+		t.Errorf("Expected the entries above the low watermark to be kept")
+	}
+
+	for i := 91; i < 130; i++ {
+		c.Set(i, i)
+	}
+	if c.Len() != 90 || c.Stats().Evictions != 40 {
+		t.Errorf("Expected no evictions until the high watermark is reached again, got Len=%d, %+v", c.Len(), c.Stats())
+	}
+}
+
+func TestTTL_KeepsRecency_LRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.TTL("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected TTL not to mark a as recently used")
+	}
+}
@@ -0,0 +1,90 @@
+package incache
+
+import "time"
+
+// lruClass is a class of entries configured with WithClass. Its entries are linked in a second list,
+// threaded through the items of the eviction list, ordered from the most recently used (front)
+// to the least recently used (back), so that the class can evict its own entries.
+// An index of 0 means "no item".
+type lruClass struct {
+	name        string
+	capacity    uint // maximum number of entries of the class, 0 means only the cache size applies
+	ttl         time.Duration
+	front, back int
+	len         int
+}
+
+// classConfig is a class as configured by WithClass.
+type classConfig struct {
+	name     string
+	capacity uint
+	ttl      time.Duration
+}
+
+// newClasses creates the classes configured by the options. A class configured twice keeps the last configuration.
+func newClasses(configs []classConfig) []lruClass {
+	var classes []lruClass
+	for _, cfg := range configs {
+		cl := lruClass{name: cfg.name, capacity: cfg.capacity, ttl: cfg.ttl}
+		if i := classIndex(classes, cfg.name); i > 0 {
+			classes[i-1] = cl
+		} else {
+			classes = append(classes, cl)
+		}
+	}
+	return classes
+}
+
+// classIndex returns the index of the named class plus 1, as stored in lruItem.class, or 0 if there is no such class.
+func classIndex(classes []lruClass, name string) int {
+	for i := range classes {
+		if classes[i].name == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// full reports whether the class holds more entries than its capacity.
+func (cl *lruClass) full() bool {
+	return cl.capacity > 0 && uint(cl.len) > cl.capacity
+}
+
+// classLink inserts the item at index i at the front of the list of the class.
+func (l *lruList[K, V]) classLink(cl *lruClass, i int) {
+	item := &l.items[i]
+	item.classPrev = 0
+	item.classNext = cl.front
+	if cl.front != 0 {
+		l.items[cl.front].classPrev = i
+	} else {
+		cl.back = i
+	}
+	cl.front = i
+	cl.len++
+}
+
+// classUnlink removes the item at index i from the list of the class.
+func (l *lruList[K, V]) classUnlink(cl *lruClass, i int) {
+	prev, next := l.items[i].classPrev, l.items[i].classNext
+	if prev != 0 {
+		l.items[prev].classNext = next
+	} else {
+		cl.front = next
+	}
+	if next != 0 {
+		l.items[next].classPrev = prev
+	} else {
+		cl.back = prev
+	}
+	cl.len--
+}
+
+// classMoveToFront moves the item at index i to the front of the list of the class.
+func (l *lruList[K, V]) classMoveToFront(cl *lruClass, i int) {
+	if cl.front == i {
+		return
+	}
+	l.classUnlink(cl, i)
+	l.classLink(cl, i)
+}
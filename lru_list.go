@@ -0,0 +1,100 @@
+package incache
+
+// lruList is a doubly linked list of lruItems ordered from the most recently used (front)
+// to the least recently used (back). The items are stored by value in a slice and linked by their indices,
+// so the list neither allocates per item nor needs type assertions like container/list.
+// Slots of removed items are kept in a free list and reused by later insertions.
+//
+// Index 0 is a sentinel: its next is the front of the list and its prev the back,
+// and an index of 0 means "no item". Pointers returned by at are only valid until the next PushFront,
+// which may grow the slice.
+type lruList[K comparable, V any] struct {
+	items []lruItem[K, V]
+	free  int // first slot of the free list, linked through next, 0 if empty
+	len   int
+}
+
+// Init clears the list and preallocates room for capacity items.
+func (l *lruList[K, V]) Init(capacity int) {
+	l.items = make([]lruItem[K, V], 1, capacity+1)
+	l.free = 0
+	l.len = 0
+}
+
+// Len returns the number of items in the list.
+func (l *lruList[K, V]) Len() int {
+	return l.len
+}
+
+// at returns the item at index i.
+func (l *lruList[K, V]) at(i int) *lruItem[K, V] {
+	return &l.items[i]
+}
+
+// Front returns the index of the most recently used item, or 0 if the list is empty.
+func (l *lruList[K, V]) Front() int {
+	return l.items[0].next
+}
+
+// Back returns the index of the least recently used item, or 0 if the list is empty.
+func (l *lruList[K, V]) Back() int {
+	return l.items[0].prev
+}
+
+// Next returns the index of the item after i, towards the least recently used item, or 0 at the back of the list.
+func (l *lruList[K, V]) Next(i int) int {
+	return l.items[i].next
+}
+
+// Prev returns the index of the item before i, towards the most recently used item, or 0 at the front of the list.
+func (l *lruList[K, V]) Prev(i int) int {
+	return l.items[i].prev
+}
+
+// PushFront stores item in a free slot, or a new one, at the front of the list and returns its index.
+func (l *lruList[K, V]) PushFront(item lruItem[K, V]) int {
+	i := l.free
+	if i != 0 {
+		l.free = l.items[i].next
+		l.items[i] = item
+	} else {
+		i = len(l.items)
+		l.items = append(l.items, item)
+	}
+	l.link(i)
+	l.len++
+	return i
+}
+
+// Remove removes the item at index i from the list and releases its slot.
+func (l *lruList[K, V]) Remove(i int) {
+	l.unlink(i)
+	l.items[i] = lruItem[K, V]{next: l.free} // clear the key and value so they can be garbage collected
+	l.free = i
+	l.len--
+}
+
+// MoveToFront moves the item at index i to the front of the list.
+func (l *lruList[K, V]) MoveToFront(i int) {
+	if l.items[0].next == i {
+		return
+	}
+	l.unlink(i)
+	l.link(i)
+}
+
+// link inserts the item at index i after the sentinel.
+func (l *lruList[K, V]) link(i int) {
+	front := l.items[0].next
+	l.items[i].prev = 0
+	l.items[i].next = front
+	l.items[front].prev = i
+	l.items[0].next = i
+}
+
+// unlink detaches the item at index i from its neighbours.
+func (l *lruList[K, V]) unlink(i int) {
+	prev, next := l.items[i].prev, l.items[i].next
+	l.items[prev].next = next
+	l.items[next].prev = prev
+}
@@ -1,6 +1,8 @@
 package incache
 
 import (
+	"fmt"
+	"math"
 	"sync"
 	"time"
 )
@@ -13,27 +15,58 @@ type MCache[K comparable, V any] struct {
 	size         uint
 	m            map[K]valueWithTimeout[V] // where the key-value pairs are stored
 	stopCh       chan struct{}             // Channel to signal timeout goroutine to stop
-	timeInterval time.Duration             // Time interval to sleep the goroutine that checks for expired keys
+	timeInterval time.Duration             // Initial time interval to sleep the goroutine that checks for expired keys
+	sweeper      *sweeper
+	closed       bool
+	stats        Stats
+	lastEvicted  K // key of the most recently evicted item, reported by SetReport
+	opts         options[K, V]
 }
 
 type valueWithTimeout[V any] struct {
-	value    V
-	expireAt int64 // Unix nano timestamp, 0 means no expiration
+	value      V
+	expireAt   int64 // Unix nano timestamp, 0 means no expiration
+	lastAccess int64 // Unix nano timestamp, only recorded with WithSampledLRU
 }
 
 // NewManual creates a new cache instance with optional configuration provided by the specified options.
 // The cache starts a background goroutine to periodically check for expired keys based on the configured time interval.
-// If size is 0, the cache will not store any items.
-func NewManual[K comparable, V any](size uint, timeInterval time.Duration) *MCache[K, V] {
+// If size is 0, the cache will not store any items unless WithUnbounded is used; with WithStrictSizing, it panics instead.
+func NewManual[K comparable, V any](size uint, timeInterval time.Duration, opts ...Option[K, V]) *MCache[K, V] {
+	o := applyOptions(opts)
 	c := &MCache[K, V]{
-		m:            make(map[K]valueWithTimeout[V]),
-		stopCh:       make(chan struct{}),
-		size:         size,
-		timeInterval: timeInterval,
+		m:      make(map[K]valueWithTimeout[V], o.initialCapacity),
+		stopCh: make(chan struct{}),
+		size:   o.capacity(size),
+		opts:   o,
+	}
+	c.sweeper = c.opts.newSweeper(timeInterval)
+	if !registerGlobalSweep(c.stopCh, c.sweeper, c.sweep) {
+		c.timeInterval = c.sweeper.currentInterval()
 	}
 	if c.timeInterval > 0 {
 		go c.expireKeys()
 	}
+	c.opts.startReporter(c.stopCh, c.Stats)
+	c.opts.startPressureMonitor(c.stopCh, c.shed)
+	return c
+}
+
+// NewManualFrom creates a new cache instance like NewManual, filled with the entries of seed.
+// The internal map is presized for the seed entries unless WithInitialCapacity is given.
+// If the seed holds more than size entries, the cache keeps size of them; since map iteration order
+// is unspecified, which entries are kept is arbitrary.
+func NewManualFrom[K comparable, V any](size uint, timeInterval time.Duration, seed map[K]V, opts ...Option[K, V]) *MCache[K, V] {
+	c := NewManual(size, timeInterval, withSeedCapacity(opts, len(seed), size)...)
+	if c.size == 0 {
+		return c
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range seed {
+		c.set(k, valueWithTimeout[V]{value: v})
+	}
 	return c
 }
 
@@ -47,24 +80,35 @@ func (c *MCache[K, V]) Set(k K, v V) {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
-
-	// If key exists, just update
-	if _, ok := c.m[k]; ok {
-		c.m[k] = valueWithTimeout[V]{
-			value:    v,
-			expireAt: 0,
-		}
-		return
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Set", time.Now())
 	}
 
-	if uint(len(c.m)) >= c.size {
-		c.evict(1)
+	c.set(k, valueWithTimeout[V]{
+		value:    v,
+		expireAt: 0,
+	})
+}
+
+// TrySet adds or updates a key-value pair like Set, but reports why the pair could not be stored.
+// Unlike Set, it never evicts: it returns ErrCacheFull if the key is new and the cache is at capacity.
+// It returns ErrCacheClosed if the cache has been closed.
+func (c *MCache[K, V]) TrySet(k K, v V) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrCacheClosed
+	}
+	if _, ok := c.m[k]; !ok && uint(len(c.m)) >= c.size {
+		return ErrCacheFull
 	}
 
-	c.m[k] = valueWithTimeout[V]{
+	c.set(k, valueWithTimeout[V]{
 		value:    v,
 		expireAt: 0,
-	}
+	})
+	return nil
 }
 
 // NotFoundSet adds a key-value pair to the database if the key does not already exist or is expired, and returns true.
@@ -77,30 +121,49 @@ func (c *MCache[K, V]) NotFoundSet(k K, v V) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if val, ok := c.m[k]; ok {
-		// Check if existing key is expired
-		if val.expireAt == 0 || val.expireAt >= time.Now().UnixNano() {
-			return false
-		}
-		// Key exists but is expired, delete it
-		delete(c.m, k)
+	return c.notFoundSet(k, v, 0)
+}
+
+// SetWithTimeout adds or updates a key-value pair in the database with an expiration time.
+// If the timeout duration is zero or negative, the key-value pair will not have an expiration time.
+// This function is safe for concurrent use.
+func (c *MCache[K, V]) SetWithTimeout(k K, v V, timeout time.Duration) {
+	if c.size == 0 {
+		return
 	}
 
-	if uint(len(c.m)) >= c.size {
-		c.evict(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("SetWithTimeout", time.Now())
+	}
+	timeout, ok := c.opts.ttl(timeout)
+	if !ok {
+		return
 	}
 
-	c.m[k] = valueWithTimeout[V]{
-		value:    v,
-		expireAt: 0,
+	var expireAt int64
+	if timeout > 0 {
+		expireAt = c.opts.now().Add(timeout).UnixNano()
 	}
-	return true
+
+	c.set(k, valueWithTimeout[V]{
+		value:    v,
+		expireAt: expireAt,
+	})
 }
 
-// SetWithTimeout adds or updates a key-value pair in the database with an expiration time.
-// If the timeout duration is zero or negative, the key-value pair will not have an expiration time.
-// This function is safe for concurrent use.
-func (c *MCache[K, V]) SetWithTimeout(k K, v V, timeout time.Duration) {
+// SetReport adds or updates a key-value pair like Set and reports whether an entry was evicted
+// to make room for it, and the key of that entry. The evicted entry is an expired one if there is any,
+// otherwise an arbitrary one. If several entries were evicted with WithEvictionBatch,
+// the key of the last one is reported.
+func (c *MCache[K, V]) SetReport(k K, v V) (evicted bool, evictedKey K) {
+	return c.SetWithTimeoutReport(k, v, 0)
+}
+
+// SetWithTimeoutReport adds or updates a key-value pair like SetWithTimeout and reports
+// whether an entry was evicted to make room for it, and the key of that entry, like SetReport.
+func (c *MCache[K, V]) SetWithTimeoutReport(k K, v V, timeout time.Duration) (evicted bool, evictedKey K) {
 	if c.size == 0 {
 		return
 	}
@@ -108,28 +171,114 @@ func (c *MCache[K, V]) SetWithTimeout(k K, v V, timeout time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	timeout, ok := c.opts.ttl(timeout)
+	if !ok {
+		return
+	}
 	var expireAt int64
 	if timeout > 0 {
-		expireAt = time.Now().Add(timeout).UnixNano()
+		expireAt = c.opts.now().Add(timeout).UnixNano()
 	}
 
-	// If key exists, just update
-	if _, ok := c.m[k]; ok {
-		c.m[k] = valueWithTimeout[V]{
-			value:    v,
-			expireAt: expireAt,
-		}
+	before := c.stats.Evictions
+	c.set(k, valueWithTimeout[V]{
+		value:    v,
+		expireAt: expireAt,
+	})
+	if c.stats.Evictions == before {
+		return false, evictedKey
+	}
+
+	evictedKey = c.lastEvicted
+	c.lastEvicted = *new(K)
+	return true, evictedKey
+}
+
+// GetSet adds or updates a key-value pair like SetWithTimeout and returns the previous value of the key
+// if it existed and had not expired, in one atomic step. Like Get, the lookup counts in Stats.
+func (c *MCache[K, V]) GetSet(k K, v V, timeout time.Duration) (old V, existed bool) {
+	if c.size == 0 {
 		return
 	}
 
-	if uint(len(c.m)) >= c.size {
-		c.evict(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if val, ok := c.get(k); ok {
+		old, existed = c.opts.copyValue(val.value), true
+	}
+	timeout, ok := c.opts.ttl(timeout)
+	if !ok {
+		return old, existed
+	}
+	var expireAt int64
+	if timeout > 0 {
+		expireAt = c.opts.now().Add(timeout).UnixNano()
 	}
 
-	c.m[k] = valueWithTimeout[V]{
+	c.set(k, valueWithTimeout[V]{
 		value:    v,
 		expireAt: expireAt,
+	})
+	return old, existed
+}
+
+// SetWithTimeoutIfSooner adds or updates a key-value pair with an expiration time,
+// but only if the new expiration would be earlier than the current one.
+// If the key does not exist or is expired, it behaves like SetWithTimeout.
+// Keys without an expiration time are considered to expire later than any timeout.
+func (c *MCache[K, V]) SetWithTimeoutIfSooner(k K, v V, timeout time.Duration) {
+	c.setWithTimeoutIf(k, v, timeout, true)
+}
+
+// SetWithTimeoutIfLater adds or updates a key-value pair with an expiration time,
+// but only if the new expiration would be later than the current one.
+// If the key does not exist or is expired, it behaves like SetWithTimeout.
+// A zero or negative timeout means no expiration, which is later than any timeout.
+func (c *MCache[K, V]) SetWithTimeoutIfLater(k K, v V, timeout time.Duration) {
+	c.setWithTimeoutIf(k, v, timeout, false)
+}
+
+func (c *MCache[K, V]) setWithTimeoutIf(k K, v V, timeout time.Duration, sooner bool) {
+	if c.size == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timeout, ok := c.opts.ttl(timeout)
+	if !ok {
+		return
+	}
+	now := c.opts.now()
+	var expireAt int64
+	if timeout > 0 {
+		expireAt = now.Add(timeout).UnixNano()
 	}
+
+	if val, ok := c.m[k]; ok {
+		if val.expireAt == 0 || val.expireAt >= c.opts.expiryNow() {
+			if sooner && !expiresBefore(expireAt, val.expireAt) {
+				return
+			}
+			if !sooner && !expiresBefore(val.expireAt, expireAt) {
+				return
+			}
+			c.store(k, valueWithTimeout[V]{
+				value:    v,
+				expireAt: expireAt,
+			})
+			return
+		}
+		// Key exists but is expired, delete it
+		c.remove(k)
+	}
+
+	c.insert(k, valueWithTimeout[V]{
+		value:    v,
+		expireAt: expireAt,
+	})
 }
 
 // NotFoundSetWithTimeout adds a key-value pair to the database with an expiration time if the key does not already exist or is expired, and returns true.
@@ -143,28 +292,141 @@ func (c *MCache[K, V]) NotFoundSetWithTimeout(k K, v V, timeout time.Duration) b
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.notFoundSet(k, v, timeout)
+}
+
+// NotFoundSetMany adds each key-value pair to the database if the key does not already exist or is expired.
+// The whole batch is applied under a single lock.
+// It returns the keys that were added, in no particular order.
+func (c *MCache[K, V]) NotFoundSetMany(items map[K]V) []K {
+	if c.size == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var added []K
+	for k, v := range items {
+		if c.notFoundSet(k, v, 0) {
+			added = append(added, k)
+		}
+	}
+	return added
+}
+
+func (c *MCache[K, V]) notFoundSet(k K, v V, timeout time.Duration) bool {
+	timeout, ok := c.opts.ttl(timeout)
+	if !ok {
+		return false
+	}
 	if val, ok := c.m[k]; ok {
 		// Check if existing key is expired
-		if val.expireAt == 0 || val.expireAt >= time.Now().UnixNano() {
+		if val.expireAt == 0 || val.expireAt >= c.opts.expiryNow() {
 			return false
 		}
 		// Key exists but is expired, delete it
-		delete(c.m, k)
+		c.remove(k)
 	}
 
 	var expireAt int64
 	if timeout > 0 {
-		expireAt = time.Now().Add(timeout).UnixNano()
+		expireAt = c.opts.now().Add(timeout).UnixNano()
+	}
+
+	return c.insert(k, valueWithTimeout[V]{
+		value:    v,
+		expireAt: expireAt,
+	})
+}
+
+// LoadOrStore returns the existing value for the key if it exists and is not expired.
+// Otherwise, it stores the given value and returns it.
+// The loaded result is true if the value was loaded, false if stored. If the value could not be stored,
+// e.g. with WithRejectOnFull when the cache is full, it returns the zero value and false, like SetIfAbsent.
+func (c *MCache[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if val, ok := c.m[k]; ok {
+		if val.expireAt == 0 || val.expireAt >= c.opts.expiryNow() {
+			return c.opts.copyValue(val.value), true
+		}
+		// Key exists but is expired, delete it
+		c.remove(k)
+	}
+
+	if c.size == 0 || !c.insert(k, valueWithTimeout[V]{value: v}) {
+		return actual, false
 	}
+	return v, false
+}
+
+// SetIfAbsent stores the value if the key does not exist or is expired and returns (v, true).
+// If the key exists, it returns the current value and false without modifying it.
+// If the value could not be stored, e.g. because the cache is closed, it returns the zero value and false.
+func (c *MCache[K, V]) SetIfAbsent(k K, v V) (current V, set bool) {
+	if c.size == 0 {
+		return current, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if uint(len(c.m)) >= c.size {
-		c.evict(1)
+	if val, ok := c.get(k); ok {
+		return c.opts.copyValue(val.value), false
+	}
+	if !c.insert(k, valueWithTimeout[V]{value: v}) {
+		return current, false
 	}
+	return v, true
+}
+
+// ReplaceIfPresent updates the value of the key if it exists and is not expired, and returns true.
+// Otherwise, it does nothing and returns false.
+// The existing expiration time of the key is preserved.
+func (c *MCache[K, V]) ReplaceIfPresent(k K, v V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.m[k] = valueWithTimeout[V]{
+	val, ok := c.m[k]
+	if !ok {
+		return false
+	}
+	if val.expireAt > 0 && val.expireAt < c.opts.expiryNow() {
+		c.remove(k)
+		return false
+	}
+
+	c.store(k, valueWithTimeout[V]{
 		value:    v,
-		expireAt: expireAt,
+		expireAt: val.expireAt,
+	})
+	return true
+}
+
+// Rename moves the entry of oldKey to newKey under a single lock, keeping its value and expiration time.
+// An existing entry of newKey is overwritten.
+// It returns false if oldKey does not exist or has expired.
+func (c *MCache[K, V]) Rename(oldKey, newKey K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	val, ok := c.m[oldKey]
+	if !ok {
+		return false
 	}
+	if val.expireAt > 0 && val.expireAt < c.opts.expiryNow() {
+		c.remove(oldKey)
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+
+	c.remove(newKey)
+	delete(c.m, oldKey)
+	c.m[newKey] = val
 	return true
 }
 
@@ -172,31 +434,238 @@ func (c *MCache[K, V]) NotFoundSetWithTimeout(k K, v V, timeout time.Duration) b
 // If the key is not found or has expired, it returns (zero value of V, false).
 // Otherwise, it returns (value, true).
 func (c *MCache[K, V]) Get(k K) (v V, b bool) {
+	if c.opts.missHook != nil {
+		defer c.opts.observeMiss(k, &b) // runs after the lock is released
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Get", time.Now())
+	}
 
-	val, ok := c.m[k]
+	val, ok := c.get(k)
 	if !ok {
 		return
 	}
-	if val.expireAt > 0 && val.expireAt < time.Now().UnixNano() {
-		delete(c.m, k)
+	return c.opts.copyValue(val.value), true
+}
+
+// HasAndTouch reports whether the key exists and has not expired. MCache keeps no eviction order,
+// so it only records the access like Get, for WithSampledLRU. It counts as a lookup in Stats.
+func (c *MCache[K, V]) HasAndTouch(k K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.get(k)
+	return ok
+}
+
+// GetOrDefault retrieves the value associated with the given key like Get,
+// or returns def if the key is not found or has expired.
+func (c *MCache[K, V]) GetOrDefault(k K, def V) V {
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	return def
+}
+
+// GetWithExpiration retrieves the value associated with the given key together with its expiration time.
+// If the key is not found or has expired, it returns (zero value of ValueTTL[V], false).
+func (c *MCache[K, V]) GetWithExpiration(k K) (v ValueTTL[V], b bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	val, ok := c.get(k)
+	if !ok {
 		return
 	}
-	return val.value, true
+	return ValueTTL[V]{Value: c.opts.copyValue(val.value), ExpireAt: expireTime(val.expireAt)}, true
+}
+
+// TTL returns the remaining time to live of the key, or NoExpiry if it never expires. It returns false
+// if the key is not found or has expired. It neither copies the value nor records an access for WithSampledLRU.
+func (c *MCache[K, V]) TTL(k K) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.m[k]
+	if !ok {
+		return 0, false
+	}
+	return remainingTTL(v.expireAt, c.opts.expiryNow())
+}
+
+// GetManyWithExpiration retrieves the values of the given keys together with their expiration times under a single lock.
+// Keys that are not found or have expired are omitted from the returned map.
+func (c *MCache[K, V]) GetManyWithExpiration(keys []K) map[K]ValueTTL[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V], len(keys))
+	for _, k := range keys {
+		if val, ok := c.get(k); ok {
+			m[k] = ValueTTL[V]{Value: c.opts.copyValue(val.value), ExpireAt: expireTime(val.expireAt)}
+		}
+	}
+
+	return m
+}
+
+// GetManyPartial retrieves the values of the given keys under a single lock and returns the keys that are
+// not found or have expired separately, in the order given, e.g. to load only the missing keys from a backing store.
+func (c *MCache[K, V]) GetManyPartial(keys []K) (found map[K]V, missing []K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found = make(map[K]V, len(keys))
+	for _, k := range keys {
+		if val, ok := c.get(k); ok {
+			found[k] = c.opts.copyValue(val.value)
+		} else {
+			missing = append(missing, k)
+		}
+	}
+
+	return found, missing
+}
+
+// GetState retrieves the value associated with the given key and reports whether it is live, expired or absent.
+// For an expired key it returns the last value and removes the key.
+func (c *MCache[K, V]) GetState(k K) (v V, p Presence) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	val, ok := c.m[k]
+	if !ok {
+		c.stats.Misses++
+		return v, Absent
+	}
+	if val.expireAt > 0 && val.expireAt < c.opts.expiryNow() {
+		c.remove(k)
+		c.stats.Misses++
+		return c.opts.copyValue(val.value), Expired
+	}
+
+	c.stats.Hits++
+	if c.opts.sampleSize > 0 {
+		c.store(k, val)
+	}
+	return c.opts.copyValue(val.value), Live
+}
+
+// get returns the non-expired value for the key. Expired keys are removed.
+func (c *MCache[K, V]) get(k K) (valueWithTimeout[V], bool) {
+	val, ok := c.m[k]
+	if !ok {
+		c.stats.Misses++
+		return val, false
+	}
+	if val.expireAt > 0 && val.expireAt < c.opts.expiryNow() {
+		c.remove(k)
+		c.stats.Misses++
+		return val, false
+	}
+	c.stats.Hits++
+	if c.opts.sampleSize > 0 {
+		c.store(k, val)
+	}
+	return val, true
 }
 
 // GetAll retrieves all key-value pairs from the cache.
 // It returns a map containing all the key-value pairs that are not expired.
 func (c *MCache[K, V]) GetAll() map[K]V {
+	m := make(map[K]V)
+	c.GetAllInto(m)
+	return m
+}
+
+// GetAllInto clears dst and fills it with all the key-value pairs of the cache that are not expired, like GetAll.
+// Reusing dst across calls saves allocating and growing a new map each time.
+func (c *MCache[K, V]) GetAllInto(dst map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(dst)
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		if v.expireAt == 0 || v.expireAt >= now {
+			dst[k] = c.opts.copyValue(v.value)
+		} else if c.opts.eagerScanCleanup {
+			c.remove(k)
+		}
+	}
+}
+
+// GetWhere retrieves the non-expired key-value pairs of the cache for which pred returns true.
+// pred is evaluated under the cache lock and must not call methods of the cache.
+func (c *MCache[K, V]) GetWhere(pred func(k K, v V) bool) map[K]V {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	m := make(map[K]V)
-	now := time.Now().UnixNano()
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		if (v.expireAt == 0 || v.expireAt >= now) && pred(k, v.value) {
+			m[k] = c.opts.copyValue(v.value)
+		}
+	}
+	return m
+}
+
+// Range calls f for each non-expired key-value pair of the cache, in no particular order, until f returns false.
+// Unlike GetAll, it does not copy the entries into a map. f is called under the cache lock and must not call
+// methods of the cache.
+func (c *MCache[K, V]) Range(f func(k K, v V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
 	for k, v := range c.m {
 		if v.expireAt == 0 || v.expireAt >= now {
-			m[k] = v.value
+			if !f(k, v.value) {
+				return
+			}
+		} else if c.opts.eagerScanCleanup {
+			c.remove(k)
+		}
+	}
+}
+
+// GetAllOrdered retrieves all non-expired key-value pairs from the cache.
+// MCache has no eviction order, so the entries are returned in arbitrary order.
+func (c *MCache[K, V]) GetAllOrdered() []Entry[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]Entry[K, V], 0, len(c.m))
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		if v.expireAt == 0 || v.expireAt >= now {
+			entries = append(entries, Entry[K, V]{Key: k, Value: c.opts.copyValue(v.value)})
+		}
+	}
+
+	return entries
+}
+
+// GetAllSorted retrieves all non-expired key-value pairs from the cache sorted by key according to less,
+// e.g. to compare the contents of the cache in tests or to export them deterministically.
+func (c *MCache[K, V]) GetAllSorted(less func(a, b K) bool) []Entry[K, V] {
+	return sortedEntries(c.GetAll(), less)
+}
+
+// GetAllWithExpiration retrieves all key-value pairs from the cache together with their expiration times.
+// It returns a map containing all the key-value pairs that are not expired.
+func (c *MCache[K, V]) GetAllWithExpiration() map[K]ValueTTL[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V])
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		if v.expireAt == 0 || v.expireAt >= now {
+			m[k] = ValueTTL[V]{Value: c.opts.copyValue(v.value), ExpireAt: expireTime(v.expireAt)}
 		}
 	}
 	return m
@@ -206,7 +675,49 @@ func (c *MCache[K, V]) GetAll() map[K]V {
 func (c *MCache[K, V]) Delete(k K) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.m, k)
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Delete", time.Now())
+	}
+	c.remove(k)
+}
+
+// ExtendMany adds extra to the expiration time of each of the given keys that is live and expires,
+// under a single lock, and returns the number of keys extended. Keys without expiration are left untouched,
+// and missing or expired keys are skipped.
+func (c *MCache[K, V]) ExtendMany(keys []K, extra time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	extended := 0
+	for _, k := range keys {
+		if v, ok := c.m[k]; ok && v.expireAt > 0 && v.expireAt >= now {
+			v.expireAt += int64(extra)
+			c.m[k] = v
+			extended++
+		}
+	}
+
+	return extended
+}
+
+// DeleteExpired removes the given keys if they are expired and returns the number of keys removed.
+// Only the supplied keys are checked, which is cheaper than a full sweep when the candidates are known.
+// Live and missing keys are left untouched.
+func (c *MCache[K, V]) DeleteExpired(keys []K) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	removed := 0
+	for _, k := range keys {
+		if v, ok := c.m[k]; ok && v.expireAt > 0 && v.expireAt < now {
+			c.remove(k)
+			removed++
+		}
+	}
+
+	return removed
 }
 
 // TransferTo transfers all non-expired key-value pairs from the source cache to the destination cache.
@@ -214,7 +725,7 @@ func (c *MCache[K, V]) Delete(k K) {
 func (src *MCache[K, V]) TransferTo(dst *MCache[K, V]) {
 	// Collect data with source lock
 	src.mu.Lock()
-	now := time.Now().UnixNano()
+	now := src.opts.expiryNow()
 	toTransfer := make(map[K]V)
 	var keysToDelete []K
 
@@ -242,7 +753,7 @@ func (src *MCache[K, V]) TransferTo(dst *MCache[K, V]) {
 func (src *MCache[K, V]) CopyTo(dst *MCache[K, V]) {
 	// Collect data with source lock
 	src.mu.Lock()
-	now := time.Now().UnixNano()
+	now := src.opts.expiryNow()
 	toCopy := make(map[K]V)
 
 	for k, v := range src.m {
@@ -265,10 +776,32 @@ func (c *MCache[K, V]) Keys() []K {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := time.Now().UnixNano()
+	now := c.opts.expiryNow()
 	keys := make([]K, 0, len(c.m))
 
 	for k, v := range c.m {
+		if v.expireAt == 0 || v.expireAt >= now {
+			keys = append(keys, k)
+		} else if c.opts.eagerScanCleanup {
+			c.remove(k)
+		}
+	}
+
+	return keys
+}
+
+// SomeKeys returns up to n live keys. MCache has no eviction order, so the keys are selected arbitrarily.
+// Unlike Keys, it stops as soon as n keys are found.
+func (c *MCache[K, V]) SomeKeys(n int) []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	keys := make([]K, 0, min(max(n, 0), len(c.m)))
+	for k, v := range c.m {
+		if len(keys) >= n {
+			break
+		}
 		if v.expireAt == 0 || v.expireAt >= now {
 			keys = append(keys, k)
 		}
@@ -281,62 +814,218 @@ func (c *MCache[K, V]) Keys() []K {
 // It runs until the Close method is called.
 // This function is not intended to be called directly by users.
 func (c *MCache[K, V]) expireKeys() {
-	ticker := time.NewTicker(c.timeInterval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			c.mu.Lock()
-			now := time.Now().UnixNano()
-			for k, v := range c.m {
-				if v.expireAt > 0 && v.expireAt < now {
-					delete(c.m, k)
-				}
-			}
-			c.mu.Unlock()
-		case <-c.stopCh:
-			return
+	c.sweeper.run(c.stopCh, c.sweep)
+}
+
+// Pause stops the background cleanup from removing expired entries until Resume is called, e.g. to inspect
+// expired entries that have not been removed yet. Expired entries are still treated as absent by reads,
+// which may remove them. It does nothing if the cache has no background cleanup.
+func (c *MCache[K, V]) Pause() {
+	c.sweeper.paused.Store(true)
+}
+
+// Resume resumes the background cleanup paused by Pause or WithStartPaused.
+// Expired entries are removed by the next scheduled sweep.
+func (c *MCache[K, V]) Resume() {
+	c.sweeper.paused.Store(false)
+}
+
+// sweep removes all expired keys and reports how many keys were scanned and removed.
+func (c *MCache[K, V]) sweep() (scanned, removed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		scanned++
+		if v.expireAt > 0 && v.expireAt < now {
+			c.remove(k)
+			removed++
 		}
 	}
+	return scanned, removed
 }
 
-// Purge removes all key-value pairs from the cache.
+// shed evicts the given fraction of the entries in response to memory pressure.
+func (c *MCache[K, V]) shed(fraction float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(int(math.Ceil(float64(len(c.m)) * fraction)))
+}
+
+// ExpiringWithin returns the live keys that expire within d from now, sorted soonest first.
+// Keys without an expiration time are not included.
+func (c *MCache[K, V]) ExpiringWithin(d time.Duration) []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from := c.opts.expiryNow()
+	until := from + int64(d)
+	var entries []keyExpiration[K]
+	for k, v := range c.m {
+		if v.expireAt >= from && v.expireAt <= until {
+			entries = append(entries, keyExpiration[K]{key: k, expireAt: v.expireAt})
+		}
+	}
+
+	return keysByExpiration(entries)
+}
+
+// TransformValues calls f for each non-expired entry under a single lock and replaces the value
+// of the entry with the returned value if f returns true. The expiration times are not changed.
+// f must not call methods of the cache.
+func (c *MCache[K, V]) TransformValues(f func(k K, v V) (V, bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for k, val := range c.m {
+		if val.expireAt > 0 && val.expireAt < now {
+			continue
+		}
+		if v, ok := f(k, val.value); ok {
+			val.value = v
+			c.m[k] = val
+		}
+	}
+}
+
+// Purge removes all key-value pairs from the cache. Values implementing Expirable are notified of their removal.
 // The cache can still be used after calling Purge.
 func (c *MCache[K, V]) Purge() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.notifyAll()
+	c.m = make(map[K]valueWithTimeout[V])
+}
+
+// Flush removes all key-value pairs from the cache and notifies each value implementing Expirable of its removal,
+// so that the resources they hold are released. It currently does the same as Purge, which notifies them too.
+// The cache can still be used after calling Flush.
+func (c *MCache[K, V]) Flush() {
+	c.Purge()
+}
+
+// Drain removes all key-value pairs from the cache and returns the ones that were not expired.
+// Unlike GetAll followed by Purge, it is performed under a single lock.
+func (c *MCache[K, V]) Drain() map[K]V {
+	return drainedValues(c.drainWithTTL())
+}
+
+// drainWithTTL removes all key-value pairs from the cache like Drain and returns the ones
+// that were not expired together with their remaining time to live.
+func (c *MCache[K, V]) drainWithTTL() map[K]drainedEntry[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]drainedEntry[V])
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		if ttl, ok := remainingTTL(v.expireAt, now); ok {
+			m[k] = drainedEntry[V]{value: v.value, ttl: ttl}
+		} else {
+			c.opts.notifyRemoved(v.value)
+		}
+	}
+
 	c.m = make(map[K]valueWithTimeout[V])
+	return m
 }
 
-// Close stops the background expiration goroutine and clears the cache.
-// After calling Close, the cache should not be used.
+// Compact rebuilds the internal map with only the non-expired key-value pairs.
+// Go maps do not shrink when keys are deleted, so calling Compact after a burst of deletions
+// releases the memory held by the grown map. Expired keys are removed in the process.
+func (c *MCache[K, V]) Compact() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	now := c.opts.expiryNow()
+	live := 0
+	for _, v := range c.m {
+		if v.expireAt == 0 || v.expireAt >= now {
+			live++
+		}
+	}
+
+	m := make(map[K]valueWithTimeout[V], live)
+	for k, v := range c.m {
+		if v.expireAt == 0 || v.expireAt >= now {
+			m[k] = v
+		}
+	}
+	c.m = m
+}
+
+// Close stops the background goroutines and clears the cache.
+// After calling Close, the cache should not be used: writes are ignored and reads find no keys.
+// Calling Close more than once has no effect.
 func (c *MCache[K, V]) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.mu.Unlock()
+
 	if c.timeInterval > 0 {
 		c.stopCh <- struct{}{} // Signal the expiration goroutine to stop
-		close(c.stopCh)
 	}
+	close(c.stopCh) // Stop any remaining background goroutines, such as the metrics reporter
 	c.mu.Lock()
+	c.notifyAll()
 	c.m = nil
 	c.mu.Unlock()
 }
 
 // Count returns the number of non-expired key-value pairs in the database.
+// With WithApproximateCount it returns the number of stored entries without checking them for expiration.
 func (c *MCache[K, V]) Count() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.opts.approxCount {
+		return len(c.m)
+	}
 	count := 0
-	now := time.Now().UnixNano()
-	for _, v := range c.m {
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
 		if v.expireAt == 0 || v.expireAt >= now {
 			count++
+		} else if c.opts.eagerScanCleanup {
+			c.remove(k)
 		}
 	}
 
 	return count
 }
 
+// TTLStats counts the entries without an expiration time, the live entries with one,
+// and the expired entries that have not been removed yet, in a single scan.
+func (c *MCache[K, V]) TTLStats() (permanent, expiring, expired int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for _, v := range c.m {
+		switch {
+		case v.expireAt == 0:
+			permanent++
+		case v.expireAt >= now:
+			expiring++
+		default:
+			expired++
+		}
+	}
+	return permanent, expiring, expired
+}
+
 // Len returns the total number of elements in the cache (including expired ones).
 func (c *MCache[K, V]) Len() int {
 	c.mu.Lock()
@@ -345,10 +1034,85 @@ func (c *MCache[K, V]) Len() int {
 	return len(c.m)
 }
 
+// Name returns the name of the cache set with WithName, or an empty string.
+func (c *MCache[K, V]) Name() string {
+	return c.opts.name
+}
+
+// Stats returns the usage statistics of the cache.
+func (c *MCache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Len = len(c.m)
+	return stats
+}
+
+// String returns a compact summary of the cache, e.g. incache.MCache[name=sessions size=1000 count=812].
+func (c *MCache[K, V]) String() string {
+	return fmt.Sprintf("incache.MCache[name=%s size=%s count=%d]", c.opts.name, sizeString(c.size), c.Count())
+}
+
+// set adds or updates a key in the cache.
+func (c *MCache[K, V]) set(k K, v valueWithTimeout[V]) {
+	// If key exists, just update
+	if _, ok := c.m[k]; ok {
+		c.store(k, v)
+		return
+	}
+
+	c.insert(k, v)
+}
+
+// insert adds a new key to the cache, evicting an item first if the cache is full.
+// It returns false if the cache has been closed or the key was rejected because the cache is full.
+func (c *MCache[K, V]) insert(k K, v valueWithTimeout[V]) bool {
+	if c.closed {
+		return false
+	}
+
+	before := len(c.m)
+	if uint(before) >= c.size {
+		if c.opts.rejectOnFull {
+			return false
+		}
+		c.evict(c.opts.evictionCount())
+	}
+
+	c.store(k, v)
+	c.opts.observeHighWater(before, len(c.m), c.size)
+	return true
+}
+
+// remove deletes the key from the cache and notifies its value of the removal.
+func (c *MCache[K, V]) remove(k K) {
+	if v, ok := c.m[k]; ok {
+		delete(c.m, k)
+		c.opts.notifyRemoved(v.value)
+	}
+}
+
+// notifyAll notifies the values of all items of their removal, before the cache is cleared.
+func (c *MCache[K, V]) notifyAll() {
+	for _, v := range c.m {
+		c.opts.notifyRemoved(v.value)
+	}
+}
+
+// store writes the value of the key, recording the access time if sampled LRU eviction is enabled.
+func (c *MCache[K, V]) store(k K, v valueWithTimeout[V]) {
+	if c.opts.sampleSize > 0 {
+		v.lastAccess = c.opts.now().UnixNano()
+	}
+	c.m[k] = v
+}
+
 // evict removes i items from the cache.
-// It first tries to evict expired items, then evicts any items if needed.
+// It first tries to evict expired items, then evicts any items if needed,
+// or the least recently accessed of sampled items if sampled LRU eviction is enabled.
 func (c *MCache[K, V]) evict(i int) {
-	now := time.Now().UnixNano()
+	now := c.opts.expiryNow()
 	counter := 0
 
 	// First pass: evict expired items
@@ -357,20 +1121,49 @@ func (c *MCache[K, V]) evict(i int) {
 			return
 		}
 		if v.expireAt > 0 && v.expireAt < now {
-			delete(c.m, k)
+			c.remove(k)
+			c.lastEvicted = k
+			c.stats.Evictions++
 			counter++
 		}
 	}
 
 	// Second pass: evict any items if we still need to evict more
+	if counter < i && c.opts.sampleSize > 0 {
+		c.evictSampled(i - counter)
+		return
+	}
 	if counter < i {
 		remaining := min(i-counter, len(c.m))
 		for k := range c.m {
 			if remaining <= 0 {
 				break
 			}
-			delete(c.m, k)
+			c.remove(k)
+			c.lastEvicted = k
+			c.stats.Evictions++
 			remaining--
 		}
 	}
 }
+
+// evictSampled removes n items, each being the least recently accessed of sampleSize sampled items.
+// Map iteration starts at a random position, which provides the sampling.
+func (c *MCache[K, V]) evictSampled(n int) {
+	for ; n > 0 && len(c.m) > 0; n-- {
+		var victim K
+		oldest := int64(math.MaxInt64)
+		sampled := 0
+		for k, v := range c.m {
+			if v.lastAccess < oldest {
+				victim, oldest = k, v.lastAccess
+			}
+			if sampled++; sampled >= c.opts.sampleSize {
+				break
+			}
+		}
+		c.remove(victim)
+		c.lastEvicted = victim
+		c.stats.Evictions++
+	}
+}
@@ -1,6 +1,8 @@
 package incache
 
 import (
+	"reflect"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -87,6 +89,32 @@ func TestNotFoundSetWithTimeout(t *testing.T) {
 	}
 }
 
+func TestNotFoundSetMany(t *testing.T) {
+	c := NewManual[string, string](10, 0)
+	c.Set("present", "old")
+	c.SetWithTimeout("expired", "old", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	added := c.NotFoundSetMany(map[string]string{
+		"present": "new",
+		"expired": "new",
+		"absent":  "new",
+	})
+	slices.Sort(added)
+
+	if !slices.Equal(added, []string{"absent", "expired"}) {
+		t.Errorf("Expected only absent and expired keys to be added, got %v", added)
+	}
+	if v, _ := c.Get("present"); v != "old" {
+		t.Errorf("Expected present key to keep its value, got %v", v)
+	}
+	for _, k := range added {
+		if v, ok := c.Get(k); !ok || v != "new" {
+			t.Errorf("Expected %s to be stored, got %v", k, v)
+		}
+	}
+}
+
 func TestSetWithTimeout(t *testing.T) {
 	c := NewManual[string, string](10, 0)
 	key := "test"
@@ -415,3 +443,279 @@ func TestUpdateExisting(t *testing.T) {
 		t.Errorf("Expected Len=1 after update, got %d", c.Len())
 	}
 }
+
+func TestReplaceIfPresent(t *testing.T) {
+	c := NewManual[string, string](10, 0)
+
+	if c.ReplaceIfPresent("key1", "value1") {
+		t.Errorf("ReplaceIfPresent should return false for a missing key")
+	}
+	if _, ok := c.Get("key1"); ok {
+		t.Errorf("ReplaceIfPresent should not create a new key")
+	}
+
+	c.SetWithTimeout("key1", "value1", time.Second)
+	if !c.ReplaceIfPresent("key1", "value2") {
+		t.Errorf("ReplaceIfPresent should return true for an existing key")
+	}
+	if v, ok := c.Get("key1"); !ok || v != "value2" {
+		t.Errorf("Expected value2, got %v", v)
+	}
+	if c.m["key1"].expireAt == 0 {
+		t.Errorf("ReplaceIfPresent should preserve the expiration time")
+	}
+
+	c.SetWithTimeout("key2", "value1", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if c.ReplaceIfPresent("key2", "value2") {
+		t.Errorf("ReplaceIfPresent should return false for an expired key")
+	}
+}
+
+func TestSetWithTimeoutIfSooner(t *testing.T) {
+	c := NewManual[string, string](10, 0)
+
+	c.SetWithTimeoutIfSooner("key1", "value1", time.Hour)
+	if v, ok := c.Get("key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	c.SetWithTimeoutIfSooner("key1", "value2", 2*time.Hour)
+	if v, _ := c.Get("key1"); v != "value1" {
+		t.Errorf("Expected later expiration to be ignored, got %v", v)
+	}
+
+	c.SetWithTimeoutIfSooner("key1", "value3", time.Minute)
+	if v, _ := c.Get("key1"); v != "value3" {
+		t.Errorf("Expected sooner expiration to win, got %v", v)
+	}
+
+	// A key without expiration is replaced by any timeout
+	c.Set("key2", "value1")
+	c.SetWithTimeoutIfSooner("key2", "value2", time.Hour)
+	if v, _ := c.Get("key2"); v != "value2" {
+		t.Errorf("Expected timeout to win over no expiration, got %v", v)
+	}
+}
+
+func TestSetWithTimeoutIfLater(t *testing.T) {
+	c := NewManual[string, string](10, 0)
+
+	c.SetWithTimeoutIfLater("key1", "value1", time.Minute)
+	if v, ok := c.Get("key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1, got %v", v)
+	}
+
+	c.SetWithTimeoutIfLater("key1", "value2", time.Hour)
+	if v, _ := c.Get("key1"); v != "value2" {
+		t.Errorf("Expected later expiration to win, got %v", v)
+	}
+
+	c.SetWithTimeoutIfLater("key1", "value3", time.Minute)
+	if v, _ := c.Get("key1"); v != "value2" {
+		t.Errorf("Expected sooner expiration to be ignored, got %v", v)
+	}
+
+	// No expiration is later than any timeout
+	c.SetWithTimeoutIfLater("key1", "value4", 0)
+	if v, _ := c.Get("key1"); v != "value4" || c.m["key1"].expireAt != 0 {
+		t.Errorf("Expected no expiration to win, got %v", v)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	c := NewManual[string, string](10, 0)
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.SetWithTimeout("key3", "value3", time.Microsecond)
+	time.Sleep(time.Millisecond)
+
+	m := c.Drain()
+	if len(m) != 2 || m["key1"] != "value1" || m["key2"] != "value2" {
+		t.Errorf("Drain returned unexpected entries: %v", m)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Expected cache to be empty after Drain, got Len=%d", c.Len())
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	clock := NewMockClock()
+	c := NewManual(10, time.Millisecond, WithClock[string, string](clock))
+	defer c.Close()
+
+	c.SetWithTimeout("key1", "value1", time.Minute)
+	c.Set("key2", "value2")
+
+	time.Sleep(5 * time.Millisecond)
+	if c.Len() != 2 {
+		t.Errorf("Expected sweeper to keep live keys, got Len=%d", c.Len())
+	}
+
+	clock.Advance(2 * time.Minute)
+	if c.Count() != 1 {
+		t.Errorf("Expected Count=1 after advancing the clock, got %d", c.Count())
+	}
+
+	// The background sweeper consults the clock as well
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected sweeper to remove the expired key, got Len=%d", c.Len())
+	}
+}
+
+func TestLoadOrStore(t *testing.T) {
+	c := NewManual[string, string](10, 0)
+
+	if v, loaded := c.LoadOrStore("key1", "value1"); loaded || v != "value1" {
+		t.Errorf("Expected value1 to be stored, got %v, %v", v, loaded)
+	}
+	if v, loaded := c.LoadOrStore("key1", "other"); !loaded || v != "value1" {
+		t.Errorf("Expected value1 to be loaded, got %v, %v", v, loaded)
+	}
+
+	c.SetWithTimeout("key2", "value2", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if v, loaded := c.LoadOrStore("key2", "new"); loaded || v != "new" {
+		t.Errorf("Expected expired key to be stored, got %v, %v", v, loaded)
+	}
+	if v, _ := c.Get("key2"); v != "new" {
+		t.Errorf("Expected new, got %v", v)
+	}
+}
+
+func TestLoadOrStore_NotStored(t *testing.T) {
+	c := NewManual(1, 0, WithRejectOnFull[string, int]())
+	c.Set("x", 1)
+
+	if v, loaded := c.LoadOrStore("y", 2); loaded || v != 0 {
+		t.Errorf("Expected the zero value for a rejected key, got %v, %v", v, loaded)
+	}
+	if _, ok := c.Get("y"); ok {
+		t.Errorf("Expected the rejected key not to be stored")
+	}
+
+	empty := NewManual[string, int](0, 0)
+	if v, loaded := empty.LoadOrStore("y", 2); loaded || v != 0 {
+		t.Errorf("Expected the zero value from a cache of size 0, got %v, %v", v, loaded)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	c := NewManual[int, int](10000, 0)
+
+	for i := 0; i < 10000; i++ {
+		c.Set(i, i)
+	}
+	for i := 0; i < 9990; i++ {
+		c.Delete(i)
+	}
+	c.SetWithTimeout(-1, -1, time.Microsecond)
+	time.Sleep(time.Millisecond)
+
+	before := reflect.ValueOf(c.m).UnsafePointer()
+	c.Compact()
+
+	if reflect.ValueOf(c.m).UnsafePointer() == before {
+		t.Errorf("Expected Compact to replace the grown map with a new one")
+	}
+	if _, ok := c.m[-1]; ok {
+		t.Errorf("Expected Compact to remove the expired key from the map")
+	}
+	if len(c.m) != 10 {
+		t.Errorf("Expected Compact to keep the 10 live keys and drop the expired one, got %d keys", len(c.m))
+	}
+	for i := 9990; i < 10000; i++ {
+		if v, ok := c.Get(i); !ok || v != i {
+			t.Errorf("Expected key %d to survive Compact, got %v", i, v)
+		}
+	}
+
+	// The cache stays usable after Compact
+	c.Set(1, 1)
+	if v, ok := c.Get(1); !ok || v != 1 {
+		t.Errorf("Expected to use cache after Compact")
+	}
+}
+
+func TestWithSampledLRU(t *testing.T) {
+	clock := NewMockClock()
+	c := NewManual(100, 0, WithSampledLRU[int, int](10), WithClock[int, int](clock))
+
+	// Keys 0-9 are read continuously while new keys churn through the cache
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	for i := 100; i < 2000; i++ {
+		clock.Advance(time.Millisecond)
+		for k := 0; k < 10; k++ {
+			c.Get(k)
+		}
+		c.Set(i, i)
+	}
+
+	for k := 0; k < 10; k++ {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("Expected recently accessed key %d to survive the churn", k)
+		}
+	}
+	idle := 0
+	for k := 10; k < 100; k++ {
+		if _, ok := c.Get(k); ok {
+			idle++
+		}
+	}
+	if idle > 0 {
+		t.Errorf("Expected idle keys to be evicted, %d of 90 survived", idle)
+	}
+	if c.Len() != 100 {
+		t.Errorf("Expected the cache to stay full, got Len=%d", c.Len())
+	}
+}
+
+func TestTransformValues(t *testing.T) {
+	c := NewManual[string, int](10, 0)
+	c.Set("a", 1)
+	c.SetWithTimeout("b", 2, time.Minute)
+
+	c.TransformValues(func(k string, v int) (int, bool) {
+		return v * 2, true
+	})
+
+	if v, _ := c.Get("a"); v != 2 {
+		t.Errorf("Expected a=2, got %v", v)
+	}
+	if e, _ := c.GetWithExpiration("b"); e.Value != 4 || e.ExpireAt.IsZero() {
+		t.Errorf("Expected b=4 with its expiration kept, got %+v", e)
+	}
+}
+
+func TestSetReport(t *testing.T) {
+	clock := NewMockClock()
+	c := NewManual[string, int](2, 0, WithClock[string, int](clock))
+	c.Set("a", 1)
+	c.SetWithTimeout("b", 2, time.Second)
+	clock.Advance(2 * time.Second)
+
+	evicted, key := c.SetReport("c", 3)
+	if !evicted || key != "b" {
+		t.Errorf("Expected the expired 'b' to be evicted first, got (%v, %q)", evicted, key)
+	}
+
+	evicted, key = c.SetWithTimeoutReport("d", 4, time.Minute)
+	if !evicted || (key != "a" && key != "c") {
+		t.Errorf("Expected 'a' or 'c' to be evicted, got (%v, %q)", evicted, key)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Errorf("Expected the reported key %q to be gone", key)
+	}
+
+	if evicted, _ := c.SetReport("d", 40); evicted {
+		t.Errorf("Expected no eviction when updating an existing key")
+	}
+}
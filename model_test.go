@@ -0,0 +1,145 @@
+package incache
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// cacheModel is a reference implementation of a cache policy that the real cache is checked against.
+type cacheModel interface {
+	SetWithTimeout(k, v int, ttl time.Duration)
+	Get(k int) (int, bool)
+	Delete(k int)
+	Len() int
+}
+
+// referenceEntry is an entry of the reference model.
+type referenceEntry struct {
+	value    int
+	expireAt time.Time // zero means no expiration
+}
+
+// referenceLRU is a deliberately simple model of LRUCache used as a test oracle.
+// It keeps the keys in a slice ordered from the most to the least recently used,
+// trading performance for being obviously correct.
+//
+// To extend the harness to another policy, write a model with the same methods whose
+// eviction picks the victim the way the policy does (e.g. for LFU: the lowest frequency,
+// and the least recently used among equal frequencies) so that it implements cacheModel,
+// then run it through checkModel next to the real cache and its validate method.
+type referenceLRU struct {
+	size    int
+	clock   Clock
+	order   []int // most recently used first
+	entries map[int]referenceEntry
+}
+
+func newReferenceLRU(size int, clock Clock) *referenceLRU {
+	return &referenceLRU{size: size, clock: clock, entries: make(map[int]referenceEntry)}
+}
+
+func (r *referenceLRU) expired(e referenceEntry) bool {
+	return !e.expireAt.IsZero() && e.expireAt.Before(r.clock.Now())
+}
+
+func (r *referenceLRU) touch(k int) {
+	i := slices.Index(r.order, k)
+	r.order = slices.Insert(slices.Delete(r.order, i, i+1), 0, k)
+}
+
+func (r *referenceLRU) remove(k int) {
+	if i := slices.Index(r.order, k); i >= 0 {
+		r.order = slices.Delete(r.order, i, i+1)
+	}
+	delete(r.entries, k)
+}
+
+func (r *referenceLRU) SetWithTimeout(k, v int, ttl time.Duration) {
+	e := referenceEntry{value: v}
+	if ttl > 0 {
+		e.expireAt = r.clock.Now().Add(ttl)
+	}
+
+	if _, ok := r.entries[k]; ok {
+		r.entries[k] = e
+		r.touch(k)
+		return
+	}
+
+	if len(r.order) >= r.size {
+		r.remove(r.order[len(r.order)-1])
+	}
+	r.entries[k] = e
+	r.order = slices.Insert(r.order, 0, k)
+}
+
+func (r *referenceLRU) Get(k int) (int, bool) {
+	e, ok := r.entries[k]
+	if !ok {
+		return 0, false
+	}
+	if r.expired(e) {
+		r.remove(k)
+		return 0, false
+	}
+	r.touch(k)
+	return e.value, true
+}
+
+func (r *referenceLRU) Delete(k int) {
+	r.remove(k)
+}
+
+func (r *referenceLRU) Len() int {
+	return len(r.entries)
+}
+
+// checkModel applies a random sequence of operations on keys in [0, keys) to both the cache and the model,
+// failing the test as soon as a Get or Len disagrees or validate reports a broken invariant.
+func checkModel(t *testing.T, seed uint64, keys int, c Cache[int, int], model cacheModel, clock *MockClock, validate func() error) {
+	t.Helper()
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	for i := 0; i < 20000; i++ {
+		k, v := r.IntN(keys), r.IntN(1000)
+		switch op := r.IntN(10); {
+		case op < 3:
+			c.Set(k, v)
+			model.SetWithTimeout(k, v, 0)
+		case op < 5:
+			ttl := time.Duration(r.IntN(4)) * time.Second
+			c.SetWithTimeout(k, v, ttl)
+			model.SetWithTimeout(k, v, ttl)
+		case op < 8:
+			got, gotOK := c.Get(k)
+			want, wantOK := model.Get(k)
+			if got != want || gotOK != wantOK {
+				t.Fatalf("seed %d, op %d: Get(%d) = (%v, %v), model says (%v, %v)", seed, i, k, got, gotOK, want, wantOK)
+			}
+		case op < 9:
+			c.Delete(k)
+			model.Delete(k)
+		default:
+			clock.Advance(time.Second)
+		}
+
+		if c.Len() != model.Len() {
+			t.Fatalf("seed %d, op %d: Len = %d, model says %d", seed, i, c.Len(), model.Len())
+		}
+		if validate != nil {
+			if err := validate(); err != nil {
+				t.Fatalf("seed %d, op %d: %v", seed, i, err)
+			}
+		}
+	}
+}
+
+func TestModel_LRU(t *testing.T) {
+	for seed := uint64(1); seed <= 5; seed++ {
+		clock := NewMockClock()
+		c := NewLRU(8, WithClock[int, int](clock))
+		checkModel(t, seed, 24, c, newReferenceLRU(8, clock), clock, c.validate)
+	}
+}
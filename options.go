@@ -0,0 +1,772 @@
+package incache
+
+import (
+	"math"
+	"time"
+	"unsafe"
+)
+
+// Option configures optional behavior of a cache.
+// Options are passed to the cache constructors, e.g. NewLRU[string, int](100, WithHighWaterMark[string, int](0.9, cb)).
+type Option[K comparable, V any] func(*options[K, V])
+
+type options[K comparable, V any] struct {
+	name              string
+	clock             Clock
+	cleanupInterval   time.Duration
+	cleanupMin        time.Duration
+	cleanupMax        time.Duration
+	cleanupJitter     float64
+	startPaused       bool
+	maxKeys           uint
+	maxCost           int64
+	costFunc          func(V) int64
+	autoCost          bool
+	maxValueSize      int64
+	sizer             func(V) int64
+	valueCopier       func(V) V
+	valueEquals       func(a, b V) bool
+	initialCapacity   int
+	evictionBatch     int
+	watermarkHigh     float64
+	watermarkLow      float64
+	rejectOnFull      bool
+	tombstoneWrites   bool
+	retainExpired     bool
+	eagerScanCleanup  bool
+	expiryGrace       time.Duration
+	minTTL            time.Duration
+	rejectShortTTL    bool
+	notFoundRefresh   bool
+	trackAge          bool
+	trackDirty        bool
+	evictionHistory   int
+	readBuffer        int
+	classes           []classConfig
+	approxCount       bool
+	unbounded         bool
+	strictSizing      bool
+	protectedRatio    float64
+	sampleSize        int
+	tieBreak          TieBreak
+	promoteAfter      uint
+	maxFreq           uint
+	hasher            func(K) uint64
+	primaryOnlyWrites bool
+	highWaterRatio    float64
+	highWaterCallback func(count, capacity uint)
+	operationHook     func(op string, d time.Duration)
+	missHook          func(k K)
+	reportInterval    time.Duration
+	reporter          func(Stats)
+	pressureCheck     func() bool
+	pressureFraction  float64
+	pressureInterval  time.Duration
+	flush             func(k K, v V) error
+	flushBatch        int
+	flushInterval     time.Duration
+	flushErrorHook    func(k K, v V, err error)
+	flushAttempts     int
+	flushRetryDelay   time.Duration
+	events            chan<- Event[K, V]
+	safeCallbacks     bool
+	panicHandler      func(recovered any)
+}
+
+func applyOptions[K comparable, V any](opts []Option[K, V]) options[K, V] {
+	o := options[K, V]{
+		clock: realClock{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithName sets the name of the cache, which is returned by Name and included in String.
+// It helps to tell caches apart in logs and metrics when an application runs several of them.
+func WithName[K comparable, V any](name string) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.name = name
+	}
+}
+
+// WithClock sets the clock used by the cache to determine the current time.
+// It is mainly useful for testing expiration without sleeping, see NewMockClock.
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.clock = clock
+	}
+}
+
+// WithHighWaterMark registers a callback that is invoked when the number of entries in the cache
+// crosses ratio*size from below. The callback fires once per crossing: it is not called again
+// until the number of entries has dropped below the mark and crosses it again.
+// The callback is invoked while the cache lock is held and must not call methods of the cache.
+func WithHighWaterMark[K comparable, V any](ratio float64, cb func(count, capacity uint)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.highWaterRatio = ratio
+		o.highWaterCallback = cb
+	}
+}
+
+// observeHighWater invokes the high water mark callback if the number of entries
+// went from below the mark (before) to at or above it (after).
+func (o *options[K, V]) observeHighWater(before, after int, capacity uint) {
+	if o.highWaterCallback == nil {
+		return
+	}
+	defer o.recoverCallback()
+	mark := o.highWaterRatio * float64(capacity)
+	if float64(before) < mark && float64(after) >= mark {
+		o.highWaterCallback(uint(after), capacity)
+	}
+}
+
+// WithCleanupInterval starts a background goroutine that removes expired entries every interval.
+// For MCache, the interval passed to NewManual takes precedence if it is positive.
+// Call Close to stop the goroutine.
+func WithCleanupInterval[K comparable, V any](interval time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.cleanupInterval = interval
+	}
+}
+
+// WithAdaptiveCleanup starts a background goroutine that removes expired entries
+// with an interval that adapts to the workload: it is shortened (down to minInterval)
+// when a sweep reclaims many entries and lengthened (up to maxInterval) when it reclaims few.
+// The sweeper starts at maxInterval, or at the configured cleanup interval clamped to the bounds.
+// Call Close to stop the goroutine.
+func WithAdaptiveCleanup[K comparable, V any](minInterval, maxInterval time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.cleanupMin = minInterval
+		o.cleanupMax = maxInterval
+	}
+}
+
+// WithCleanupJitter randomizes the wait before each background cleanup, including the first one,
+// by up to fraction of the cleanup interval in either direction, e.g. 0.1 for ±10%.
+// It keeps the sweepers of caches created at the same time from running in lockstep.
+// fraction is capped at 1.
+func WithCleanupJitter[K comparable, V any](fraction float64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.cleanupJitter = fraction
+	}
+}
+
+// WithStartPaused creates the cache with its background cleanup paused, until Resume is called.
+// Expired entries are still treated as absent by reads.
+func WithStartPaused[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.startPaused = true
+	}
+}
+
+// WithMaxCost limits the total cost of the entries in the cache to maxCost,
+// where the cost of each entry is computed by cost when it is set.
+// Least recently used entries are evicted until the budget is satisfied.
+// It is currently supported by LRUCache only.
+func WithMaxCost[K comparable, V any](maxCost int64, cost func(V) int64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.maxCost = maxCost
+		o.costFunc = cost
+	}
+}
+
+// WithAutoCost estimates the cost of each entry as its approximate size in bytes, so that WithMaxCost
+// bounds the memory of the cache without a cost function, e.g. WithMaxCost(64<<20, nil) with WithAutoCost.
+// The estimate is the size of the key and value types as reported by unsafe.Sizeof, plus the length
+// of string keys and values. Memory referenced through pointers, slices, maps or fields of structs is not
+// counted, so the estimate is only accurate for flat types and strings. It takes precedence over the cost
+// function of WithMaxCost. It only applies to LRUCache.
+func WithAutoCost[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.autoCost = true
+	}
+}
+
+// WithMaxKeys limits the number of entries in the cache to n, independently of the cost budget.
+// Eviction is triggered when either limit is exceeded and continues until both are satisfied.
+// It is currently supported by LRUCache only.
+func WithMaxKeys[K comparable, V any](n uint) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.maxKeys = n
+	}
+}
+
+// WithMaxValueSize makes the cache reject values whose size, as measured by sizer, exceeds maxSize bytes.
+// Rejected values are not stored and leave the cache unchanged; TrySet reports them with ErrValueTooLarge.
+// It is currently supported by LRUCache only.
+func WithMaxValueSize[K comparable, V any](maxSize int64, sizer func(V) int64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.maxValueSize = maxSize
+		o.sizer = sizer
+	}
+}
+
+// WithValueCopier makes Get and GetAll return copier(value) instead of the stored value.
+// This protects cached slices, maps or pointers from being mutated by callers.
+// The caches have no Peek method, so there is no Peek path for the copier to cover.
+func WithValueCopier[K comparable, V any](copier func(V) V) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.valueCopier = copier
+	}
+}
+
+// WithValueEquals makes a Set of a key whose current value is equal to the new one, as reported by equals,
+// skip the write: the entry is not moved in the recency order and its version is unchanged.
+// Only the expiration is updated. This reduces the work done by idempotent refresh loops.
+// It is currently supported by LRUCache only.
+func WithValueEquals[K comparable, V any](equals func(a, b V) bool) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.valueEquals = equals
+	}
+}
+
+// WithInitialCapacity presizes the internal map of the cache to hold n entries,
+// avoiding rehashing while a large cache is being filled. A negative n is treated as 0.
+func WithInitialCapacity[K comparable, V any](n int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.initialCapacity = max(n, 0)
+	}
+}
+
+// withSeedCapacity prepends an initial capacity for n seed entries, capped at size, to opts
+// so that an explicit WithInitialCapacity still takes precedence.
+func withSeedCapacity[K comparable, V any](opts []Option[K, V], n int, size uint) []Option[K, V] {
+	if uint(n) > size {
+		n = int(size)
+	}
+	return append([]Option[K, V]{WithInitialCapacity[K, V](n)}, opts...)
+}
+
+// WithEvictionBatch makes the cache evict n entries at once when a new key is added to a full cache,
+// instead of a single entry. This amortizes the eviction bookkeeping over several inserts
+// at the cost of temporarily holding fewer entries. The newly added key is never evicted.
+func WithEvictionBatch[K comparable, V any](n int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.evictionBatch = n
+	}
+}
+
+// WithWatermarks makes the cache evict in batches: once it holds high*size entries, adding a new key evicts
+// the least recently used entries down to low*size, and the following keys are added without evicting until
+// the cache reaches high*size again. This amortizes eviction over many inserts at the cost of holding fewer
+// entries on average. high is capped at 1 and low at just below high. It replaces WithEvictionBatch, and with
+// WithRejectOnFull new keys are rejected from high*size entries on. It only applies to LRUCache.
+func WithWatermarks[K comparable, V any](high, low float64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.watermarkHigh = high
+		o.watermarkLow = low
+	}
+}
+
+// WithMinTTL raises every positive TTL shorter than d to d, guarding against accidentally tiny TTLs
+// that make entries expire right away and churn the cache. Zero or negative TTLs, which mean no expiration,
+// are unaffected. With WithRejectShortTTL, such writes are dropped instead.
+// It applies to LRUCache, LFUCache and MCache.
+func WithMinTTL[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.minTTL = d
+	}
+}
+
+// WithRejectShortTTL makes the cache drop writes with a positive TTL below the minimum set with WithMinTTL
+// instead of raising the TTL to the minimum.
+func WithRejectShortTTL[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.rejectShortTTL = true
+	}
+}
+
+// WithClass configures a class of entries for LRUCache.SetClass, with its own capacity and default TTL.
+// Once the class holds more than capacity entries, its least recently used entries are evicted, leaving the
+// entries of other classes alone. A capacity of 0 only limits the class by the size of the cache,
+// and a ttl of 0 means the entries of the class do not expire. It only applies to LRUCache.
+func WithClass[K comparable, V any](name string, capacity uint, ttl time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.classes = append(o.classes, classConfig{name: name, capacity: capacity, ttl: ttl})
+	}
+}
+
+// WithReadBuffer makes LRUCache.Get look up keys under a shared read lock, so that concurrent Gets do not
+// contend on the cache lock. Instead of moving each hit to the front of the eviction list, Get records it
+// in a buffer of up to size hits, which is applied by the next write, eviction or cleanup.
+// Hits are dropped while the buffer is full, so eviction only approximately respects recency.
+// Other lookups, such as GetWithExpiration, and Gets of expired keys still take the exclusive lock.
+// It only applies to LRUCache.
+func WithReadBuffer[K comparable, V any](size int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.readBuffer = size
+	}
+}
+
+// WithAgeTracking records when each entry was inserted, so that AgeRange can report the insertion times
+// of the oldest and newest entries without scanning the cache. Updating an existing key keeps its insertion time.
+// It only applies to LRUCache.
+func WithAgeTracking[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.trackAge = true
+	}
+}
+
+// WithDirtyTracking records the keys whose values are written or removed, so that DirtyKeys can report
+// the keys changed since its last call, e.g. to persist a cache incrementally. It only applies to LRUCache.
+func WithDirtyTracking[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.trackDirty = true
+	}
+}
+
+// WithEvictionHistory keeps the keys of the last n evicted entries, without their values, so that
+// RecentlyEvicted can report them, e.g. to tell whether the working set fits into the cache.
+// It only applies to LRUCache.
+func WithEvictionHistory[K comparable, V any](n int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.evictionHistory = n
+	}
+}
+
+// WithExpiryGrace makes entries expire grace before their nominal expiration time, e.g. to tolerate
+// clock drift between nodes that share expiration times. It applies to every liveness check,
+// including reads, Count, Keys and the background cleanup.
+func WithExpiryGrace[K comparable, V any](grace time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.expiryGrace = grace
+	}
+}
+
+// WithRetainExpired separates the logical expiration of entries from their removal: reads treat expired entries
+// as absent but leave them in the cache, where GetExpired can still return them, until PurgeExpired or DeleteExpired
+// removes them, they are evicted or their key is written again. The background cleanup does not remove them either.
+// It only applies to LRUCache.
+func WithRetainExpired[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.retainExpired = true
+	}
+}
+
+// WithEagerScanCleanup makes Keys, GetAll, Count and Range remove the expired entries they come across,
+// instead of only skipping them and leaving them to the background cleanup.
+// It has no effect on the entries of an LRUCache created with WithRetainExpired.
+func WithEagerScanCleanup[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.eagerScanCleanup = true
+	}
+}
+
+// WithNotFoundSetRefreshesTTL makes NotFoundSet and NotFoundSetWithTimeout keep a live entry with an expiration time
+// alive: instead of doing nothing, they extend its expiration to the given TTL from now, or to the TTL of its class
+// for NotFoundSet, without overwriting its value. They still return false. Entries without an expiration are left
+// unchanged. It only applies to LRUCache.
+func WithNotFoundSetRefreshesTTL[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.notFoundRefresh = true
+	}
+}
+
+// WithTombstoneOverwrite makes writes of a key deleted with DeleteWithTombstone succeed and clear its tombstone,
+// instead of being rejected until the tombstone expires. IsTombstoned still reports the tombstone until then.
+// It only applies to LRUCache.
+func WithTombstoneOverwrite[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.tombstoneWrites = true
+	}
+}
+
+// WithApproximateCount makes Count return the running number of stored entries in O(1) instead of
+// scanning every entry for expiration. Expired entries are only subtracted once they are removed,
+// by Get, DeleteExpired or the background cleanup, so Count may include entries that have expired recently.
+// Combine it with WithCleanupInterval to bound how stale the count gets. Len is not affected.
+func WithApproximateCount[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.approxCount = true
+	}
+}
+
+// WithStrictSizing makes the constructor panic if the size is 0 and WithUnbounded is not used,
+// instead of creating a cache that silently drops every write. A size of 0 usually is a configuration mistake.
+func WithStrictSizing[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.strictSizing = true
+	}
+}
+
+// WithRejectOnFull makes the cache drop new keys instead of evicting existing entries when it is at capacity.
+// Updates of existing keys still succeed, and NotFoundSet reports false for rejected keys.
+// Expired entries that have not been removed yet count towards the capacity.
+func WithRejectOnFull[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.rejectOnFull = true
+	}
+}
+
+// WithSampledLRU makes MCache evict approximately least recently used entries instead of arbitrary ones.
+// The cache records the last access time of each entry, and to evict an entry it samples sampleSize
+// entries at random and evicts the least recently accessed of them.
+// It only applies to MCache.
+func WithSampledLRU[K comparable, V any](sampleSize int) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.sampleSize = sampleSize
+	}
+}
+
+// TieBreak selects which of several LFU entries with the same frequency is evicted first.
+type TieBreak int
+
+const (
+	// LRUWithinBucket evicts the least recently used of the entries with the lowest frequency.
+	LRUWithinBucket TieBreak = iota
+	// FIFOWithinBucket evicts the entry inserted first among the entries with the lowest frequency,
+	// regardless of when they were last used.
+	FIFOWithinBucket
+)
+
+// WithLFUTieBreak selects how LFUCache breaks ties between entries with the lowest frequency.
+// The default is LRUWithinBucket. FIFOWithinBucket keeps the entries of each frequency ordered by insertion
+// in a heap, so its evictions and frequency changes take O(log n) instead of O(1).
+// It only applies to LFUCache.
+func WithLFUTieBreak[K comparable, V any](mode TieBreak) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.tieBreak = mode
+	}
+}
+
+// WithLFUPromotionThreshold makes new LFUCache entries stay at the lowest frequency until they have been
+// accessed n times after insertion, so an entry read a few times is not protected over one read once
+// until it has proven itself. Entries below the threshold are evicted before any entry that crossed it,
+// and among themselves according to WithLFUTieBreak. A threshold of 0 or 1 counts every access.
+// It only applies to LFUCache.
+func WithLFUPromotionThreshold[K comparable, V any](n uint) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.promoteAfter = n
+	}
+}
+
+// WithMaxFrequency caps the access frequency of LFUCache entries at max, so that all entries accessed
+// at least max times share the top frequency bucket and are evicted among themselves by recency.
+// This bounds the number of frequency buckets at the cost of some eviction precision.
+// A max of 0 means no cap. It only applies to LFUCache.
+func WithMaxFrequency[K comparable, V any](max uint) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.maxFreq = max
+	}
+}
+
+// WithHasher sets the hash function used by ShardedCache to assign keys to shards.
+// By default keys are hashed with hash/maphash, which supports every comparable type
+// but can be slow or poorly distributed for some key types, e.g. large struct keys.
+// It only applies to ShardedCache.
+func WithHasher[K comparable, V any](hasher func(K) uint64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.hasher = hasher
+	}
+}
+
+// WithPrimaryOnlyWrites makes TieredCache write only to its primary cache instead of both tiers.
+// The secondary cache is then only read, e.g. because it is filled by other means,
+// and values evicted from the primary cache are lost unless the secondary cache already holds them.
+// It only applies to TieredCache.
+func WithPrimaryOnlyWrites[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.primaryOnlyWrites = true
+	}
+}
+
+// WithUnbounded removes the size limit of the cache: the size passed to the constructor is ignored,
+// nothing is ever evicted and entries are only removed when they expire or are deleted.
+// This turns the cache into a pure TTL map; combine it with a cleanup interval to reclaim expired entries.
+func WithUnbounded[K comparable, V any]() Option[K, V] {
+	return func(o *options[K, V]) {
+		o.unbounded = true
+	}
+}
+
+// WithProtectedRatio sets the fraction of an SLRU cache reserved for the protected segment.
+// The ratio must be in (0, 1], otherwise the default of 0.8 is used.
+// It only applies to SLRUCache.
+func WithProtectedRatio[K comparable, V any](ratio float64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.protectedRatio = ratio
+	}
+}
+
+// WithOperationHook registers a hook that is invoked after each Get, Set, SetWithTimeout and Delete
+// with the name of the operation and the wall-clock time it spent holding the cache lock.
+// The hook is invoked while the cache lock is held and must not call methods of the cache.
+// Without a hook, the operations are not timed.
+func WithOperationHook[K comparable, V any](hook func(op string, d time.Duration)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.operationHook = hook
+	}
+}
+
+// WithMissHook registers a hook that is invoked with the key whenever Get does not find a live value,
+// because the key is absent or expired. The hook is invoked after the cache lock is released,
+// so it may call methods of the cache. Without a hook, misses cost nothing extra.
+func WithMissHook[K comparable, V any](hook func(k K)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.missHook = hook
+	}
+}
+
+// WithMetricsReporter starts a background goroutine that calls report with the current Stats of the cache
+// every interval. Call Close to stop the goroutine.
+func WithMetricsReporter[K comparable, V any](interval time.Duration, report func(Stats)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.reportInterval = interval
+		o.reporter = report
+	}
+}
+
+// WithSafeCallbacks recovers panics of the callbacks configured with options, such as the high water mark
+// callback, the operation and miss hooks, the metrics reporter and the memory pressure check, and of the OnRemoved
+// method of Expirable values, so that a faulty callback neither crashes a background goroutine nor
+// the goroutine calling the cache. Each recovered panic is passed to onPanic if it is not nil.
+// A panicking memory pressure check counts as no pressure.
+// Functions computing values for the cache, such as cost functions and value copiers, are not covered.
+func WithSafeCallbacks[K comparable, V any](onPanic func(recovered any)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.safeCallbacks = true
+		o.panicHandler = onPanic
+	}
+}
+
+// WithMemoryPressureEviction starts a background goroutine that calls check every second and,
+// whenever it returns true, evicts evictFraction of the entries in the normal eviction order of the cache.
+// check can for example compare runtime/metrics or runtime.ReadMemStats against a threshold.
+// Use WithMemoryPressureInterval to check more or less often. Call Close to stop the goroutine.
+func WithMemoryPressureEviction[K comparable, V any](check func() bool, evictFraction float64) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.pressureCheck = check
+		o.pressureFraction = evictFraction
+	}
+}
+
+// WithMemoryPressureInterval sets how often the check of WithMemoryPressureEviction is called.
+// An interval of zero or less keeps the default of one second.
+func WithMemoryPressureInterval[K comparable, V any](interval time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.pressureInterval = interval
+	}
+}
+
+// WithWriteBehind starts a background goroutine that writes the values set in the cache to a backing store
+// by calling flush, without blocking the writers. Written keys are queued with their latest value and
+// flushed in batches of up to batchSize keys, as soon as a batch is full or at the latest every interval.
+// A value whose flush fails is retried with the next batch unless the key has been written again since;
+// failures are reported to the hook set with WithWriteBehindErrorHook.
+// Close flushes the queued values once more and waits for them, reporting and dropping values that fail.
+// flush must not call methods of the cache. It only applies to LRUCache.
+func WithWriteBehind[K comparable, V any](flush func(k K, v V) error, batchSize int, interval time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.flush = flush
+		o.flushBatch = batchSize
+		o.flushInterval = interval
+	}
+}
+
+// WithWriteBehindErrorHook registers a hook that is invoked from the write-behind goroutine
+// with the key, value and error of every failed flush of WithWriteBehind.
+func WithWriteBehindErrorHook[K comparable, V any](hook func(k K, v V, err error)) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.flushErrorHook = hook
+	}
+}
+
+// WithWriteBehindRetry makes the write-behind goroutine of WithWriteBehind retry failed flushes with
+// exponential backoff: after waiting baseDelay, then twice as long after every further failure, until
+// a value has been tried maxAttempts times in total. Values that still fail are reported to the hook set
+// with WithWriteBehindErrorHook and dropped, rather than retried with every later batch.
+// A value is no longer retried once its key is written again. Close waits for the retries of the final flush.
+func WithWriteBehindRetry[K comparable, V any](maxAttempts int, baseDelay time.Duration) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.flushAttempts = maxAttempts
+		o.flushRetryDelay = baseDelay
+	}
+}
+
+// WithEventChannel makes the cache send an Event on ch for every entry it removes, with the reason of the removal.
+// Events are sent without blocking while the cache lock is held: if ch is full, the event is dropped and
+// counted, see LRUCache.DroppedEvents. Entries cleared by Close are not reported and no events are sent
+// once Close has been called, so ch may be closed after Close returns. It only applies to LRUCache.
+func WithEventChannel[K comparable, V any](ch chan<- Event[K, V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.events = ch
+	}
+}
+
+// startPressureMonitor starts the memory pressure goroutine if one is configured.
+// shed is called with the fraction of entries to evict whenever the check reports pressure.
+// It stops when stopCh is closed.
+func (o *options[K, V]) startPressureMonitor(stopCh <-chan struct{}, shed func(fraction float64)) {
+	if o.pressureCheck == nil || o.pressureFraction <= 0 {
+		return
+	}
+	interval := o.pressureInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if o.underPressure() {
+					shed(o.pressureFraction)
+				}
+			}
+		}
+	}()
+}
+
+// startReporter starts the metrics reporter goroutine if one is configured.
+// It stops when stopCh is closed.
+func (o *options[K, V]) startReporter(stopCh <-chan struct{}, stats func() Stats) {
+	if o.reporter == nil || o.reportInterval <= 0 {
+		return
+	}
+	report := func(s Stats) {
+		defer o.recoverCallback()
+		o.reporter(s)
+	}
+	go runReporter(stopCh, o.reportInterval, stats, report)
+}
+
+// underPressure calls the memory pressure check. A recovered panic of the check counts as no pressure.
+func (o *options[K, V]) underPressure() bool {
+	defer o.recoverCallback()
+	return o.pressureCheck()
+}
+
+// newSweeper creates the background sweeper configured by the options.
+// interval overrides the configured cleanup interval if it is positive.
+func (o *options[K, V]) newSweeper(interval time.Duration) *sweeper {
+	if interval <= 0 {
+		interval = o.cleanupInterval
+	}
+	s := newSweeper(interval, o.cleanupMin, o.cleanupMax)
+	s.jitter = o.cleanupJitter
+	s.paused.Store(o.startPaused)
+	return s
+}
+
+// capacity returns the maximum number of entries of a cache created with the given size.
+// It panics for a size of 0 with WithStrictSizing.
+func (o *options[K, V]) capacity(size uint) uint {
+	if o.unbounded {
+		return math.MaxUint
+	}
+	if size == 0 && o.strictSizing {
+		panic("incache: cache size is 0, use WithUnbounded for a cache without a size limit")
+	}
+	return size
+}
+
+// tooLarge reports whether v exceeds the configured maximum value size.
+func (o *options[K, V]) tooLarge(v V) bool {
+	return o.sizer != nil && o.sizer(v) > o.maxValueSize
+}
+
+// ttl applies the minimum TTL of WithMinTTL to t. It returns false if t is below the minimum
+// and the write must be dropped because WithRejectShortTTL is used.
+func (o *options[K, V]) ttl(t time.Duration) (time.Duration, bool) {
+	if t <= 0 || t >= o.minTTL {
+		return t, true
+	}
+	return o.minTTL, !o.rejectShortTTL
+}
+
+// entryCost returns the cost of an entry, estimated with WithAutoCost or computed by the cost function
+// of WithMaxCost, or 0 if costs are not tracked.
+func (o *options[K, V]) entryCost(k K, v V) int64 {
+	if o.autoCost {
+		return estimateSize(k) + estimateSize(v)
+	}
+	if o.costFunc != nil {
+		return o.costFunc(v)
+	}
+	return 0
+}
+
+// estimateSize estimates the memory used by x in bytes: the size of its type, plus the length of a string.
+func estimateSize[T any](x T) int64 {
+	size := int64(unsafe.Sizeof(x))
+	if s, ok := any(x).(string); ok {
+		size += int64(len(s))
+	}
+	return size
+}
+
+// evictionCount returns the number of entries to evict when a full cache needs room for a new key.
+func (o *options[K, V]) evictionCount() int {
+	return max(o.evictionBatch, 1)
+}
+
+// watermarks returns the number of entries from which a cache of the given capacity evicts to add a new key,
+// and the number of entries it evicts down to, as configured by WithWatermarks or WithEvictionBatch.
+func (o *options[K, V]) watermarks(capacity uint) (high, low uint) {
+	if o.watermarkHigh <= 0 || o.unbounded {
+		return capacity, capacity - min(uint(o.evictionCount()), capacity)
+	}
+	high = min(uint(math.Ceil(min(o.watermarkHigh, 1)*float64(capacity))), capacity)
+	high = max(high, 1)
+	low = min(uint(max(o.watermarkLow, 0)*float64(capacity)), high-1)
+	return high, low
+}
+
+// now returns the current time according to the configured clock.
+func (o *options[K, V]) now() time.Time {
+	return o.clock.Now()
+}
+
+// expiryNow returns the current time in Unix nanoseconds for expiration checks, moved forward
+// by the grace period of WithExpiryGrace so that entries expire that much earlier.
+func (o *options[K, V]) expiryNow() int64 {
+	return o.clock.Now().Add(o.expiryGrace).UnixNano()
+}
+
+// observeOperation reports the time elapsed since start to the operation hook.
+func (o *options[K, V]) observeOperation(op string, start time.Time) {
+	defer o.recoverCallback()
+	o.operationHook(op, time.Since(start))
+}
+
+// observeMiss calls the miss hook with k unless found is true.
+func (o *options[K, V]) observeMiss(k K, found *bool) {
+	if !*found {
+		defer o.recoverCallback()
+		o.missHook(k)
+	}
+}
+
+// notifyRemoved calls OnRemoved on v if it implements Expirable.
+func (o *options[K, V]) notifyRemoved(v V) {
+	if e, ok := any(v).(Expirable); ok {
+		defer o.recoverCallback()
+		e.OnRemoved()
+	}
+}
+
+// recoverCallback recovers a panic of a user callback if WithSafeCallbacks is used
+// and passes it to the panic handler, if any. It must be deferred by the function invoking the callback.
+func (o *options[K, V]) recoverCallback() {
+	if !o.safeCallbacks {
+		return
+	}
+	if r := recover(); r != nil && o.panicHandler != nil {
+		o.panicHandler(r)
+	}
+}
+
+// copyValue returns a copy of v if a value copier is configured, otherwise v itself.
+func (o *options[K, V]) copyValue(v V) V {
+	if o.valueCopier == nil {
+		return v
+	}
+	return o.valueCopier(v)
+}
@@ -0,0 +1,449 @@
+package incache
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithHighWaterMark(t *testing.T) {
+	var fired int
+	hook := WithHighWaterMark[int, int](0.8, func(count, capacity uint) {
+		fired++
+		if count != 8 || capacity != 10 {
+			t.Errorf("Expected callback with count=8 cap=10, got count=%d cap=%d", count, capacity)
+		}
+	})
+
+	caches := newCaches(10, hook)
+
+	for name, c := range caches {
+		fired = 0
+
+		for i := 0; i < 7; i++ {
+			c.Set(i, i)
+		}
+		if fired != 0 {
+			t.Errorf("%s: callback fired below the mark", name)
+		}
+
+		// Crossing the mark fires once, staying above and evicting does not fire again
+		for i := 7; i < 20; i++ {
+			c.Set(i, i)
+		}
+		if fired != 1 {
+			t.Errorf("%s: expected callback to fire once, fired %d times", name, fired)
+		}
+
+		// Dropping below the mark and crossing again fires again
+		for _, k := range c.Keys()[:4] {
+			c.Delete(k)
+		}
+		c.Set(100, 100)
+		if fired != 1 {
+			t.Errorf("%s: callback fired below the mark", name)
+		}
+		c.Set(101, 101)
+		if fired != 2 {
+			t.Errorf("%s: expected callback to fire again after dropping below, fired %d times", name, fired)
+		}
+	}
+}
+
+func TestWithValueCopier(t *testing.T) {
+	copier := WithValueCopier[string, []int](func(v []int) []int {
+		return append([]int(nil), v...)
+	})
+
+	caches := newCaches(10, copier)
+
+	for name, c := range caches {
+		c.Set("key", []int{1, 2, 3})
+
+		v, _ := c.Get("key")
+		v[0] = 100
+
+		if v, _ := c.Get("key"); v[0] != 1 {
+			t.Errorf("%s: mutation of a value returned by Get affected the cache", name)
+		}
+
+		c.GetAll()["key"][1] = 100
+		if v, _ := c.Get("key"); v[1] != 2 {
+			t.Errorf("%s: mutation of a value returned by GetAll affected the cache", name)
+		}
+	}
+}
+
+func TestWithEvictionBatch(t *testing.T) {
+	batch := WithEvictionBatch[int, int](5)
+
+	caches := newCaches(10, batch)
+
+	for name, c := range caches {
+		for i := 0; i < 10; i++ {
+			c.Set(i, i)
+		}
+
+		c.Set(10, 10)
+		if c.Len() != 6 {
+			t.Errorf("%s: expected a batch of 5 to be evicted, got Len=%d", name, c.Len())
+		}
+		if _, ok := c.Get(10); !ok {
+			t.Errorf("%s: expected the inserted key to survive the batch eviction", name)
+		}
+
+		// No further eviction until the cache is full again
+		for i := 11; i < 15; i++ {
+			c.Set(i, i)
+		}
+		if c.Len() != 10 {
+			t.Errorf("%s: expected Len=10, got %d", name, c.Len())
+		}
+	}
+}
+
+func TestWithRejectOnFull(t *testing.T) {
+	reject := WithRejectOnFull[int, int]()
+	caches := newCaches(3, reject)
+	caches["SLRU"] = NewSLRU(3, reject)
+
+	for name, c := range caches {
+		for i := 0; i < 3; i++ {
+			c.Set(i, i)
+		}
+
+		c.Set(3, 3)
+		if c.NotFoundSet(4, 4) {
+			t.Errorf("%s: expected NotFoundSet to report a rejected key", name)
+		}
+		for i := 0; i < 3; i++ {
+			if _, ok := c.Get(i); !ok {
+				t.Errorf("%s: expected key %d not to be evicted", name, i)
+			}
+		}
+		if _, ok := c.Get(3); ok {
+			t.Errorf("%s: expected new key to be rejected", name)
+		}
+
+		// Existing keys can still be updated
+		c.Set(0, 10)
+		if v, _ := c.Get(0); v != 10 {
+			t.Errorf("%s: expected update of existing key, got %v", name, v)
+		}
+	}
+}
+
+func TestWithOperationHook(t *testing.T) {
+	var ops []string
+	hook := WithOperationHook[int, int](func(op string, d time.Duration) {
+		if d < 0 {
+			t.Errorf("Expected a non-negative duration for %s, got %v", op, d)
+		}
+		ops = append(ops, op)
+	})
+
+	caches := newCaches(10, hook)
+	caches["SLRU"] = NewSLRU(10, hook)
+
+	for name, c := range caches {
+		ops = nil
+
+		c.Set(1, 1)
+		c.SetWithTimeout(2, 2, time.Minute)
+		c.Get(1)
+		c.Get(3)
+		c.Delete(1)
+		c.Count()
+
+		want := []string{"Set", "SetWithTimeout", "Get", "Get", "Delete"}
+		if !slices.Equal(ops, want) {
+			t.Errorf("%s: expected hook calls %v, got %v", name, want, ops)
+		}
+	}
+}
+
+func TestWithUnbounded(t *testing.T) {
+	clock := NewMockClock()
+	opts := []Option[int, int]{WithUnbounded[int, int](), WithClock[int, int](clock)}
+	caches := map[string]Cache[int, int]{
+		"LRU":    NewLRU(0, opts...),
+		"LFU":    NewLFU(2, opts...),
+		"MCache": NewManual(0, 0, opts...),
+		"SLRU":   NewSLRU(2, opts...),
+	}
+
+	for name, c := range caches {
+		for i := 0; i < 1000; i++ {
+			c.Set(i, i)
+			c.Get(i)
+		}
+		c.SetWithTimeout(-1, -1, time.Second)
+
+		if c.Len() != 1001 {
+			t.Errorf("%s: expected nothing to be evicted, got Len=%d", name, c.Len())
+		}
+		for i := 0; i < 1000; i++ {
+			if v, ok := c.Get(i); !ok || v != i {
+				t.Errorf("%s: expected key %d to be kept, got %v", name, i, v)
+				break
+			}
+		}
+
+		// Entries are only removed when they expire
+		clock.Advance(2 * time.Second)
+		if _, ok := c.Get(-1); ok {
+			t.Errorf("%s: expected the expired key to be removed", name)
+		}
+
+		want := "size=unbounded count=1000"
+		if s := c.(fmt.Stringer).String(); !strings.Contains(s, want) {
+			t.Errorf("%s: expected String to contain %q, got %q", name, want, s)
+		}
+	}
+}
+
+func TestWithInitialCapacity_Negative(t *testing.T) {
+	opts := []Option[int, int]{WithInitialCapacity[int, int](-1)}
+	caches := newCaches(10, opts...)
+	caches["SLRU"] = NewSLRU(10, opts...)
+
+	for name, c := range caches {
+		c.Set(1, 1)
+		if v, ok := c.Get(1); !ok || v != 1 {
+			t.Errorf("%s: expected a negative initial capacity to be ignored, got %v, %v", name, v, ok)
+		}
+		c.Close()
+	}
+}
+
+func TestWithStrictSizing(t *testing.T) {
+	constructors := map[string]func(opts ...Option[int, int]) Cache[int, int]{
+		"LRU":    func(opts ...Option[int, int]) Cache[int, int] { return NewLRU(0, opts...) },
+		"LFU":    func(opts ...Option[int, int]) Cache[int, int] { return NewLFU(0, opts...) },
+		"MCache": func(opts ...Option[int, int]) Cache[int, int] { return NewManual(0, 0, opts...) },
+		"SLRU":   func(opts ...Option[int, int]) Cache[int, int] { return NewSLRU(0, opts...) },
+	}
+
+	for name, newCache := range constructors {
+		// By default a size-0 cache silently drops writes
+		c := newCache()
+		c.Set(1, 1)
+		if c.Len() != 0 {
+			t.Errorf("%s: expected a size-0 cache to store nothing, got Len=%d", name, c.Len())
+		}
+		c.Close()
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s: expected a size-0 cache to panic with WithStrictSizing", name)
+				}
+			}()
+			newCache(WithStrictSizing[int, int]())
+		}()
+
+		c = newCache(WithStrictSizing[int, int](), WithUnbounded[int, int]())
+		c.Set(1, 1)
+		if c.Len() != 1 {
+			t.Errorf("%s: expected an unbounded cache to be allowed with WithStrictSizing, got Len=%d", name, c.Len())
+		}
+		c.Close()
+	}
+}
+
+func TestWithMetricsReporter(t *testing.T) {
+	constructors := map[string]func(...Option[int, int]) Cache[int, int]{
+		"LRU":    func(opts ...Option[int, int]) Cache[int, int] { return NewLRU(10, opts...) },
+		"LFU":    func(opts ...Option[int, int]) Cache[int, int] { return NewLFU(10, opts...) },
+		"MCache": func(opts ...Option[int, int]) Cache[int, int] { return NewManual(10, time.Hour, opts...) },
+		"SLRU":   func(opts ...Option[int, int]) Cache[int, int] { return NewSLRU(10, opts...) },
+	}
+
+	for name, newCache := range constructors {
+		reports := make(chan Stats, 100)
+		c := newCache(WithMetricsReporter[int, int](time.Millisecond, func(s Stats) {
+			reports <- s
+		}))
+		c.Set(1, 1)
+		c.Get(1)
+
+		for fired := 0; fired < 2; {
+			select {
+			case s := <-reports:
+				if s.Hits == 1 && s.Len == 1 {
+					fired++
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: expected the reporter to fire with the current stats", name)
+			}
+		}
+
+		c.Close()
+		time.Sleep(5 * time.Millisecond)
+		for len(reports) > 0 {
+			<-reports
+		}
+		time.Sleep(5 * time.Millisecond)
+		if len(reports) != 0 {
+			t.Errorf("%s: expected the reporter to stop after Close", name)
+		}
+	}
+}
+
+func TestWithMemoryPressureEviction(t *testing.T) {
+	constructors := map[string]func(...Option[int, int]) Cache[int, int]{
+		"LRU":    func(opts ...Option[int, int]) Cache[int, int] { return NewLRU(100, opts...) },
+		"LFU":    func(opts ...Option[int, int]) Cache[int, int] { return NewLFU(100, opts...) },
+		"MCache": func(opts ...Option[int, int]) Cache[int, int] { return NewManual(100, 0, opts...) },
+		"SLRU":   func(opts ...Option[int, int]) Cache[int, int] { return NewSLRU(100, opts...) },
+	}
+
+	for name, newCache := range constructors {
+		var pressure, checked atomic.Bool
+		c := newCache(WithMemoryPressureEviction[int, int](func() bool {
+			checked.Store(true)
+			return pressure.CompareAndSwap(true, false)
+		}, 0.25), WithMemoryPressureInterval[int, int](time.Millisecond))
+
+		for i := 0; i < 100; i++ {
+			c.Set(i, i)
+		}
+		for !checked.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		if c.Len() != 100 {
+			t.Errorf("%s: expected no eviction without memory pressure, got Len=%d", name, c.Len())
+		}
+
+		// Report pressure once and wait for the check to consume it
+		pressure.Store(true)
+		deadline := time.Now().Add(time.Second)
+		for pressure.Load() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if c.Len() != 75 {
+			t.Errorf("%s: expected the cache to shed a quarter of its entries, got Len=%d", name, c.Len())
+		}
+		// The most recently set key survives the LRU, LFU and SLRU policies, MCache evicts arbitrary keys
+		if _, ok := c.Get(99); !ok && name != "MCache" {
+			t.Errorf("%s: expected eviction to follow the policy order", name)
+		}
+
+		c.Close()
+	}
+}
+
+func TestWithSafeCallbacks(t *testing.T) {
+	var recovered []any
+	opts := []Option[int, int]{
+		WithSafeCallbacks[int, int](func(r any) { recovered = append(recovered, r) }),
+		WithOperationHook[int, int](func(op string, d time.Duration) {
+			if op == "Set" {
+				panic("hook failed")
+			}
+		}),
+	}
+	caches := newCaches(10, opts...)
+	caches["SLRU"] = NewSLRU(10, opts...)
+
+	for name, c := range caches {
+		recovered = nil
+
+		c.Set(1, 1)
+		c.Set(2, 2)
+		if v, ok := c.Get(1); !ok || v != 1 {
+			t.Errorf("%s: expected the cache to remain usable after a panicking hook, got (%v, %v)", name, v, ok)
+		}
+		if len(recovered) != 2 || recovered[0] != "hook failed" {
+			t.Errorf("%s: expected the handler to receive both panics, got %v", name, recovered)
+		}
+	}
+}
+
+func TestWithSafeCallbacks_Background(t *testing.T) {
+	recovered := make(chan any, 100)
+	reports := 0
+	c := NewLRU(10,
+		WithSafeCallbacks[int, int](func(r any) { recovered <- r }),
+		WithMetricsReporter[int, int](time.Millisecond, func(s Stats) {
+			reports++
+			panic(reports)
+		}),
+	)
+	defer c.Close()
+
+	// The reporter goroutine keeps running after its callback panicked
+	for i := 1; i <= 3; i++ {
+		select {
+		case r := <-recovered:
+			if r != i {
+				t.Errorf("Expected the handler to receive panic %d, got %v", i, r)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the reporter to keep running after %d panics", i-1)
+		}
+	}
+}
+
+type panickingResource struct{}
+
+func (panickingResource) OnRemoved() { panic("close failed") }
+
+func TestWithSafeCallbacks_OnRemoved(t *testing.T) {
+	var recovered any
+	c := NewLRU(1, WithSafeCallbacks[string, panickingResource](func(r any) { recovered = r }))
+
+	c.Set("a", panickingResource{})
+	c.Set("b", panickingResource{}) // evicts "a"
+	if recovered != "close failed" {
+		t.Errorf("Expected the handler to receive the OnRemoved panic, got %v", recovered)
+	}
+	if _, ok := c.Get("b"); !ok || c.Len() != 1 {
+		t.Errorf("Expected the cache to remain consistent after a panicking OnRemoved")
+	}
+}
+
+func TestWithMissHook(t *testing.T) {
+	clock := NewMockClock()
+	var missed []int
+	opts := []Option[int, int]{
+		WithClock[int, int](clock),
+		WithMissHook[int, int](func(k int) { missed = append(missed, k) }),
+	}
+	caches := newCaches(10, opts...)
+	caches["SLRU"] = NewSLRU(10, opts...)
+
+	for name, c := range caches {
+		missed = nil
+
+		c.Set(1, 1)
+		c.SetWithTimeout(2, 2, time.Second)
+		c.Get(1)
+		c.Get(3)
+		clock.Advance(2 * time.Second)
+		c.Get(2)
+		c.Get(1)
+
+		want := []int{3, 2}
+		if !slices.Equal(missed, want) {
+			t.Errorf("%s: expected misses %v, got %v", name, want, missed)
+		}
+	}
+}
+
+func TestWithMissHook_Unlocked(t *testing.T) {
+	var c *LRUCache[string, int]
+	c = NewLRU(10, WithMissHook[string, int](func(k string) {
+		c.Set(k, len(k)) // would deadlock if the lock were held
+	}))
+
+	c.Get("abc")
+	if v, ok := c.Get("abc"); !ok || v != 3 {
+		t.Errorf("Expected the hook to store the missed key, got (%v, %v)", v, ok)
+	}
+}
@@ -0,0 +1,74 @@
+package incache
+
+import "fmt"
+
+// Policy selects the eviction policy of a cache created with NewCache.
+type Policy int
+
+const (
+	// PolicyLRU creates an LRUCache, which evicts the least recently used entry.
+	PolicyLRU Policy = iota
+	// PolicyLFU creates an LFUCache, which evicts the least frequently used entry.
+	PolicyLFU
+	// PolicyManual creates an MCache, which evicts an expired entry if there is any, otherwise an arbitrary one.
+	PolicyManual
+	// PolicySLRU creates an SLRUCache, which protects entries that have been read again from one-time scans.
+	PolicySLRU
+)
+
+// String returns the name of the policy, e.g. "lru", as accepted by ParsePolicy.
+func (p Policy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyLFU:
+		return "lfu"
+	case PolicyManual:
+		return "manual"
+	case PolicySLRU:
+		return "slru"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// ParsePolicy returns the policy with the given name, e.g. "lru", as returned by Policy.String.
+// It lets configuration choose the policy of a cache by name.
+func ParsePolicy(name string) (Policy, error) {
+	for p := PolicyLRU; p <= PolicySLRU; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("incache: unknown policy %q", name)
+}
+
+// NewCache creates a cache of the given policy and size, e.g. to choose the policy at runtime.
+// It returns the cache as the Cache interface; use the constructor of the policy, e.g. NewLRU,
+// to access methods specific to one cache type. MCache caches are created without a cleanup interval
+// of their own, so WithCleanupInterval applies. It panics if the policy is unknown.
+func NewCache[K comparable, V any](policy Policy, size uint, opts ...Option[K, V]) Cache[K, V] {
+	switch policy {
+	case PolicyLRU:
+		return NewLRU(size, opts...)
+	case PolicyLFU:
+		return NewLFU(size, opts...)
+	case PolicyManual:
+		return NewManual(size, 0, opts...)
+	case PolicySLRU:
+		return NewSLRU(size, opts...)
+	default:
+		panic(fmt.Sprintf("incache: unknown policy %v", policy))
+	}
+}
+
+// SwitchPolicy creates a cache of the given policy and size with NewCache, moves the live entries of c
+// into it with Transfer, preserving their remaining time to live, closes c and returns the new cache.
+// If the new cache is smaller than the number of live entries, it evicts some of them on the way.
+// c must not be used concurrently while its policy is switched, as entries written to it would be lost.
+func SwitchPolicy[K comparable, V any](c Cache[K, V], newPolicy Policy, size uint, opts ...Option[K, V]) Cache[K, V] {
+	next := NewCache(newPolicy, size, opts...)
+	Transfer(c, next)
+	c.Close()
+	return next
+}
@@ -0,0 +1,87 @@
+package incache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewCache(t *testing.T) {
+	clock := NewMockClock()
+	for _, p := range []Policy{PolicyLRU, PolicyLFU, PolicyManual, PolicySLRU} {
+		c := NewCache(p, 2, WithClock[string, int](clock), WithName[string, int](p.String()))
+
+		c.Set("a", 1)
+		c.SetWithTimeout("b", 2, time.Minute)
+		if v, ok := c.Get("a"); !ok || v != 1 {
+			t.Errorf("%v: expected 1, got %v, %v", p, v, ok)
+		}
+		c.Set("c", 3)
+		if c.Count() != 2 {
+			t.Errorf("%v: expected the size to be applied, got %d entries", p, c.Count())
+		}
+		if c.Name() != p.String() {
+			t.Errorf("%v: expected the options to be applied, got name %q", p, c.Name())
+		}
+		c.Close()
+	}
+
+	if _, ok := NewCache[string, int](PolicyLFU, 10).(*LFUCache[string, int]); !ok {
+		t.Errorf("Expected PolicyLFU to create an LFUCache")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected NewCache to panic for an unknown policy")
+		}
+	}()
+	NewCache[string, int](Policy(42), 10)
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, p := range []Policy{PolicyLRU, PolicyLFU, PolicyManual, PolicySLRU} {
+		if got, err := ParsePolicy(p.String()); err != nil || got != p {
+			t.Errorf("Expected %v, got %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParsePolicy("fifo"); err == nil {
+		t.Errorf("Expected an error for an unknown policy")
+	}
+	if s := Policy(42).String(); s != "Policy(42)" {
+		t.Errorf("Expected Policy(42), got %s", s)
+	}
+}
+
+func TestSwitchPolicy(t *testing.T) {
+	lru := NewLRU[string, int](100)
+	for i := 0; i < 50; i++ {
+		lru.Set(strconv.Itoa(i), i)
+	}
+	lru.SetWithTimeout("ttl", -1, time.Hour)
+	lru.SetWithTimeout("expired", -2, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	c := SwitchPolicy[string, int](lru, PolicyLFU, 100)
+	defer c.Close()
+	if _, ok := c.(*LFUCache[string, int]); !ok {
+		t.Fatalf("Expected an LFUCache, got %T", c)
+	}
+
+	for i := 0; i < 50; i++ {
+		if v, ok := c.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Errorf("Expected %d to survive the switch, got %v, %v", i, v, ok)
+		}
+	}
+	if c.Count() != 51 {
+		t.Errorf("Expected 51 live entries, got %d", c.Count())
+	}
+	e, ok := c.GetWithExpiration("ttl")
+	if !ok || time.Until(e.ExpireAt) < 59*time.Minute {
+		t.Errorf("Expected the TTL to be preserved, got %v, %v", e.ExpireAt, ok)
+	}
+
+	lru.Set("late", 1)
+	if lru.Len() != 0 {
+		t.Errorf("Expected the old cache to be closed")
+	}
+}
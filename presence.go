@@ -0,0 +1,25 @@
+package incache
+
+// Presence describes whether a key was found in a cache.
+type Presence int
+
+const (
+	// Absent means the key is not in the cache.
+	Absent Presence = iota
+	// Live means the key is in the cache and has not expired.
+	Live
+	// Expired means the key was in the cache but has expired.
+	Expired
+)
+
+// String returns the name of the presence, e.g. "live".
+func (p Presence) String() string {
+	switch p {
+	case Live:
+		return "live"
+	case Expired:
+		return "expired"
+	default:
+		return "absent"
+	}
+}
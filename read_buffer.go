@@ -0,0 +1,61 @@
+package incache
+
+import "sync"
+
+// readBufferStripes is the number of independently locked stripes of a readBuffer.
+const readBufferStripes = 16
+
+// readBuffer records the items read by LRUCache.Get with WithReadBuffer, so that the reads only need
+// the read lock of the cache and the eviction list is reordered later under the write lock.
+// Reads are spread over stripes by item index to keep the lock of each stripe uncontended.
+// Records are dropped while a stripe is full, so the recency order is approximate.
+type readBuffer[K comparable] struct {
+	stripes [readBufferStripes]readStripe[K]
+}
+
+type readStripe[K comparable] struct {
+	mu   sync.Mutex
+	hits []readRecord[K]
+	_    [32]byte // keep stripes on separate cache lines
+}
+
+// readRecord is a read of the item at index i with the given key. It is stale if the item has been removed since.
+type readRecord[K comparable] struct {
+	i   int
+	key K
+}
+
+// newReadBuffer creates a read buffer holding up to size records.
+func newReadBuffer[K comparable](size int) *readBuffer[K] {
+	b := &readBuffer[K]{}
+	perStripe := max(size/readBufferStripes, 1)
+	for s := range b.stripes {
+		b.stripes[s].hits = make([]readRecord[K], 0, perStripe)
+	}
+	return b
+}
+
+// record records a read of the item at index i, unless its stripe is full.
+func (b *readBuffer[K]) record(i int, key K) {
+	s := &b.stripes[i%readBufferStripes]
+	s.mu.Lock()
+	if len(s.hits) < cap(s.hits) {
+		s.hits = append(s.hits, readRecord[K]{i: i, key: key})
+	}
+	s.mu.Unlock()
+}
+
+// drain calls apply for every recorded read, stripe by stripe, and empties the buffer.
+// The caller must hold the write lock of the cache.
+func (b *readBuffer[K]) drain(apply func(r readRecord[K])) {
+	for s := range b.stripes {
+		stripe := &b.stripes[s]
+		stripe.mu.Lock()
+		for _, r := range stripe.hits {
+			apply(r)
+		}
+		clear(stripe.hits)
+		stripe.hits = stripe.hits[:0]
+		stripe.mu.Unlock()
+	}
+}
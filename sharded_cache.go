@@ -0,0 +1,209 @@
+package incache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"maps"
+	"time"
+)
+
+// ShardedCache spreads keys over several independent caches, called shards, by the hash of the key.
+// Each shard has its own lock, which reduces lock contention when the cache is used concurrently.
+// Eviction and capacity apply per shard.
+type ShardedCache[K comparable, V any] struct {
+	shards []Cache[K, V]
+	seed   maphash.Seed
+	opts   options[K, V]
+}
+
+// NewSharded creates a new sharded cache with n shards, each created by newShard.
+// For example, NewSharded(16, func() Cache[string, int] { return NewLRU[string, int](1000) })
+// creates a cache that holds up to 16000 entries in 16 LRU shards.
+// If n is less than 1, a single shard is used.
+func NewSharded[K comparable, V any](n int, newShard func() Cache[K, V], opts ...Option[K, V]) *ShardedCache[K, V] {
+	n = max(n, 1)
+	c := &ShardedCache[K, V]{
+		shards: make([]Cache[K, V], n),
+		seed:   maphash.MakeSeed(),
+		opts:   applyOptions(opts),
+	}
+	for i := range c.shards {
+		c.shards[i] = newShard()
+	}
+	return c
+}
+
+// shard returns the shard responsible for the key.
+func (c *ShardedCache[K, V]) shard(k K) Cache[K, V] {
+	return c.shards[c.hash(k)%uint64(len(c.shards))]
+}
+
+// hash returns the hash of the key, using the hasher configured with WithHasher if any.
+func (c *ShardedCache[K, V]) hash(k K) uint64 {
+	if c.opts.hasher != nil {
+		return c.opts.hasher(k)
+	}
+	return maphash.Comparable(c.seed, k)
+}
+
+// Get retrieves the value associated with the given key from its shard.
+// If the key is not found or has expired, it returns (zero value of V, false).
+func (c *ShardedCache[K, V]) Get(k K) (V, bool) {
+	return c.shard(k).Get(k)
+}
+
+// GetOrDefault retrieves the value associated with the given key like Get,
+// or returns def if the key is not found or has expired.
+func (c *ShardedCache[K, V]) GetOrDefault(k K, def V) V {
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	return def
+}
+
+// GetWithExpiration retrieves the value associated with the given key together with its expiration time.
+// If the key is not found or has expired, it returns (zero value of ValueTTL[V], false).
+func (c *ShardedCache[K, V]) GetWithExpiration(k K) (ValueTTL[V], bool) {
+	return c.shard(k).GetWithExpiration(k)
+}
+
+// Set adds the key-value pair to its shard.
+func (c *ShardedCache[K, V]) Set(k K, v V) {
+	c.shard(k).Set(k, v)
+}
+
+// SetWithTimeout adds the key-value pair to its shard with a specified expiration time.
+func (c *ShardedCache[K, V]) SetWithTimeout(k K, v V, t time.Duration) {
+	c.shard(k).SetWithTimeout(k, v, t)
+}
+
+// NotFoundSet adds the key-value pair only if the key does not exist or is expired.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *ShardedCache[K, V]) NotFoundSet(k K, v V) bool {
+	return c.shard(k).NotFoundSet(k, v)
+}
+
+// NotFoundSetWithTimeout adds the key-value pair with an expiration time only if the key does not exist or is expired.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *ShardedCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+	return c.shard(k).NotFoundSetWithTimeout(k, v, t)
+}
+
+// ReplaceIfPresent updates the value of the key only if it exists and is not expired.
+// It returns true if the value was replaced, otherwise false.
+func (c *ShardedCache[K, V]) ReplaceIfPresent(k K, v V) bool {
+	return c.shard(k).ReplaceIfPresent(k, v)
+}
+
+// Delete removes the key-value pair associated with the given key from its shard.
+func (c *ShardedCache[K, V]) Delete(k K) {
+	c.shard(k).Delete(k)
+}
+
+// GetAll retrieves all non-expired key-value pairs from all shards.
+// The shards are read one after another, so the result is not an atomic snapshot of the whole cache.
+func (c *ShardedCache[K, V]) GetAll() map[K]V {
+	m := make(map[K]V)
+	for _, s := range c.shards {
+		for k, v := range s.GetAll() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// GetAllWithExpiration retrieves all non-expired key-value pairs from all shards together with their expiration times.
+// The shards are read one after another, so the result is not an atomic snapshot of the whole cache.
+func (c *ShardedCache[K, V]) GetAllWithExpiration() map[K]ValueTTL[V] {
+	m := make(map[K]ValueTTL[V])
+	for _, s := range c.shards {
+		for k, v := range s.GetAllWithExpiration() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Keys returns the non-expired keys of all shards.
+// The order of keys in the slice is not guaranteed.
+func (c *ShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, s := range c.shards {
+		keys = append(keys, s.Keys()...)
+	}
+	return keys
+}
+
+// Purge removes all key-value pairs from all shards.
+func (c *ShardedCache[K, V]) Purge() {
+	for _, s := range c.shards {
+		s.Purge()
+	}
+}
+
+// drainWithTTL removes all key-value pairs from all shards and returns the ones that were not expired
+// together with their remaining time to live, see drainAll.
+func (c *ShardedCache[K, V]) drainWithTTL() map[K]drainedEntry[V] {
+	m := make(map[K]drainedEntry[V])
+	for _, s := range c.shards {
+		maps.Copy(m, drainAll(s))
+	}
+	return m
+}
+
+// Count returns the number of non-expired key-value pairs in all shards.
+func (c *ShardedCache[K, V]) Count() int {
+	count := 0
+	for _, s := range c.shards {
+		count += s.Count()
+	}
+	return count
+}
+
+// Len returns the total number of elements in all shards (including expired ones).
+func (c *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+// Close closes all shards.
+func (c *ShardedCache[K, V]) Close() {
+	for _, s := range c.shards {
+		s.Close()
+	}
+}
+
+// Stats returns the usage statistics of the cache, aggregated over all shards.
+func (c *ShardedCache[K, V]) Stats() Stats {
+	var total Stats
+	for _, s := range c.ShardStats() {
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Evictions += s.Evictions
+		total.Len += s.Len
+	}
+	return total
+}
+
+// ShardStats returns the usage statistics of each shard, which helps to detect hot shards.
+// Each shard is read under its own lock only, so it does not block operations on the other shards.
+func (c *ShardedCache[K, V]) ShardStats() []Stats {
+	stats := make([]Stats, len(c.shards))
+	for i, s := range c.shards {
+		stats[i] = s.Stats()
+	}
+	return stats
+}
+
+// Name returns the name of the cache set with WithName, or an empty string.
+func (c *ShardedCache[K, V]) Name() string {
+	return c.opts.name
+}
+
+// String returns a compact summary of the cache, e.g. incache.Sharded[name=sessions shards=16 count=812].
+func (c *ShardedCache[K, V]) String() string {
+	return fmt.Sprintf("incache.Sharded[name=%s shards=%d count=%d]", c.opts.name, len(c.shards), c.Count())
+}
@@ -0,0 +1,152 @@
+package incache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSharded(n, size int) *ShardedCache[string, int] {
+	return NewSharded(n, func() Cache[string, int] { return NewLRU[string, int](uint(size)) })
+}
+
+func TestShardedCache_SetGet(t *testing.T) {
+	c := newTestSharded(4, 100)
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	c.SetWithTimeout("expired", -1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		if v, ok := c.Get(fmt.Sprint(i)); !ok || v != i {
+			t.Errorf("Expected %d, got %v", i, v)
+		}
+	}
+	if _, ok := c.Get("expired"); ok {
+		t.Errorf("Expected expired key to be missing")
+	}
+	if c.Count() != 100 || len(c.Keys()) != 100 || len(c.GetAll()) != 100 {
+		t.Errorf("Expected 100 live keys, got Count=%d", c.Count())
+	}
+
+	c.Delete("0")
+	if _, ok := c.Get("0"); ok {
+		t.Errorf("Expected deleted key to be missing")
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Expected Purge to empty all shards, got Len=%d", c.Len())
+	}
+}
+
+func TestShardedCache_ShardStats(t *testing.T) {
+	c := newTestSharded(8, 100)
+	defer c.Close()
+
+	for i := 0; i < 200; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	for i := 0; i < 300; i++ {
+		c.Get(fmt.Sprint(i))
+	}
+
+	shards := c.ShardStats()
+	if len(shards) != 8 {
+		t.Fatalf("Expected stats for 8 shards, got %d", len(shards))
+	}
+
+	var sum Stats
+	used := 0
+	for _, s := range shards {
+		sum.Hits += s.Hits
+		sum.Misses += s.Misses
+		sum.Len += s.Len
+		if s.Len > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("Expected keys to land on several shards, %d were used", used)
+	}
+
+	total := c.Stats()
+	if sum.Hits != total.Hits || sum.Misses != total.Misses || sum.Len != total.Len {
+		t.Errorf("Expected per-shard stats %+v to sum to the aggregate %+v", sum, total)
+	}
+	if total.Hits != 200 || total.Misses != 100 || total.Len != 200 {
+		t.Errorf("Expected 200 hits, 100 misses and 200 entries, got %+v", total)
+	}
+}
+
+func TestShardedCache_ConcurrentStats(t *testing.T) {
+	c := newTestSharded(4, 100)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := fmt.Sprint(i % 150)
+				c.Set(k, i)
+				c.Get(k)
+				c.ShardStats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := c.Stats(); s.Hits+s.Misses != 4000 {
+		t.Errorf("Expected 4000 lookups, got %+v", s)
+	}
+}
+
+func TestShardedCache_WithHasher(t *testing.T) {
+	type point struct{ x, y int }
+
+	c := NewSharded(4, func() Cache[point, string] { return NewLRU[point, string](10) },
+		WithHasher[point, string](func(p point) uint64 { return uint64(p.x) }),
+	)
+	defer c.Close()
+
+	c.Set(point{0, 1}, "a")
+	c.Set(point{0, 2}, "b")
+	c.Set(point{2, 0}, "c")
+	c.Set(point{6, 0}, "d")
+
+	// Keys route to shard x % 4
+	want := []int{2, 0, 2, 0}
+	for i, s := range c.ShardStats() {
+		if s.Len != want[i] {
+			t.Errorf("Expected shard %d to hold %d keys, got %d", i, want[i], s.Len)
+		}
+	}
+	if v, ok := c.Get(point{6, 0}); !ok || v != "d" {
+		t.Errorf("Expected d, got %v", v)
+	}
+}
+
+func TestShardedCache_DefaultHasherStructKeys(t *testing.T) {
+	type key struct {
+		tenant string
+		id     int
+	}
+
+	c := NewSharded(4, func() Cache[key, int] { return NewLRU[key, int](100) })
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(key{"t", i}, i)
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := c.Get(key{"t", i}); !ok || v != i {
+			t.Errorf("Expected %d, got %v", i, v)
+		}
+	}
+}
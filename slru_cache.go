@@ -0,0 +1,520 @@
+package incache
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultProtectedRatio is the fraction of an SLRU cache reserved for the protected segment.
+const defaultProtectedRatio = 0.8
+
+// SLRUCache implements a Segmented Least Recently Used cache with O(1) operations.
+// New entries enter a probationary segment and are promoted to a protected segment when they are accessed again.
+// Entries that are only accessed once, such as those of a scan, are evicted before the protected working set.
+type SLRUCache[K comparable, V any] struct {
+	mu            sync.Mutex
+	size          uint
+	protectedSize uint                // maximum number of items in the protected segment
+	m             map[K]*list.Element // where the key-value pairs are stored
+	probation     *list.List          // items accessed once, evicted first
+	protected     *list.List          // items accessed at least twice
+	stopCh        chan struct{}       // Channel to signal the expiration goroutine to stop
+	sweeper       *sweeper
+	closed        bool
+	stats         Stats
+	opts          options[K, V]
+}
+
+type slruItem[K comparable, V any] struct {
+	key       K
+	value     V
+	expireAt  int64 // Unix nano timestamp, 0 means no expiration
+	protected bool  // whether the item is in the protected segment
+}
+
+// NewSLRU creates a new SLRU cache with the specified maximum size.
+// By default 80% of the size is reserved for the protected segment, see WithProtectedRatio.
+// If size is 0, the cache will not store any items unless WithUnbounded is used; with WithStrictSizing, it panics instead.
+// If a cleanup interval is configured, a background goroutine removes expired keys until Close is called.
+func NewSLRU[K comparable, V any](size uint, opts ...Option[K, V]) *SLRUCache[K, V] {
+	o := applyOptions(opts)
+	ratio := o.protectedRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultProtectedRatio
+	}
+
+	protectedSize := uint(float64(size) * ratio)
+	if o.unbounded {
+		protectedSize = math.MaxUint
+	}
+
+	c := &SLRUCache[K, V]{
+		size:          o.capacity(size),
+		protectedSize: protectedSize,
+		m:             make(map[K]*list.Element, o.initialCapacity),
+		probation:     list.New(),
+		protected:     list.New(),
+		stopCh:        make(chan struct{}),
+		opts:          o,
+	}
+	c.sweeper = c.opts.newSweeper(0)
+	if !registerGlobalSweep(c.stopCh, c.sweeper, c.sweep) && c.sweeper.currentInterval() > 0 {
+		go c.sweeper.run(c.stopCh, c.sweep)
+	}
+	c.opts.startReporter(c.stopCh, c.Stats)
+	c.opts.startPressureMonitor(c.stopCh, c.shed)
+	return c
+}
+
+// NewSLRUFrom creates a new SLRU cache with the specified maximum size, filled with the entries of seed.
+// The seed entries enter the probationary segment. The internal map is presized for them unless WithInitialCapacity is given.
+// If the seed holds more than size entries, the cache keeps size of them; since map iteration order
+// is unspecified, which entries are kept is arbitrary.
+func NewSLRUFrom[K comparable, V any](size uint, seed map[K]V, opts ...Option[K, V]) *SLRUCache[K, V] {
+	c := NewSLRU(size, withSeedCapacity(opts, len(seed), size)...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range seed {
+		c.set(k, v, 0)
+	}
+	return c
+}
+
+// Get retrieves the value associated with the given key from the cache.
+// If the key is not found or has expired, it returns (zero value of V, false).
+// Otherwise, it returns (value, true) and promotes the key to the protected segment.
+func (c *SLRUCache[K, V]) Get(k K) (v V, b bool) {
+	if c.opts.missHook != nil {
+		defer c.opts.observeMiss(k, &b) // runs after the lock is released
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Get", time.Now())
+	}
+
+	slruItem, ok := c.get(k)
+	if !ok {
+		return
+	}
+
+	return c.opts.copyValue(slruItem.value), true
+}
+
+// GetOrDefault retrieves the value associated with the given key like Get,
+// or returns def if the key is not found or has expired.
+// It counts as an access like Get.
+func (c *SLRUCache[K, V]) GetOrDefault(k K, def V) V {
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	return def
+}
+
+// GetWithExpiration retrieves the value associated with the given key together with its expiration time.
+// If the key is not found or has expired, it returns (zero value of ValueTTL[V], false).
+func (c *SLRUCache[K, V]) GetWithExpiration(k K) (v ValueTTL[V], b bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slruItem, ok := c.get(k)
+	if !ok {
+		return
+	}
+
+	return ValueTTL[V]{Value: c.opts.copyValue(slruItem.value), ExpireAt: expireTime(slruItem.expireAt)}, true
+}
+
+// get returns the non-expired item for the key and records the access.
+// Expired items are removed.
+func (c *SLRUCache[K, V]) get(k K) (*slruItem[K, V], bool) {
+	item, ok := c.m[k]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	slruItem := item.Value.(*slruItem[K, V])
+	if slruItem.expireAt > 0 && slruItem.expireAt < c.opts.expiryNow() {
+		c.removeElement(item)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.access(item)
+	return slruItem, true
+}
+
+// GetAll retrieves all key-value pairs from the cache.
+// It returns a map containing all the key-value pairs that are not expired.
+func (c *SLRUCache[K, V]) GetAll() map[K]V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]V)
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		slruItem := v.Value.(*slruItem[K, V])
+		if slruItem.expireAt == 0 || slruItem.expireAt >= now {
+			m[k] = c.opts.copyValue(slruItem.value)
+		}
+	}
+
+	return m
+}
+
+// GetAllWithExpiration retrieves all key-value pairs from the cache together with their expiration times.
+// It returns a map containing all the key-value pairs that are not expired.
+func (c *SLRUCache[K, V]) GetAllWithExpiration() map[K]ValueTTL[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]ValueTTL[V])
+	now := c.opts.expiryNow()
+	for k, v := range c.m {
+		slruItem := v.Value.(*slruItem[K, V])
+		if slruItem.expireAt == 0 || slruItem.expireAt >= now {
+			m[k] = ValueTTL[V]{Value: c.opts.copyValue(slruItem.value), ExpireAt: expireTime(slruItem.expireAt)}
+		}
+	}
+
+	return m
+}
+
+// Set adds the key-value pair to the cache.
+func (c *SLRUCache[K, V]) Set(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Set", time.Now())
+	}
+
+	c.set(k, v, 0)
+}
+
+// SetWithTimeout adds the key-value pair to the cache with a specified expiration time.
+func (c *SLRUCache[K, V]) SetWithTimeout(k K, v V, t time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("SetWithTimeout", time.Now())
+	}
+
+	c.set(k, v, t)
+}
+
+// NotFoundSet adds the key-value pair to the cache only if the key does not exist or is expired.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *SLRUCache[K, V]) NotFoundSet(k K, v V) bool {
+	return c.NotFoundSetWithTimeout(k, v, 0)
+}
+
+// NotFoundSetWithTimeout adds the key-value pair to the cache only if the key does not exist or is expired.
+// It sets an expiration time for the key-value pair.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *SLRUCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	if item, ok := c.m[k]; ok {
+		slruItem := item.Value.(*slruItem[K, V])
+		// Check if existing key is expired
+		if slruItem.expireAt == 0 || slruItem.expireAt >= c.opts.expiryNow() {
+			return false
+		}
+		// Key exists but is expired, delete it first
+		c.removeElement(item)
+	}
+
+	return c.set(k, v, t)
+}
+
+// ReplaceIfPresent updates the value of the key only if it exists and is not expired.
+// The existing expiration time is preserved and the replace counts as an access.
+// It returns true if the value was replaced, otherwise false.
+func (c *SLRUCache[K, V]) ReplaceIfPresent(k K, v V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.m[k]
+	if !ok {
+		return false
+	}
+
+	slruItem := item.Value.(*slruItem[K, V])
+	if slruItem.expireAt > 0 && slruItem.expireAt < c.opts.expiryNow() {
+		c.removeElement(item)
+		return false
+	}
+
+	slruItem.value = v
+	c.access(item)
+	return true
+}
+
+// Delete removes the key-value pair associated with the given key from the cache.
+func (c *SLRUCache[K, V]) Delete(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opts.operationHook != nil {
+		defer c.opts.observeOperation("Delete", time.Now())
+	}
+
+	if item, ok := c.m[k]; ok {
+		c.removeElement(item)
+	}
+}
+
+// Keys returns a slice of all keys currently stored in the cache.
+// The returned slice does not include expired keys.
+// The order of keys in the slice is not guaranteed.
+func (c *SLRUCache[K, V]) Keys() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	keys := make([]K, 0, len(c.m))
+
+	for k, v := range c.m {
+		slruItem := v.Value.(*slruItem[K, V])
+		if slruItem.expireAt == 0 || slruItem.expireAt >= now {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// Purge removes all key-value pairs from the cache.
+func (c *SLRUCache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.notifyAll()
+	c.m = make(map[K]*list.Element)
+	c.probation.Init()
+	c.protected.Init()
+}
+
+// drainWithTTL removes all key-value pairs from the cache and returns the ones that were not expired
+// together with their remaining time to live. Only the expired values are notified of their removal.
+func (c *SLRUCache[K, V]) drainWithTTL() map[K]drainedEntry[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := make(map[K]drainedEntry[V])
+	now := c.opts.expiryNow()
+	for k, e := range c.m {
+		item := e.Value.(*slruItem[K, V])
+		if ttl, ok := remainingTTL(item.expireAt, now); ok {
+			m[k] = drainedEntry[V]{value: item.value, ttl: ttl}
+		} else {
+			c.opts.notifyRemoved(item.value)
+		}
+	}
+
+	c.m = make(map[K]*list.Element)
+	c.probation.Init()
+	c.protected.Init()
+	return m
+}
+
+// Close stops the background goroutines, if any, and clears the cache.
+// After calling Close, the cache should not be used.
+func (c *SLRUCache[K, V]) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.stopCh)
+
+	c.notifyAll()
+	c.m = nil
+	c.probation.Init()
+	c.protected.Init()
+}
+
+// Count returns the number of non-expired key-value pairs currently stored in the cache.
+// With WithApproximateCount it returns the number of stored entries without checking them for expiration.
+func (c *SLRUCache[K, V]) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.opts.approxCount {
+		return len(c.m)
+	}
+	count := 0
+	now := c.opts.expiryNow()
+	for _, v := range c.m {
+		slruItem := v.Value.(*slruItem[K, V])
+		if slruItem.expireAt == 0 || slruItem.expireAt >= now {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Len returns the total number of elements in the cache (including expired ones).
+func (c *SLRUCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.m)
+}
+
+// Name returns the name of the cache set with WithName, or an empty string.
+func (c *SLRUCache[K, V]) Name() string {
+	return c.opts.name
+}
+
+// Stats returns the usage statistics of the cache.
+func (c *SLRUCache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Len = len(c.m)
+	return stats
+}
+
+// String returns a compact summary of the cache, e.g. incache.SLRU[name=sessions size=1000 count=812].
+func (c *SLRUCache[K, V]) String() string {
+	return fmt.Sprintf("incache.SLRU[name=%s size=%s count=%d]", c.opts.name, sizeString(c.size), c.Count())
+}
+
+// sweep removes all expired keys and reports how many keys were scanned and removed.
+func (c *SLRUCache[K, V]) sweep() (scanned, removed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.expiryNow()
+	for _, v := range c.m {
+		scanned++
+		slruItem := v.Value.(*slruItem[K, V])
+		if slruItem.expireAt > 0 && slruItem.expireAt < now {
+			c.removeElement(v)
+			removed++
+		}
+	}
+	return scanned, removed
+}
+
+// shed evicts the given fraction of the entries in response to memory pressure.
+func (c *SLRUCache[K, V]) shed(fraction float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(int(math.Ceil(float64(len(c.m)) * fraction)))
+}
+
+// set adds or updates the key in the cache.
+// It returns false if the key could not be added.
+func (c *SLRUCache[K, V]) set(k K, v V, exp time.Duration) bool {
+	if c.size == 0 || c.closed {
+		return false
+	}
+
+	var expireAt int64
+	if exp > 0 {
+		expireAt = c.opts.now().Add(exp).UnixNano()
+	}
+
+	if item, ok := c.m[k]; ok {
+		slruItem := item.Value.(*slruItem[K, V])
+		slruItem.value = v
+		slruItem.expireAt = expireAt
+		c.segment(slruItem).MoveToFront(item)
+		return true
+	}
+
+	before := len(c.m)
+	if uint(before) >= c.size {
+		if c.opts.rejectOnFull {
+			return false
+		}
+		c.evict(c.opts.evictionCount())
+	}
+
+	c.m[k] = c.probation.PushFront(&slruItem[K, V]{
+		key:      k,
+		value:    v,
+		expireAt: expireAt,
+	})
+	c.opts.observeHighWater(before, len(c.m), c.size)
+	return true
+}
+
+// access records a hit on the item: probationary items are promoted to the protected segment,
+// protected items are moved to the front of it.
+func (c *SLRUCache[K, V]) access(item *list.Element) {
+	entry := item.Value.(*slruItem[K, V])
+	if entry.protected {
+		c.protected.MoveToFront(item)
+		return
+	}
+
+	c.probation.Remove(item)
+	entry.protected = true
+	c.m[entry.key] = c.protected.PushFront(entry)
+
+	// Demote the least recently used protected items back to probation
+	for uint(c.protected.Len()) > c.protectedSize {
+		b := c.protected.Back()
+		demoted := c.protected.Remove(b).(*slruItem[K, V])
+		demoted.protected = false
+		c.m[demoted.key] = c.probation.PushFront(demoted)
+	}
+}
+
+// segment returns the list holding the item.
+func (c *SLRUCache[K, V]) segment(item *slruItem[K, V]) *list.List {
+	if item.protected {
+		return c.protected
+	}
+	return c.probation
+}
+
+// removeElement removes the given element from both the map and its segment
+// and notifies its value of the removal.
+func (c *SLRUCache[K, V]) removeElement(e *list.Element) {
+	slruItem := e.Value.(*slruItem[K, V])
+	delete(c.m, slruItem.key)
+	c.segment(slruItem).Remove(e)
+	c.opts.notifyRemoved(slruItem.value)
+}
+
+// notifyAll notifies the values of all items of their removal, before the cache is cleared.
+func (c *SLRUCache[K, V]) notifyAll() {
+	for _, e := range c.m {
+		c.opts.notifyRemoved(e.Value.(*slruItem[K, V]).value)
+	}
+}
+
+// evict removes i items, taking the least recently used probationary items first
+// and falling back to the least recently used protected items.
+func (c *SLRUCache[K, V]) evict(i int) {
+	for j := 0; j < i; j++ {
+		b := c.probation.Back()
+		if b == nil {
+			b = c.protected.Back()
+		}
+		if b == nil {
+			return
+		}
+		c.removeElement(b)
+		c.stats.Evictions++
+	}
+}
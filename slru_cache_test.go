@@ -0,0 +1,128 @@
+package incache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSLRUCache_SetGet(t *testing.T) {
+	c := NewSLRU[string, int](10)
+
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected 1, got %v", v)
+	}
+
+	c.Set("a", 2)
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("Expected 2, got %v", v)
+	}
+
+	if c.Len() != 1 {
+		t.Errorf("Expected Len=1, got %d", c.Len())
+	}
+}
+
+func TestSLRUCache_ScanResistance(t *testing.T) {
+	c := NewSLRU[string, int](10)
+
+	// Working set accessed twice is promoted to the protected segment
+	for _, k := range []string{"w1", "w2", "w3"} {
+		c.Set(k, 1)
+		c.Get(k)
+	}
+
+	// A scan of keys accessed only once cycles through probation
+	for i := 0; i < 100; i++ {
+		c.Set("scan"+string(rune('a'+i%26))+string(rune('a'+i/26)), i)
+	}
+
+	for _, k := range []string{"w1", "w2", "w3"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("Expected working set key %s to survive the scan", k)
+		}
+	}
+	if c.Len() != 10 {
+		t.Errorf("Expected Len=10, got %d", c.Len())
+	}
+}
+
+func TestSLRUCache_Demotion(t *testing.T) {
+	c := NewSLRU(4, WithProtectedRatio[string, int](0.5))
+
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, 1)
+		c.Get(k)
+	}
+
+	// Protected holds 2 items, so "a" was demoted back to probation
+	if c.protected.Len() != 2 || c.probation.Len() != 1 {
+		t.Fatalf("Expected 2 protected and 1 probationary items, got %d and %d", c.protected.Len(), c.probation.Len())
+	}
+
+	c.Set("d", 1)
+	c.Set("e", 1)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected demoted key a to be evicted from probation")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("Expected protected key b to survive")
+	}
+}
+
+func TestSLRUCache_SetWithTimeout(t *testing.T) {
+	clock := NewMockClock()
+	c := NewSLRU(10, WithClock[string, int](clock))
+
+	c.SetWithTimeout("a", 1, time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Expected a to be live")
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to have expired")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected expired key to be removed, got Len=%d", c.Len())
+	}
+}
+
+func TestSLRUCache_NotFoundSet(t *testing.T) {
+	c := NewSLRU[string, int](10)
+
+	if !c.NotFoundSet("a", 1) {
+		t.Errorf("Expected NotFoundSet to add a new key")
+	}
+	if c.NotFoundSet("a", 2) {
+		t.Errorf("Expected NotFoundSet to not overwrite an existing key")
+	}
+	if !c.ReplaceIfPresent("a", 3) || c.ReplaceIfPresent("b", 3) {
+		t.Errorf("Expected ReplaceIfPresent to only replace existing keys")
+	}
+	if v, _ := c.Get("a"); v != 3 {
+		t.Errorf("Expected 3, got %v", v)
+	}
+}
+
+func TestSLRUCache_DeletePurge(t *testing.T) {
+	c := NewSLRU[string, int](10)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("b")
+
+	c.Delete("b")
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be deleted")
+	}
+	if c.protected.Len() != 0 {
+		t.Errorf("Expected protected segment to be empty")
+	}
+
+	c.Purge()
+	if c.Len() != 0 || len(c.Keys()) != 0 || len(c.GetAll()) != 0 || c.Count() != 0 {
+		t.Errorf("Expected cache to be empty after Purge")
+	}
+}
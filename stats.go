@@ -0,0 +1,35 @@
+package incache
+
+import "time"
+
+// Stats holds the usage statistics of a cache.
+type Stats struct {
+	Hits      uint64 // lookups of keys that were found and not expired
+	Misses    uint64 // lookups of keys that were missing or expired
+	Evictions uint64 // entries removed to make room for new ones
+	Len       int    // number of entries in the cache, including expired ones
+}
+
+// HitRatio returns the fraction of lookups that were hits, or 0 if there were no lookups.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// runReporter calls report with the current stats every interval until stopCh is closed.
+func runReporter(stopCh <-chan struct{}, interval time.Duration, stats func() Stats, report func(Stats)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			report(stats())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package incache
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// adaptiveShortenRatio is the fraction of scanned entries that must have expired
+	// for an adaptive sweeper to halve its interval.
+	adaptiveShortenRatio = 0.25
+	// adaptiveLengthenRatio is the fraction of scanned entries below which
+	// an adaptive sweeper doubles its interval.
+	adaptiveLengthenRatio = 0.05
+)
+
+// sweeper periodically removes expired entries from a cache in the background.
+// If adaptive bounds are configured, the interval between sweeps is adjusted
+// based on the fraction of entries reclaimed by the previous sweep.
+type sweeper struct {
+	interval    atomic.Int64 // current interval in nanoseconds
+	lastRatio   atomic.Uint64
+	minInterval time.Duration
+	maxInterval time.Duration
+	jitter      float64 // fraction by which each wait is randomized, see WithCleanupJitter
+	paused      atomic.Bool
+}
+
+// newSweeper creates a sweeper with the given interval.
+// If minInterval and maxInterval are both positive, the sweeper is adaptive and
+// starts at maxInterval unless interval is set, in which case interval is clamped to the bounds.
+func newSweeper(interval, minInterval, maxInterval time.Duration) *sweeper {
+	s := &sweeper{
+		minInterval: minInterval,
+		maxInterval: maxInterval,
+	}
+	if s.adaptive() {
+		if interval <= 0 {
+			interval = maxInterval
+		}
+		interval = min(max(interval, minInterval), maxInterval)
+	}
+	s.interval.Store(int64(interval))
+	return s
+}
+
+func (s *sweeper) adaptive() bool {
+	return s.minInterval > 0 && s.maxInterval >= s.minInterval
+}
+
+// currentInterval returns the time the sweeper waits before the next sweep.
+func (s *sweeper) currentInterval() time.Duration {
+	return time.Duration(s.interval.Load())
+}
+
+// wait returns the time to wait before the next sweep: the current interval,
+// randomly shortened or lengthened by up to the jitter fraction of it.
+func (s *sweeper) wait() time.Duration {
+	interval := s.currentInterval()
+	if s.jitter <= 0 {
+		return interval
+	}
+	jitter := min(s.jitter, 1)
+	return interval + time.Duration((rand.Float64()*2-1)*jitter*float64(interval))
+}
+
+// run calls sweep every interval, unless the sweeper is paused, until stopCh receives a value or is closed.
+// sweep must remove expired entries and report how many entries it scanned and removed.
+func (s *sweeper) run(stopCh <-chan struct{}, sweep func() (scanned, removed int)) {
+	timer := time.NewTimer(s.wait())
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if !s.paused.Load() {
+				s.adjust(sweep())
+			}
+			timer.Reset(s.wait())
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// adjust updates the interval of an adaptive sweeper based on the reclaim ratio of the last sweep.
+func (s *sweeper) adjust(scanned, removed int) {
+	if !s.adaptive() {
+		return
+	}
+
+	var ratio float64
+	if scanned > 0 {
+		ratio = float64(removed) / float64(scanned)
+	}
+	s.lastRatio.Store(uint64(ratio * 1e6))
+
+	interval := s.currentInterval()
+	switch {
+	case ratio >= adaptiveShortenRatio:
+		interval = max(interval/2, s.minInterval)
+	case ratio < adaptiveLengthenRatio:
+		interval = min(interval*2, s.maxInterval)
+	}
+	s.interval.Store(int64(interval))
+}
+
+// lastReclaimRatio returns the fraction of scanned entries removed by the last sweep.
+func (s *sweeper) lastReclaimRatio() float64 {
+	return float64(s.lastRatio.Load()) / 1e6
+}
@@ -0,0 +1,169 @@
+package incache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSweeper_Adjust(t *testing.T) {
+	s := newSweeper(0, time.Millisecond, 8*time.Millisecond)
+	if s.currentInterval() != 8*time.Millisecond {
+		t.Fatalf("Expected adaptive sweeper to start at max interval, got %v", s.currentInterval())
+	}
+
+	s.adjust(100, 50)
+	if s.currentInterval() != 4*time.Millisecond {
+		t.Errorf("Expected interval to halve, got %v", s.currentInterval())
+	}
+	if s.lastReclaimRatio() != 0.5 {
+		t.Errorf("Expected last reclaim ratio 0.5, got %v", s.lastReclaimRatio())
+	}
+
+	for i := 0; i < 5; i++ {
+		s.adjust(100, 100)
+	}
+	if s.currentInterval() != time.Millisecond {
+		t.Errorf("Expected interval to stop at min, got %v", s.currentInterval())
+	}
+
+	for i := 0; i < 5; i++ {
+		s.adjust(100, 0)
+	}
+	if s.currentInterval() != 8*time.Millisecond {
+		t.Errorf("Expected interval to stop at max, got %v", s.currentInterval())
+	}
+
+	fixed := newSweeper(time.Second, 0, 0)
+	fixed.adjust(100, 100)
+	if fixed.currentInterval() != time.Second {
+		t.Errorf("Expected fixed sweeper interval to stay unchanged, got %v", fixed.currentInterval())
+	}
+}
+
+func TestWithAdaptiveCleanup(t *testing.T) {
+	const maxInterval = 20 * time.Millisecond
+
+	clock := NewMockClock()
+	opts := []Option[int, int]{
+		WithClock[int, int](clock),
+		WithAdaptiveCleanup[int, int](time.Millisecond, maxInterval),
+	}
+
+	lru := NewLRU(1000, opts...)
+	lfu := NewLFU(1000, opts...)
+	mc := NewManual(1000, 0, opts...)
+	defer lru.Close()
+	defer lfu.Close()
+	defer mc.Close()
+
+	caches := map[string]struct {
+		c       Cache[int, int]
+		sweeper *sweeper
+	}{
+		"LRU":    {lru, lru.sweeper},
+		"LFU":    {lfu, lfu.sweeper},
+		"MCache": {mc, mc.sweeper},
+	}
+
+	for _, tc := range caches {
+		for i := 0; i < 1000; i++ {
+			tc.c.SetWithTimeout(i, i, time.Millisecond)
+		}
+	}
+	clock.Advance(time.Second)
+
+	for name, tc := range caches {
+		deadline := time.Now().Add(time.Second)
+		for tc.sweeper.currentInterval() == maxInterval && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if tc.sweeper.currentInterval() >= maxInterval {
+			t.Errorf("%s: expected interval to shorten, got %v", name, tc.sweeper.currentInterval())
+		}
+		if tc.c.Len() != 0 {
+			t.Errorf("%s: expected expired entries to be swept, got Len=%d", name, tc.c.Len())
+		}
+	}
+}
+
+func TestWithCleanupJitter(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	c := NewLRU(10, WithCleanupInterval[int, int](interval), WithCleanupJitter[int, int](0.5))
+	defer c.Close()
+	s := c.sweeper
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		d := s.wait()
+		if d < interval/2 || d > interval*3/2 {
+			t.Fatalf("Expected waits within ±50%% of %v, got %v", interval, d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected the waits to be randomized")
+	}
+
+	start := time.Now()
+	swept := make(chan time.Duration, 1)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go s.run(stopCh, func() (int, int) {
+		select {
+		case swept <- time.Since(start):
+		default:
+		}
+		return 0, 0
+	})
+
+	select {
+	case d := <-swept:
+		if d < interval/2 {
+			t.Errorf("Expected the first sweep no earlier than %v, got %v", interval/2, d)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a first sweep within the jittered window")
+	}
+}
+
+func TestSetGlobalSweeper(t *testing.T) {
+	SetGlobalSweeper(5 * time.Millisecond)
+	defer SetGlobalSweeper(0)
+
+	caches := map[string]Cache[string, int]{
+		"LRU":    NewLRU(10, WithCleanupInterval[string, int](time.Hour)),
+		"LFU":    NewLFU(10, WithCleanupInterval[string, int](time.Hour)),
+		"MCache": NewManual[string, int](10, time.Hour),
+		"SLRU":   NewSLRU(10, WithCleanupInterval[string, int](time.Hour)),
+	}
+	unswept := NewLRU[string, int](10)
+	defer unswept.Close()
+	if n := len(globalSweeper.registered()); n != 4 {
+		t.Fatalf("Expected 4 registered caches without the one lacking a cleanup interval, got %d", n)
+	}
+	for _, c := range caches {
+		c.SetWithTimeout("a", 1, time.Millisecond)
+		c.Set("b", 2)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for name, c := range caches {
+		for c.Len() != 1 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if c.Len() != 1 {
+			t.Errorf("%s: expected the shared sweeper to remove the expired entry, got Len=%d", name, c.Len())
+		}
+		c.Close()
+	}
+	if n := len(globalSweeper.registered()); n != 0 {
+		t.Errorf("Expected closed caches to be dropped, got %d registered", n)
+	}
+
+	SetGlobalSweeper(0)
+	c := NewLRU(10, WithCleanupInterval[string, int](time.Hour))
+	defer c.Close()
+	if n := len(globalSweeper.registered()); n != 0 {
+		t.Errorf("Expected no registration without a global sweeper, got %d", n)
+	}
+}
@@ -0,0 +1,222 @@
+package incache
+
+import (
+	"fmt"
+	"maps"
+	"sync/atomic"
+	"time"
+)
+
+// TieredCache combines a small, fast primary cache with a larger secondary cache.
+// Reads check the primary first and fall back to the secondary, promoting values found there
+// into the primary. Writes go to both tiers, so a value evicted from the primary can still be
+// read from the secondary; with WithPrimaryOnlyWrites, writes only go to the primary.
+// Operations on the two tiers are not atomic with respect to each other.
+type TieredCache[K comparable, V any] struct {
+	primary   Cache[K, V]
+	secondary Cache[K, V]
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	opts      options[K, V]
+}
+
+// NewTiered creates a new tiered cache reading from primary first and falling back to secondary.
+// For example, NewTiered(NewLRU[string, int](1000), NewLFU[string, int](100000)).
+// Closing the tiered cache closes both tiers.
+func NewTiered[K comparable, V any](primary, secondary Cache[K, V], opts ...Option[K, V]) *TieredCache[K, V] {
+	return &TieredCache[K, V]{
+		primary:   primary,
+		secondary: secondary,
+		opts:      applyOptions(opts),
+	}
+}
+
+// Get retrieves the value associated with the given key from the primary cache, or from the secondary cache,
+// in which case the value is promoted into the primary cache with its remaining time to live.
+// If the key is not found in either tier or has expired, it returns (zero value of V, false).
+func (c *TieredCache[K, V]) Get(k K) (V, bool) {
+	e, ok := c.GetWithExpiration(k)
+	return e.Value, ok
+}
+
+// GetOrDefault retrieves the value associated with the given key like Get,
+// or returns def if the key is not found or has expired.
+// A value found in the secondary cache is promoted like Get.
+func (c *TieredCache[K, V]) GetOrDefault(k K, def V) V {
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	return def
+}
+
+// GetWithExpiration retrieves the value associated with the given key together with its expiration time,
+// promoting values found in the secondary cache like Get.
+// If the key is not found in either tier or has expired, it returns (zero value of ValueTTL[V], false).
+func (c *TieredCache[K, V]) GetWithExpiration(k K) (ValueTTL[V], bool) {
+	if e, ok := c.primary.GetWithExpiration(k); ok {
+		c.hits.Add(1)
+		return e, true
+	}
+
+	e, ok := c.secondary.GetWithExpiration(k)
+	if !ok {
+		c.misses.Add(1)
+		return e, false
+	}
+
+	c.hits.Add(1)
+	c.promote(k, e)
+	return e, true
+}
+
+// promote copies a value found in the secondary cache into the primary cache.
+func (c *TieredCache[K, V]) promote(k K, e ValueTTL[V]) {
+	if e.ExpireAt.IsZero() {
+		c.primary.Set(k, e.Value)
+		return
+	}
+	if ttl := e.ExpireAt.Sub(c.opts.now()); ttl > 0 {
+		c.primary.SetWithTimeout(k, e.Value, ttl)
+	}
+}
+
+// Set adds the key-value pair to both tiers, or only to the primary cache with WithPrimaryOnlyWrites.
+func (c *TieredCache[K, V]) Set(k K, v V) {
+	c.primary.Set(k, v)
+	if !c.opts.primaryOnlyWrites {
+		c.secondary.Set(k, v)
+	}
+}
+
+// SetWithTimeout adds the key-value pair with a specified expiration time to both tiers,
+// or only to the primary cache with WithPrimaryOnlyWrites.
+func (c *TieredCache[K, V]) SetWithTimeout(k K, v V, t time.Duration) {
+	c.primary.SetWithTimeout(k, v, t)
+	if !c.opts.primaryOnlyWrites {
+		c.secondary.SetWithTimeout(k, v, t)
+	}
+}
+
+// NotFoundSet adds the key-value pair only if the key does not exist in either tier or is expired.
+// It returns true if the key was added to the cache, otherwise false.
+func (c *TieredCache[K, V]) NotFoundSet(k K, v V) bool {
+	return c.NotFoundSetWithTimeout(k, v, 0)
+}
+
+// NotFoundSetWithTimeout adds the key-value pair with an expiration time only if the key does not exist
+// in either tier or is expired. It returns true if the key was added to the cache, otherwise false.
+func (c *TieredCache[K, V]) NotFoundSetWithTimeout(k K, v V, t time.Duration) bool {
+	if e, ok := c.secondary.GetWithExpiration(k); ok {
+		c.promote(k, e)
+		return false
+	}
+	if !c.primary.NotFoundSetWithTimeout(k, v, t) {
+		return false
+	}
+	if !c.opts.primaryOnlyWrites {
+		c.secondary.SetWithTimeout(k, v, t)
+	}
+	return true
+}
+
+// ReplaceIfPresent updates the value of the key in each tier where it exists and is not expired.
+// It returns true if the value was replaced in either tier, otherwise false.
+func (c *TieredCache[K, V]) ReplaceIfPresent(k K, v V) bool {
+	replaced := c.primary.ReplaceIfPresent(k, v)
+	return c.secondary.ReplaceIfPresent(k, v) || replaced
+}
+
+// Delete removes the key-value pair associated with the given key from both tiers.
+func (c *TieredCache[K, V]) Delete(k K) {
+	c.primary.Delete(k)
+	c.secondary.Delete(k)
+}
+
+// GetAll retrieves all non-expired key-value pairs from both tiers.
+// For keys stored in both tiers, the value of the primary cache is returned.
+func (c *TieredCache[K, V]) GetAll() map[K]V {
+	m := c.secondary.GetAll()
+	for k, v := range c.primary.GetAll() {
+		m[k] = v
+	}
+	return m
+}
+
+// GetAllWithExpiration retrieves all non-expired key-value pairs from both tiers together with their expiration times.
+// For keys stored in both tiers, the value of the primary cache is returned.
+func (c *TieredCache[K, V]) GetAllWithExpiration() map[K]ValueTTL[V] {
+	m := c.secondary.GetAllWithExpiration()
+	for k, v := range c.primary.GetAllWithExpiration() {
+		m[k] = v
+	}
+	return m
+}
+
+// Keys returns the non-expired keys of both tiers, each key once.
+// The order of keys in the slice is not guaranteed.
+func (c *TieredCache[K, V]) Keys() []K {
+	seen := make(map[K]struct{})
+	var keys []K
+	for _, tier := range []Cache[K, V]{c.primary, c.secondary} {
+		for _, k := range tier.Keys() {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// Purge removes all key-value pairs from both tiers.
+func (c *TieredCache[K, V]) Purge() {
+	c.primary.Purge()
+	c.secondary.Purge()
+}
+
+// drainWithTTL removes all key-value pairs from both tiers and returns the ones that were not expired
+// together with their remaining time to live, see drainAll. The primary cache wins for keys stored in both tiers.
+func (c *TieredCache[K, V]) drainWithTTL() map[K]drainedEntry[V] {
+	m := drainAll(c.secondary)
+	maps.Copy(m, drainAll(c.primary))
+	return m
+}
+
+// Count returns the number of distinct non-expired keys in both tiers.
+func (c *TieredCache[K, V]) Count() int {
+	return len(c.Keys())
+}
+
+// Len returns the total number of elements in both tiers (including expired ones).
+// Keys stored in both tiers are counted twice.
+func (c *TieredCache[K, V]) Len() int {
+	return c.primary.Len() + c.secondary.Len()
+}
+
+// Close closes both tiers.
+func (c *TieredCache[K, V]) Close() {
+	c.primary.Close()
+	c.secondary.Close()
+}
+
+// Stats returns the usage statistics of the cache. A hit is a Get that found the key in either tier
+// and a miss one that found it in neither. Evictions and Len are summed over both tiers.
+func (c *TieredCache[K, V]) Stats() Stats {
+	primary, secondary := c.primary.Stats(), c.secondary.Stats()
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: primary.Evictions + secondary.Evictions,
+		Len:       primary.Len + secondary.Len,
+	}
+}
+
+// Name returns the name of the cache set with WithName, or an empty string.
+func (c *TieredCache[K, V]) Name() string {
+	return c.opts.name
+}
+
+// String returns a compact summary of the cache, e.g. incache.Tiered[name=sessions primary=100 secondary=812].
+func (c *TieredCache[K, V]) String() string {
+	return fmt.Sprintf("incache.Tiered[name=%s primary=%d secondary=%d]", c.opts.name, c.primary.Count(), c.secondary.Count())
+}
@@ -0,0 +1,89 @@
+package incache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieredCache_PromotesOnSecondaryHit(t *testing.T) {
+	primary := NewLRU[string, int](10)
+	secondary := NewLRU[string, int](10)
+	c := NewTiered[string, int](primary, secondary)
+	defer c.Close()
+
+	secondary.Set("a", 1)
+	if _, ok := primary.Get("a"); ok {
+		t.Fatalf("Expected a to be missing from the primary cache")
+	}
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected 1 from the secondary cache, got %v, %v", v, ok)
+	}
+	if v, ok := primary.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a to be promoted into the primary cache, got %v, %v", v, ok)
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("Expected missing key to be missing")
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", s)
+	}
+}
+
+func TestTieredCache_PromoteKeepsRemainingTTL(t *testing.T) {
+	clock := NewMockClock()
+	primary := NewLRU(10, WithClock[string, int](clock))
+	secondary := NewLRU(10, WithClock[string, int](clock))
+	c := NewTiered(primary, secondary, WithClock[string, int](clock))
+	defer c.Close()
+
+	secondary.SetWithTimeout("a", 1, time.Minute)
+	clock.Advance(40 * time.Second)
+	c.Get("a")
+
+	clock.Advance(30 * time.Second)
+	if _, ok := primary.Get("a"); ok {
+		t.Errorf("Expected promoted value to expire with its original TTL")
+	}
+}
+
+func TestTieredCache_PrimaryEvictionFallsBack(t *testing.T) {
+	c := NewTiered[string, int](NewLRU[string, int](1), NewLRU[string, int](10))
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2) // evicts a from the primary cache
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a to be served by the secondary cache, got %v, %v", v, ok)
+	}
+	if c.Count() != 2 || len(c.GetAll()) != 2 {
+		t.Errorf("Expected 2 distinct keys, got Count=%d", c.Count())
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected deleted key to be missing from both tiers")
+	}
+}
+
+func TestTieredCache_WithPrimaryOnlyWrites(t *testing.T) {
+	primary := NewLRU[string, int](1)
+	secondary := NewLRU[string, int](10)
+	c := NewTiered(primary, secondary, WithPrimaryOnlyWrites[string, int]())
+	defer c.Close()
+
+	c.Set("a", 1)
+	if _, ok := secondary.Get("a"); ok {
+		t.Errorf("Expected Set not to write to the secondary cache")
+	}
+
+	secondary.Set("b", 2)
+	if c.NotFoundSet("b", 3) {
+		t.Errorf("Expected NotFoundSet to see the key in the secondary cache")
+	}
+	if v, _ := c.Get("b"); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+}
@@ -0,0 +1,65 @@
+package incache
+
+import "time"
+
+// Transfer moves all non-expired key-value pairs from src to dst, preserving their remaining time to live,
+// and empties src. Unlike the TransferTo methods, src and dst may be caches of different types,
+// e.g. to switch the eviction policy at runtime.
+// The remaining time to live is measured with the clock of src, see WithClock, and applied with the clock of dst.
+// The moved values are not notified of their removal from src, like with Drain; only the expired
+// entries of src are. Caches of other packages are read with GetAllWithExpiration and purged instead,
+// and their remaining time to live is measured with the system clock.
+// The locks of src and dst are never held at the same time, so entries written to src
+// while the transfer is in progress may be lost.
+func Transfer[K comparable, V any](src, dst Cache[K, V]) {
+	for k, e := range drainAll(src) {
+		switch {
+		case e.ttl == NoExpiry:
+			dst.Set(k, e.value)
+		case e.ttl > 0:
+			dst.SetWithTimeout(k, e.value, e.ttl)
+		}
+	}
+}
+
+// drainedEntry is a value removed from a cache together with its remaining time to live, measured with the clock
+// of the cache, or NoExpiry if it never expires.
+type drainedEntry[V any] struct {
+	value V
+	ttl   time.Duration
+}
+
+// drainer is implemented by the caches that can remove all their entries without notifying the live ones.
+type drainer[K comparable, V any] interface {
+	drainWithTTL() map[K]drainedEntry[V]
+}
+
+// drainAll removes all entries from c and returns the non-expired ones together with their remaining time to live.
+// The values it returns are not notified of their removal, unless c only implements Cache, in which case
+// it is read with GetAllWithExpiration and purged.
+func drainAll[K comparable, V any](c Cache[K, V]) map[K]drainedEntry[V] {
+	if d, ok := c.(drainer[K, V]); ok {
+		return d.drainWithTTL()
+	}
+
+	entries := c.GetAllWithExpiration()
+	c.Purge()
+	m := make(map[K]drainedEntry[V], len(entries))
+	for k, e := range entries {
+		ttl := NoExpiry
+		if !e.ExpireAt.IsZero() {
+			ttl = time.Until(e.ExpireAt)
+		}
+		m[k] = drainedEntry[V]{value: e.Value, ttl: ttl}
+	}
+	return m
+}
+
+// drainedValues returns the values of the drained entries.
+func drainedValues[K comparable, V any](entries map[K]drainedEntry[V]) map[K]V {
+	m := make(map[K]V, len(entries))
+	for k, e := range entries {
+		m[k] = e.value
+	}
+	return m
+}
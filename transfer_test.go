@@ -0,0 +1,108 @@
+package incache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransfer(t *testing.T) {
+	constructors := map[string]func() Cache[string, int]{
+		"LRU":    func() Cache[string, int] { return NewLRU[string, int](10) },
+		"LFU":    func() Cache[string, int] { return NewLFU[string, int](10) },
+		"SLRU":   func() Cache[string, int] { return NewSLRU[string, int](10) },
+		"MCache": func() Cache[string, int] { return NewManual[string, int](10, 0) },
+	}
+
+	for srcName, newSrc := range constructors {
+		for dstName, newDst := range constructors {
+			src, dst := newSrc(), newDst()
+
+			src.Set("a", 1)
+			src.SetWithTimeout("b", 2, time.Hour)
+			src.SetWithTimeout("c", 3, time.Microsecond)
+			time.Sleep(time.Millisecond)
+
+			Transfer(src, dst)
+
+			if src.Len() != 0 {
+				t.Errorf("%s->%s: expected src to be purged, got Len=%d", srcName, dstName, src.Len())
+			}
+			if dst.Len() != 2 {
+				t.Errorf("%s->%s: expected 2 live entries in dst, got Len=%d", srcName, dstName, dst.Len())
+			}
+			if v, ok := dst.Get("a"); !ok || v != 1 {
+				t.Errorf("%s->%s: expected a=1, got %v", srcName, dstName, v)
+			}
+
+			entries := dst.GetAllWithExpiration()
+			if !entries["a"].ExpireAt.IsZero() {
+				t.Errorf("%s->%s: expected a to never expire", srcName, dstName)
+			}
+			if ttl := time.Until(entries["b"].ExpireAt); ttl <= 59*time.Minute || ttl > time.Hour {
+				t.Errorf("%s->%s: expected b to keep its TTL, got %v", srcName, dstName, ttl)
+			}
+		}
+	}
+}
+
+func TestTransfer_SourceClock(t *testing.T) {
+	clock := NewMockClock()
+	clock.Advance(24 * time.Hour)
+	src := NewLRU(10, WithClock[string, int](clock))
+	src.SetWithTimeout("a", 1, time.Hour)
+	src.SetWithTimeout("b", 2, 2*time.Hour)
+	clock.Advance(90 * time.Minute)
+
+	dst := NewLFU[string, int](10)
+	Transfer(src, dst)
+
+	if _, ok := dst.Get("a"); ok {
+		t.Errorf("Expected a, expired according to the clock of src, not to be moved")
+	}
+	if ttl, ok := dst.TTL("b"); !ok || ttl <= 29*time.Minute || ttl > 30*time.Minute {
+		t.Errorf("Expected b to keep its remaining TTL according to the clock of src, got %v, %v", ttl, ok)
+	}
+}
+
+func TestTransfer_Expirable(t *testing.T) {
+	constructors := map[string]func() Cache[string, resource]{
+		"LRU":    func() Cache[string, resource] { return NewLRU[string, resource](10) },
+		"LFU":    func() Cache[string, resource] { return NewLFU[string, resource](10) },
+		"SLRU":   func() Cache[string, resource] { return NewSLRU[string, resource](10) },
+		"MCache": func() Cache[string, resource] { return NewManual[string, resource](10, 0) },
+		"Sharded": func() Cache[string, resource] {
+			return NewSharded(2, func() Cache[string, resource] { return NewLRU[string, resource](10) })
+		},
+		"Tiered": func() Cache[string, resource] {
+			return NewTiered[string, resource](NewLRU[string, resource](10), NewLFU[string, resource](10))
+		},
+	}
+
+	for name, newSrc := range constructors {
+		removed := false
+		src := newSrc()
+		src.Set("a", resource{&removed})
+
+		dst := NewLRU[string, resource](10)
+		Transfer(src, dst)
+		if removed {
+			t.Errorf("%s: expected OnRemoved not to be called for values moved by Transfer", name)
+		}
+		if _, ok := dst.Get("a"); !ok {
+			t.Errorf("%s: expected a to be moved to dst", name)
+		}
+
+		removed = false
+		src = newSrc()
+		src.Set("a", resource{&removed})
+		c := SwitchPolicy(src, PolicyLFU, 10)
+		if removed {
+			t.Errorf("%s: expected OnRemoved not to be called for values moved by SwitchPolicy", name)
+		}
+		c.Delete("a")
+		if !removed {
+			t.Errorf("%s: expected OnRemoved to be called when the value is deleted from the new cache", name)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package incache
+
+import (
+	"sync"
+	"time"
+)
+
+// writeBehind queues the keys written to a cache with their latest value and flushes them
+// to a backing store from a background goroutine, as configured by WithWriteBehind.
+type writeBehind[K comparable, V any] struct {
+	mu      sync.Mutex
+	pending map[K]V       // latest unflushed value of each written key
+	full    chan struct{} // signalled when a batch of keys is pending
+	done    chan struct{} // closed when the goroutine has drained the queue and stopped
+	opts    *options[K, V]
+}
+
+// newWriteBehind creates the write-behind queue configured by the options, or returns nil if none is configured.
+func newWriteBehind[K comparable, V any](o *options[K, V]) *writeBehind[K, V] {
+	if o.flush == nil {
+		return nil
+	}
+	return &writeBehind[K, V]{
+		pending: make(map[K]V),
+		full:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		opts:    o,
+	}
+}
+
+// enqueue queues the value of the key, replacing an older unflushed value.
+func (w *writeBehind[K, V]) enqueue(k K, v V) {
+	w.mu.Lock()
+	w.pending[k] = v
+	full := len(w.pending) >= w.opts.flushBatch
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run flushes the queued values whenever a batch is full or the interval elapses, until stopCh is closed.
+// It then flushes the remaining values once and closes done.
+func (w *writeBehind[K, V]) run(stopCh <-chan struct{}) {
+	defer close(w.done)
+
+	var tick <-chan time.Time
+	if w.opts.flushInterval > 0 {
+		ticker := time.NewTicker(w.opts.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.full:
+			w.flushPending(true)
+		case <-tick:
+			w.flushPending(true)
+		case <-stopCh:
+			w.flushPending(false)
+			return
+		}
+	}
+}
+
+// flushPending flushes the values that are queued when it is called, batch by batch.
+// If retry is true and WithWriteBehindRetry is not used, failed values are queued again
+// unless their key has been written since.
+func (w *writeBehind[K, V]) flushPending(retry bool) {
+	w.mu.Lock()
+	n := len(w.pending)
+	w.mu.Unlock()
+
+	for n > 0 {
+		batch := w.take(min(n, max(w.opts.flushBatch, 1)))
+		if len(batch) == 0 {
+			return
+		}
+		n -= len(batch)
+
+		for k, err := range w.writeBatch(batch) {
+			w.failed(k, batch[k], err)
+			if retry && w.opts.flushAttempts == 0 {
+				w.requeue(k, batch[k])
+			}
+		}
+	}
+}
+
+// writeBatch flushes the values of the batch and returns the errors of the keys that failed.
+// With WithWriteBehindRetry, failed values are retried with exponential backoff until they succeed,
+// their attempts are exhausted or their key is queued again with a newer value.
+func (w *writeBehind[K, V]) writeBatch(batch map[K]V) map[K]error {
+	failed := make(map[K]error)
+	for k, v := range batch {
+		if err := w.write(k, v); err != nil {
+			failed[k] = err
+		}
+	}
+
+	delay := w.opts.flushRetryDelay
+	for attempt := 1; attempt < w.opts.flushAttempts && len(failed) > 0; attempt++ {
+		time.Sleep(delay)
+		delay *= 2
+		for k := range failed {
+			if w.queued(k) {
+				delete(failed, k) // the newer value is flushed with a later batch
+			} else if err := w.write(k, batch[k]); err != nil {
+				failed[k] = err
+			} else {
+				delete(failed, k)
+			}
+		}
+	}
+	return failed
+}
+
+// queued reports whether a value of the key is queued.
+func (w *writeBehind[K, V]) queued(k K) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.pending[k]
+	return ok
+}
+
+// take removes up to n values from the queue and returns them.
+func (w *writeBehind[K, V]) take(n int) map[K]V {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batch := make(map[K]V, min(n, len(w.pending)))
+	for k, v := range w.pending {
+		if len(batch) == n {
+			break
+		}
+		batch[k] = v
+		delete(w.pending, k)
+	}
+	return batch
+}
+
+// requeue queues a value again after a failed flush, unless a newer value of the key is queued.
+func (w *writeBehind[K, V]) requeue(k K, v V) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.pending[k]; !ok {
+		w.pending[k] = v
+	}
+}
+
+// write calls the flush function. A recovered panic counts as a successful flush.
+func (w *writeBehind[K, V]) write(k K, v V) error {
+	defer w.opts.recoverCallback()
+	return w.opts.flush(k, v)
+}
+
+// failed reports a failed flush to the error hook, if any.
+func (w *writeBehind[K, V]) failed(k K, v V, err error) {
+	if w.opts.flushErrorHook == nil {
+		return
+	}
+	defer w.opts.recoverCallback()
+	w.opts.flushErrorHook(k, v, err)
+}